@@ -18,7 +18,9 @@ type CDT struct {
 }
 
 func New(reader *storage.Reader) *CDT {
-	return &CDT{tzx.New(reader)}
+	t := tzx.New(reader)
+	t.DisableLoaderDetection()
+	return &CDT{t}
 }
 
 func (d CDT) CommandDir() {
@@ -1,15 +1,23 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
 	"path"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/petscii"
+	"github.com/mrcook/retroio/storage"
 )
 
-var commodoreMediaTypeFlag string
+var (
+	commodoreMediaTypeFlag string
+	commodoreCharsetFlag   string
+)
 
 // commodoreCmd represents the spectrum command
 var commodoreCmd = &cobra.Command{
@@ -42,6 +50,22 @@ func commodoreDetermineMediaType(filename string) commodore.MediaType {
 		return commodore.D71
 	case "d81":
 		return commodore.D81
+	case "d80":
+		return commodore.D80
+	case "d82":
+		return commodore.D82
+	case "d1m":
+		return commodore.D1M
+	case "d2m":
+		return commodore.D2M
+	case "d4m":
+		return commodore.D4M
+	case "dnp":
+		return commodore.DNP
+	case "g64":
+		return commodore.G64
+	case "nib":
+		return commodore.NIB
 	case "t64":
 		return commodore.T64
 	case "tap":
@@ -50,3 +74,65 @@ func commodoreDetermineMediaType(filename string) commodore.MediaType {
 		return commodore.Unknown
 	}
 }
+
+// commodoreOpenDisk reads a D64, D71 or D81 image for modifying.
+func commodoreOpenDisk(filename string) (*disk.Disk, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader, err := storage.NewReaderFromFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType := commodoreDetermineMediaType(reader.Filename)
+	if mediaType != commodore.D64 && mediaType != commodore.D71 && mediaType != commodore.D81 {
+		return nil, fmt.Errorf("unsupported media type for this command")
+	}
+
+	dsk, err := disk.New(reader, mediaType, uint32(reader.FileSize))
+	if err != nil {
+		return nil, err
+	}
+	if err := dsk.Read(); err != nil {
+		return nil, err
+	}
+
+	return dsk, nil
+}
+
+// commodoreSaveDisk writes the modified disk image back to the file.
+func commodoreSaveDisk(filename string, dsk *disk.Disk) {
+	if err := os.WriteFile(filename, dsk.Bytes(), 0644); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// commodoreSetCharset sets the PETSCII character set from the --charset flag,
+// for the media types which display PETSCII names.
+func commodoreSetCharset(img interface{}) error {
+	cs, err := petscii.ParseCharset(commodoreCharsetFlag)
+	if err != nil {
+		return err
+	}
+	if selector, ok := img.(commodore.CharsetSelector); ok {
+		selector.SetCharset(cs)
+	}
+	return nil
+}
+
+// commodoreSetPath changes to the sub-directory at the path, for the media
+// types supporting them.
+func commodoreSetPath(img interface{}, path string) error {
+	if selector, ok := img.(commodore.PathSelector); ok {
+		return selector.SetPath(path)
+	}
+	if strings.Trim(path, "/") != "" {
+		return fmt.Errorf("sub-directories are not supported by this media type")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var commodoreCollectCmd = &cobra.Command{
+	Use:   "collect IMAGE",
+	Short: "Rebuild the BAM of a Commodore disk image",
+	Long: `Rebuild the BAM of a D64, D71 or D81 disk image from its directory, as done by
+the DOS VALIDATE command (COLLECT in BASIC 3.5 and 7.0).
+
+The sectors of every closed file are allocated, and all other sectors are
+freed. Unclosed (splat) files are scratched.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		dsk, err := commodoreOpenDisk(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		report, err := dsk.RebuildBAM()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, line := range report {
+			fmt.Printf("WARNING: %s\n", line)
+		}
+
+		commodoreSaveDisk(args[0], dsk)
+		fmt.Printf("%d BLOCKS FREE.\n", dsk.FreeBlocks())
+	},
+}
+
+func init() {
+	commodoreCollectCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreCmd.AddCommand(commodoreCollectCmd)
+}
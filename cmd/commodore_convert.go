@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/g64"
+	"github.com/mrcook/retroio/commodore/nib"
+	"github.com/mrcook/retroio/storage"
+)
+
+var commodoreConvertCmd = &cobra.Command{
+	Use:   "convert IMAGE OUTPUT",
+	Short: "Convert a G64 or NIB disk image to D64",
+	Long: `Convert a Commodore G64 or NIB raw GCR disk image to a D64 image, by decoding
+the sector headers and data blocks of each track.
+
+A D64 only stores the sector data, so half-tracks, speed zones and any
+non-standard track layouts are lost. Sectors which could not be decoded are
+recorded in the error bytes of the D64, using the error code the 1541 drive
+would report, such as a checksum error or a missing sector header. Tracks
+36-42 are included when they contain sectors.`,
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+
+		reader, err := storage.NewReaderFromFile(f)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if _, err := os.Stat(args[1]); err == nil {
+			fmt.Printf("image already exists: %s\n", args[1])
+			return
+		}
+
+		var img interface {
+			commodore.Image
+			Disk() *disk.Disk
+		}
+
+		switch commodoreDetermineMediaType(reader.Filename) {
+		case commodore.G64:
+			img = g64.New(reader)
+		case commodore.NIB:
+			img = nib.New(reader)
+		default:
+			fmt.Print("unsupported media type for this command")
+			return
+		}
+
+		if err := img.Read(); err != nil {
+			fmt.Println("Media read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		dsk := img.Disk()
+		for _, e := range dsk.SectorErrors() {
+			fmt.Printf("WARNING: %s\n", e)
+		}
+
+		commodoreSaveDisk(args[1], dsk)
+		fmt.Printf("D64 image written to %s (%s)\n", args[1], dsk.DiskType())
+	},
+}
+
+func init() {
+	commodoreConvertCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreCmd.AddCommand(commodoreConvertCmd)
+}
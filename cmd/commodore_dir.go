@@ -7,17 +7,35 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/cmdfd"
 	"github.com/mrcook/retroio/commodore/d64"
 	"github.com/mrcook/retroio/commodore/d71"
+	"github.com/mrcook/retroio/commodore/d80"
 	"github.com/mrcook/retroio/commodore/d81"
+	"github.com/mrcook/retroio/commodore/dnp"
+	"github.com/mrcook/retroio/commodore/g64"
+	"github.com/mrcook/retroio/commodore/nib"
+	"github.com/mrcook/retroio/commodore/t64"
+	"github.com/mrcook/retroio/commodore/tap"
 	"github.com/mrcook/retroio/storage"
 )
 
 var commodoreCommandDir = &cobra.Command{
-	Use:                   "dir FILE",
-	Short:                 "Displays the directory of a Commodore disk image",
-	Long:                  `Performs a directory listing for Commodore D64, D71, and D81 disk image files.`,
-	Args:                  cobra.ExactArgs(1),
+	Use:   "dir FILE [PATH]",
+	Short: "Displays the directory of a Commodore disk or tape image",
+	Long: `Performs a directory listing for Commodore D64, D71, D81, G64, and NIB disk
+image files, for the D80 and D82 disk images of the CBM 8050/8250 drives, and
+for the CMD D1M, D2M, D4M and DNP images. The files of a T64 tape image are
+listed in the same way, with their size in disk blocks, and the files of a TAP
+image as found by the Kernal tape loader.
+
+The PATH of a D81 sub-directory can be given, e.g. GAMES/, to list the files
+of the sub-directory, along with the blocks free in its partition. For a DNP
+the PATH is a native sub-directory.
+
+For a D1M, D2M or D4M the partition directory is listed, unless the PATH
+starts with the number or name of a native partition, e.g. 1/ or 1/GAMES/.`,
+	Args:                  cobra.RangeArgs(1, 2),
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
 		f, err := os.Open(args[0])
@@ -58,6 +76,26 @@ var commodoreCommandDir = &cobra.Command{
 				fmt.Println(err)
 				return
 			}
+		case commodore.D80, commodore.D82:
+			if dsk, err = d80.New(reader, mediaType, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.D1M, commodore.D2M, commodore.D4M:
+			if dsk, err = cmdfd.New(reader, mediaType, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.DNP:
+			dsk = dnp.New(reader)
+		case commodore.G64:
+			dsk = g64.New(reader)
+		case commodore.NIB:
+			dsk = nib.New(reader)
+		case commodore.T64:
+			dsk = t64.New(reader)
+		case commodore.TAP:
+			dsk = tap.New(reader)
 		default:
 			fmt.Print("unsupported media type for this command")
 			return
@@ -69,11 +107,24 @@ var commodoreCommandDir = &cobra.Command{
 			os.Exit(1)
 		}
 
+		if err := commodoreSetCharset(dsk); err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if len(args) > 1 {
+			if err := commodoreSetPath(dsk, args[1]); err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
+
 		dsk.CommandDir()
 	},
 }
 
 func init() {
 	commodoreCommandDir.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreCommandDir.Flags().StringVar(&commodoreCharsetFlag, "charset", "upper", `PETSCII character set: upper (upper/graphics) or lower (lower/upper)`)
 	commodoreCmd.AddCommand(commodoreCommandDir)
 }
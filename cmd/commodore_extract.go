@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/d64"
+	"github.com/mrcook/retroio/commodore/d71"
+	"github.com/mrcook/retroio/commodore/d81"
+	"github.com/mrcook/retroio/commodore/g64"
+	"github.com/mrcook/retroio/commodore/nib"
+	"github.com/mrcook/retroio/commodore/pc64"
+	"github.com/mrcook/retroio/commodore/t64"
+	"github.com/mrcook/retroio/commodore/tap"
+	"github.com/mrcook/retroio/storage"
+)
+
+var (
+	commodoreOutputDir string
+	commodoreRelFormat string
+	commodorePC64      bool
+)
+
+var commodoreExtractCmd = &cobra.Command{
+	Use:   "extract IMAGE [FILE...]",
+	Short: "Extract files from a Commodore disk or tape image",
+	Long: `Extract the PRG, SEQ, USR and REL files from a Commodore D64, D71, D81, G64 or
+NIB disk image by following their track/sector chains, or the files of a T64
+or TAP tape image, using their start address as the PRG load address.
+When no FILE names are given, all files on the disk are extracted.
+
+Files are written exactly as stored, so PRG files keep their load address.
+REL files can also be written as CSV, or a hex dump, with one entry for each
+record, using the --rel flag.
+
+Files in a D81 sub-directory are selected with their path, e.g. GAMES/ for all
+files of the sub-directory, or GAMES/LEVEL1 for a single file, and are written
+to a matching directory on the host system.
+
+With the --pc64 flag the files are written as PC64 files (P00, S00, U00, R00),
+keeping the PETSCII filename, and the record size of REL files, in the header.`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+
+		reader, err := storage.NewReaderFromFile(f)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		diskSize := uint32(reader.FileSize)
+
+		mediaType := commodoreDetermineMediaType(reader.Filename)
+		if mediaType == commodore.Unknown {
+			fmt.Printf("unknown media type for %s", reader.Filename)
+			return
+		}
+
+		var dsk commodore.FileExtractor
+
+		switch mediaType {
+		case commodore.D64:
+			if dsk, err = d64.New(reader, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.D71:
+			if dsk, err = d71.New(reader, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.D81:
+			if dsk, err = d81.New(reader, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.G64:
+			dsk = g64.New(reader)
+		case commodore.NIB:
+			dsk = nib.New(reader)
+		case commodore.T64:
+			dsk = t64.New(reader)
+		case commodore.TAP:
+			dsk = tap.New(reader)
+		default:
+			fmt.Print("unsupported media type for this command")
+			return
+		}
+
+		if err := commodoreSetCharset(dsk); err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if err := dsk.Read(); err != nil {
+			fmt.Println("Media read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if commodoreRelFormat != "raw" && commodoreRelFormat != "csv" && commodoreRelFormat != "hex" {
+			fmt.Printf("unknown REL format: '%s'\n", commodoreRelFormat)
+			return
+		}
+
+		for _, sel := range commodoreExtractSelections(dsk, args[1:]) {
+			if err := commodoreSetPath(dsk, sel.path); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			outputDir := filepath.Join(commodoreOutputDir, hostPath(sel.path))
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			commodoreExtractFiles(dsk.ExtractFiles(), sel.names, outputDir)
+		}
+	},
+}
+
+func init() {
+	commodoreExtractCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreExtractCmd.Flags().StringVar(&commodoreCharsetFlag, "charset", "upper", `PETSCII character set: upper (upper/graphics) or lower (lower/upper)`)
+	commodoreExtractCmd.Flags().StringVarP(&commodoreOutputDir, "output", "o", ".", `Output directory`)
+	commodoreExtractCmd.Flags().StringVar(&commodoreRelFormat, "rel", "raw", `REL file output: raw, csv, or hex`)
+	commodoreExtractCmd.Flags().BoolVar(&commodorePC64, "pc64", false, `Write the files as PC64 P00/S00/U00/R00 files`)
+	commodoreCmd.AddCommand(commodoreExtractCmd)
+}
+
+// commodoreExtractFiles writes the selected files to the output directory.
+func commodoreExtractFiles(files []commodore.File, names []string, outputDir string) {
+	written := make(map[string]bool)
+
+	for _, file := range files {
+		if !selectedFile(file.Name, names) {
+			continue
+		}
+
+		var outName string
+		var data []byte
+		if commodorePC64 {
+			data = pc64.Bytes(file)
+			outName = uniquePC64Filename(hostFilename(file.Name), file.Type, written)
+		} else {
+			var extension string
+			var err error
+			data, extension, err = relFileOutput(file, commodoreRelFormat)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			outName = uniqueFilename(hostFilename(file.Name), extension, written)
+		}
+
+		outPath := filepath.Join(outputDir, outName)
+		if err := os.WriteFile(outPath, data, 0644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		info := fmt.Sprintf("%d bytes", len(file.Data))
+		if file.RecordLength > 0 {
+			info += fmt.Sprintf(", %d records of %d bytes", len(file.Records()), file.RecordLength)
+		}
+		if address, ok := file.LoadAddress(); ok && file.Type == "prg" {
+			info += fmt.Sprintf(", load address $%04X", address)
+		}
+		fmt.Printf("%-16s -> %s (%s)\n", file.Name, outPath, info)
+		for _, w := range file.Warnings {
+			fmt.Printf("    WARNING: %s\n", w)
+		}
+	}
+}
+
+// commodoreExtraction is a sub-directory, and the names of the files to
+// extract from it, with no names selecting all files.
+type commodoreExtraction struct {
+	path  string
+	names []string
+}
+
+// commodoreExtractSelections groups the FILE arguments by sub-directory. On
+// media supporting sub-directories, GAMES/ selects all files of the GAMES
+// sub-directory, and GAMES/LEVEL1 a single file.
+func commodoreExtractSelections(img interface{}, args []string) []commodoreExtraction {
+	if _, ok := img.(commodore.PathSelector); !ok || len(args) == 0 {
+		return []commodoreExtraction{{names: args}}
+	}
+
+	var selections []commodoreExtraction
+	all := make(map[string]bool)
+	index := make(map[string]int)
+
+	for _, arg := range args {
+		path, name := "", arg
+		if i := strings.LastIndex(arg, "/"); i >= 0 {
+			path, name = strings.ToUpper(strings.Trim(arg[:i], "/")), arg[i+1:]
+		}
+
+		i, ok := index[path]
+		if !ok {
+			i = len(selections)
+			index[path] = i
+			selections = append(selections, commodoreExtraction{path: path})
+		}
+		if name == "" {
+			all[path] = true
+			selections[i].names = nil
+		} else if !all[path] {
+			selections[i].names = append(selections[i].names, name)
+		}
+	}
+
+	return selections
+}
+
+// hostPath converts a sub-directory path to a path on the host system.
+func hostPath(path string) string {
+	var parts []string
+	for _, name := range strings.Split(path, "/") {
+		if name != "" {
+			parts = append(parts, hostFilename(name))
+		}
+	}
+	return filepath.Join(parts...)
+}
+
+// uniqueFilename adds the extension to the name, appending a number when a
+// file of the same name has already been written, as disks may hold more
+// than one file with the same name.
+func uniqueFilename(name, extension string, written map[string]bool) string {
+	outName := name + "." + extension
+	for i := 1; written[outName]; i++ {
+		outName = fmt.Sprintf("%s_%d.%s", name, i, extension)
+	}
+	written[outName] = true
+	return outName
+}
+
+// uniquePC64Filename adds the PC64 extension for the file type to the name,
+// numbering the extension when a file of the same name has already been
+// written, e.g. GAME.P00, GAME.P01.
+func uniquePC64Filename(name, fileType string, written map[string]bool) string {
+	outName := name + "." + pc64.Extension(fileType, 0)
+	for i := 1; written[outName] && i < 100; i++ {
+		outName = name + "." + pc64.Extension(fileType, i)
+	}
+	written[outName] = true
+	return outName
+}
+
+// relFileOutput returns the data and file extension for writing the file,
+// converting the records of REL files to the requested format.
+func relFileOutput(file commodore.File, format string) ([]byte, string, error) {
+	if file.RecordLength == 0 {
+		return file.Data, file.Type, nil
+	}
+
+	switch format {
+	case "csv":
+		records, err := file.RecordsCSV()
+		return []byte(records), "csv", err
+	case "hex":
+		return []byte(file.RecordsHex()), "txt", nil
+	default:
+		return file.Data, file.Type, nil
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/d64"
+	"github.com/mrcook/retroio/commodore/d71"
+	"github.com/mrcook/retroio/commodore/d81"
+	"github.com/mrcook/retroio/commodore/disk"
+)
+
+var commodoreFormatCmd = &cobra.Command{
+	Use:   "format IMAGE NAME,ID",
+	Short: "Create a blank Commodore disk image",
+	Long: `Create a new, formatted, D64, D71 or D81 disk image with the given disk name
+and two character ID, as with the DOS command: OPEN 15,8,15,"N:NAME,ID"
+
+An existing image file will not be overwritten.`,
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+
+		if _, err := os.Stat(filename); err == nil {
+			fmt.Printf("image already exists: %s\n", filename)
+			return
+		}
+
+		header := strings.SplitN(args[1], ",", 2)
+		if len(header) != 2 {
+			fmt.Println("disk header must be given as NAME,ID")
+			return
+		}
+		name, id := header[0], header[1]
+
+		var dsk *disk.Disk
+		var err error
+
+		switch commodoreDetermineMediaType(filename) {
+		case commodore.D64:
+			dsk, err = d64.Format(name, id)
+		case commodore.D71:
+			dsk, err = d71.Format(name, id)
+		case commodore.D81:
+			dsk, err = d81.Format(name, id)
+		default:
+			fmt.Print("unsupported media type for this command")
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		commodoreSaveDisk(filename, dsk)
+		fmt.Printf("%s formatted, %d blocks free\n", filename, dsk.FreeBlocks())
+	},
+}
+
+func init() {
+	commodoreFormatCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreCmd.AddCommand(commodoreFormatCmd)
+}
@@ -7,9 +7,14 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/cmdfd"
 	"github.com/mrcook/retroio/commodore/d64"
 	"github.com/mrcook/retroio/commodore/d71"
+	"github.com/mrcook/retroio/commodore/d80"
 	"github.com/mrcook/retroio/commodore/d81"
+	"github.com/mrcook/retroio/commodore/dnp"
+	"github.com/mrcook/retroio/commodore/g64"
+	"github.com/mrcook/retroio/commodore/nib"
 	"github.com/mrcook/retroio/commodore/t64"
 	"github.com/mrcook/retroio/commodore/tap"
 	"github.com/mrcook/retroio/storage"
@@ -61,6 +66,22 @@ or T64 tape file.`,
 				fmt.Println(err)
 				return
 			}
+		case commodore.D80, commodore.D82:
+			if dsk, err = d80.New(reader, mediaType, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.D1M, commodore.D2M, commodore.D4M:
+			if dsk, err = cmdfd.New(reader, mediaType, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.DNP:
+			dsk = dnp.New(reader)
+		case commodore.G64:
+			dsk = g64.New(reader)
+		case commodore.NIB:
+			dsk = nib.New(reader)
 		case commodore.T64:
 			dsk = t64.New(reader)
 		case commodore.TAP:
@@ -77,11 +98,17 @@ or T64 tape file.`,
 			os.Exit(1)
 		}
 
+		if err := commodoreSetCharset(dsk); err != nil {
+			fmt.Println(err)
+			return
+		}
+
 		dsk.DisplayGeometry()
 	},
 }
 
 func init() {
 	commodoreGeometryCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreGeometryCmd.Flags().StringVar(&commodoreCharsetFlag, "charset", "upper", `PETSCII character set: upper (upper/graphics) or lower (lower/upper)`)
 	commodoreCmd.AddCommand(commodoreGeometryCmd)
 }
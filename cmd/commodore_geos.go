@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/petscii"
+)
+
+var commodoreGEOSDir string
+
+var commodoreGEOSCmd = &cobra.Command{
+	Use:   "geos IMAGE [FILE...]",
+	Short: "Show the GEOS files on a Commodore disk image",
+	Long: `Show the info block of the GEOS files on a D64, D71 or D81 disk image: the
+icon, class, author, description, and the records of VLIR files.
+
+Use -o to export the files to a directory on the host system in the CVT
+(ConVerT) format, which keeps the directory entry, info block and VLIR records
+of a GEOS file in a single sequential file, as used by the GEOS Convert tool
+and most emulators.
+
+When no FILE names are given, all GEOS files are used.`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		charset, err := petscii.ParseCharset(commodoreCharsetFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		dsk, err := commodoreOpenDisk(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		dsk.SetCharset(charset)
+
+		if signature := dsk.GEOSSignature(); signature != "" {
+			fmt.Printf("%s\n\n", signature)
+		} else {
+			fmt.Printf("not a GEOS formatted disk\n\n")
+		}
+
+		if commodoreGEOSDir != "" {
+			if err := os.MkdirAll(commodoreGEOSDir, 0755); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		entries, err := dsk.Directory()
+		if err != nil {
+			fmt.Println(err)
+		}
+
+		written := make(map[string]bool)
+		for _, e := range entries {
+			name := e.File.Name(charset)
+			if !e.File.IsGEOS() || !selectedFile(name, args[1:]) {
+				continue
+			}
+
+			fmt.Printf("%s\n", name)
+			fmt.Printf("  Type:        %s, %s\n", e.File.GEOSFileTypeName(), e.File.GEOSStructure())
+			if date, ok := e.File.GEOSDate(); ok {
+				fmt.Printf("  Date:        %s\n", date.Format("2006-01-02 15:04"))
+			}
+
+			info, err := dsk.GEOSInfo(e.File)
+			if err != nil {
+				fmt.Printf("  %s\n\n", err)
+				continue
+			}
+			fmt.Printf("  Class:       %s\n", info.ClassName())
+			if author := info.AuthorName(); author != "" {
+				fmt.Printf("  Author:      %s\n", author)
+			}
+			if parent := info.ParentName(); parent != "" {
+				fmt.Printf("  Application: %s\n", parent)
+			}
+			fmt.Printf("  Load:        $%04X\n", info.LoadAddress)
+			fmt.Printf("  Start:       $%04X\n", info.StartAddress)
+
+			if e.File.RecordLength == disk.GEOSVLIR {
+				if records, err := dsk.VLIRRecords(e.File); err != nil {
+					fmt.Printf("  %s\n", err)
+				} else {
+					fmt.Printf("  Records:     %d\n", len(records))
+					for i, r := range records {
+						switch {
+						case r.Empty:
+							fmt.Printf("    %3d: empty\n", i)
+						case r.Chain.Err != nil:
+							fmt.Printf("    %3d: %d blocks, %s\n", i, len(r.Chain.Sectors), r.Chain.Err)
+						default:
+							fmt.Printf("    %3d: %d blocks, %d bytes\n", i, len(r.Chain.Sectors), len(r.Chain.Data))
+						}
+					}
+				}
+			}
+
+			if description := info.DescriptionText(); description != "" {
+				fmt.Printf("  Description:\n")
+				for _, line := range strings.Split(description, "\n") {
+					fmt.Printf("    %s\n", line)
+				}
+			}
+			fmt.Println()
+			fmt.Print(info.IconString())
+
+			if commodoreGEOSDir != "" {
+				cvt, err := dsk.GEOSConvert(e.File)
+				if err != nil {
+					fmt.Printf("  not exported: %s\n\n", err)
+					continue
+				}
+				outName := uniqueFilename(hostFilename(name), "cvt", written)
+				outPath := filepath.Join(commodoreGEOSDir, outName)
+				if err := os.WriteFile(outPath, cvt, 0644); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				fmt.Printf("  -> %s (%d bytes)\n", outPath, len(cvt))
+			}
+			fmt.Println()
+		}
+	},
+}
+
+func init() {
+	commodoreGEOSCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreGEOSCmd.Flags().StringVar(&commodoreCharsetFlag, "charset", "upper", `PETSCII character set: upper (upper/graphics) or lower (lower/upper)`)
+	commodoreGEOSCmd.Flags().StringVarP(&commodoreGEOSDir, "output", "o", "", `Export the files in CVT format to this directory`)
+	commodoreCmd.AddCommand(commodoreGEOSCmd)
+}
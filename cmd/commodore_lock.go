@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var commodoreLockCmd = &cobra.Command{
+	Use:   "lock IMAGE FILE...",
+	Short: "Lock files on a Commodore disk image",
+	Long: `Set the locked flag of files on a D64, D71 or D81 disk image, protecting them
+from being scratched. Locked files are listed with a "<" after the file type.`,
+	Args:                  cobra.MinimumNArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		commodoreSetLocked(args[0], args[1:], true)
+	},
+}
+
+var commodoreUnlockCmd = &cobra.Command{
+	Use:                   "unlock IMAGE FILE...",
+	Short:                 "Unlock files on a Commodore disk image",
+	Long:                  `Clear the locked flag of files on a D64, D71 or D81 disk image.`,
+	Args:                  cobra.MinimumNArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		commodoreSetLocked(args[0], args[1:], false)
+	},
+}
+
+func init() {
+	commodoreLockCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreUnlockCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreCmd.AddCommand(commodoreLockCmd)
+	commodoreCmd.AddCommand(commodoreUnlockCmd)
+}
+
+func commodoreSetLocked(filename string, names []string, locked bool) {
+	dsk, err := commodoreOpenDisk(filename)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	status := "unlocked"
+	if locked {
+		status = "locked"
+	}
+
+	for _, name := range names {
+		if err := dsk.SetLocked(name, locked); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("\"%s\" %s\n", name, status)
+	}
+
+	commodoreSaveDisk(filename, dsk)
+}
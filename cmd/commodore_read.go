@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/basic"
+	"github.com/mrcook/retroio/commodore/d64"
+	"github.com/mrcook/retroio/commodore/d71"
+	"github.com/mrcook/retroio/commodore/d81"
+	"github.com/mrcook/retroio/commodore/g64"
+	"github.com/mrcook/retroio/commodore/nib"
+	"github.com/mrcook/retroio/commodore/petscii"
+	"github.com/mrcook/retroio/commodore/t64"
+	"github.com/mrcook/retroio/storage"
+)
+
+var (
+	commodoreBasFile      string
+	commodoreBasicVersion string
+)
+
+var commodoreReadCmd = &cobra.Command{
+	Use:   "read IMAGE --bas FILE",
+	Short: "Read a Commodore disk or tape file",
+	Long: `Read the contents of a file stored on a Commodore D64, D71, D81, G64 or NIB
+disk image, or a T64 tape image.
+
+Use --bas to list a BASIC program. The BASIC version is v7 for programs
+loading to $1C01 (C128), otherwise v2, unless given with --basic. C16 and
+Plus/4 programs load to $1001, as do those of the VIC-20, so need --basic v3.5.
+PETSCII control characters are shown by name, e.g. {clr} or {rvs on}.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if commodoreBasFile == "" {
+			_ = cmd.Help()
+			fmt.Println("\nPlease select '--bas FILE' for BASIC program listing.")
+			return
+		}
+
+		charset, err := petscii.ParseCharset(commodoreCharsetFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+
+		reader, err := storage.NewReaderFromFile(f)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		diskSize := uint32(reader.FileSize)
+
+		var dsk commodore.FileExtractor
+
+		switch commodoreDetermineMediaType(reader.Filename) {
+		case commodore.D64:
+			if dsk, err = d64.New(reader, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.D71:
+			if dsk, err = d71.New(reader, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.D81:
+			if dsk, err = d81.New(reader, diskSize); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case commodore.G64:
+			dsk = g64.New(reader)
+		case commodore.NIB:
+			dsk = nib.New(reader)
+		case commodore.T64:
+			dsk = t64.New(reader)
+		default:
+			fmt.Print("unsupported media type for this command")
+			return
+		}
+
+		if err := dsk.Read(); err != nil {
+			fmt.Println("Media read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, file := range dsk.ExtractFiles() {
+			if !selectedFile(file.Name, []string{commodoreBasFile}) {
+				continue
+			}
+
+			address, ok := file.LoadAddress()
+			if !ok || file.Type != "prg" {
+				fmt.Printf("%s is not a PRG file\n", file.Name)
+				os.Exit(1)
+			}
+
+			version := basic.VersionForLoadAddress(address)
+			if commodoreBasicVersion != "" {
+				if version, err = basic.ParseVersion(commodoreBasicVersion); err != nil {
+					fmt.Println(err)
+					return
+				}
+			}
+
+			fmt.Printf("\"%s\" (%s, load address $%04X)\n\n", file.Name, version, address)
+
+			lines, err := basic.Decode(file.Data, version, charset)
+			for _, line := range lines {
+				fmt.Println(line)
+			}
+			if err != nil {
+				fmt.Printf("\nERROR: %s\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		fmt.Printf("file not found: %s\n", commodoreBasFile)
+		os.Exit(1)
+	},
+}
+
+func init() {
+	commodoreReadCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreReadCmd.Flags().StringVar(&commodoreBasFile, "bas", "", `BASIC program listing of the named file`)
+	commodoreReadCmd.Flags().StringVar(&commodoreCharsetFlag, "charset", "upper", `PETSCII character set: upper (upper/graphics) or lower (lower/upper)`)
+	commodoreReadCmd.Flags().StringVar(&commodoreBasicVersion, "basic", "", `BASIC version: v2, v3.5, or v7, default: from the load address`)
+	commodoreCmd.AddCommand(commodoreReadCmd)
+}
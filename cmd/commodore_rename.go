@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var commodoreRenameCmd = &cobra.Command{
+	Use:                   "rename IMAGE OLD NEW",
+	Short:                 "Rename a file on a Commodore disk image",
+	Long:                  `Rename a file on a D64, D71 or D81 disk image.`,
+	Args:                  cobra.ExactArgs(3),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		dsk, err := commodoreOpenDisk(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := commodoreSetCharset(dsk); err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if err := dsk.Rename(args[1], args[2]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		commodoreSaveDisk(args[0], dsk)
+		fmt.Printf("\"%s\" renamed to \"%s\"\n", args[1], args[2])
+	},
+}
+
+func init() {
+	commodoreRenameCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreRenameCmd.Flags().StringVar(&commodoreCharsetFlag, "charset", "upper", `PETSCII character set: upper (upper/graphics) or lower (lower/upper)`)
+	commodoreCmd.AddCommand(commodoreRenameCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var commodoreScratchCmd = &cobra.Command{
+	Use:   "scratch IMAGE FILE...",
+	Short: "Scratch (delete) files on a Commodore disk image",
+	Long: `Scratch files on a D64, D71 or D81 disk image, freeing their sectors in the
+BAM. As with the DOS, the directory entry is only marked as scratched, and
+locked files can not be scratched.`,
+	Args:                  cobra.MinimumNArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		dsk, err := commodoreOpenDisk(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := commodoreSetCharset(dsk); err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		for _, name := range args[1:] {
+			if err := dsk.Scratch(name); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		commodoreSaveDisk(args[0], dsk)
+		fmt.Printf("%02d, FILES SCRATCHED,%02d,00\n", 1, len(args)-1)
+	},
+}
+
+func init() {
+	commodoreScratchCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreScratchCmd.Flags().StringVar(&commodoreCharsetFlag, "charset", "upper", `PETSCII character set: upper (upper/graphics) or lower (lower/upper)`)
+	commodoreCmd.AddCommand(commodoreScratchCmd)
+}
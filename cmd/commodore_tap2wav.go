@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/commodore/tap"
+	"github.com/mrcook/retroio/storage"
+)
+
+var (
+	commodoreClockFlag      string
+	commodoreWavClockFlag   string
+	commodoreMachineFlag    string
+	commodoreWaveFlag       string
+	commodoreSampleRateFlag int
+	commodoreTapVersionFlag uint8
+)
+
+var commodoreTap2WavCmd = &cobra.Command{
+	Use:   "tap2wav TAP WAV",
+	Short: "Convert a TAP tape image to a WAV file",
+	Long: `Convert a Commodore TAP tape image to a 16-bit mono WAV file, which can be
+played back to a datasette to write the tape.
+
+Each pulse of the TAP is converted to a single wave, using the clock rate of
+the machine and video standard given in the TAP header, unless set with the
+--clock flag. The half-waves of a v2 TAP each become half of a wave. A square
+wave is written by default, as produced by the C64, though a sine wave may
+give a cleaner recording on some tape decks.`,
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		var shape tap.WaveShape
+		switch commodoreWaveFlag {
+		case "square":
+			shape = tap.SquareWave
+		case "sine":
+			shape = tap.SineWave
+		default:
+			fmt.Printf("unknown wave shape: %s\n", commodoreWaveFlag)
+			return
+		}
+
+		if commodoreSampleRateFlag < 8000 {
+			fmt.Printf("invalid sample rate: %d\n", commodoreSampleRateFlag)
+			return
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+
+		reader, err := storage.NewReaderFromFile(f)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		t := tap.New(reader)
+		if err := t.Read(); err != nil {
+			fmt.Println("Media read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		clock := t.Clock()
+		if commodoreWavClockFlag != "" {
+			video, err := commodoreVideoStandard(commodoreWavClockFlag)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			clock = tap.MachineClock(t.Machine, video)
+		}
+
+		if err := os.WriteFile(args[1], t.WAV(clock, commodoreSampleRateFlag, shape), 0644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("WAV file written to %s (%d pulses)\n", args[1], len(t.Pulses))
+	},
+}
+
+func init() {
+	commodoreTap2WavCmd.Flags().StringVar(&commodoreWavClockFlag, "clock", "", `Clock rate of the machine: pal or ntsc, default: TAP header`)
+	commodoreTap2WavCmd.Flags().StringVar(&commodoreWaveFlag, "wave", "square", `Wave shape: square or sine`)
+	commodoreTap2WavCmd.Flags().IntVar(&commodoreSampleRateFlag, "rate", 44100, `Sample rate of the WAV file`)
+	commodoreCmd.AddCommand(commodoreTap2WavCmd)
+}
+
+// commodoreVideoStandard returns the TAP video standard for the --clock flag.
+func commodoreVideoStandard(name string) (uint8, error) {
+	switch name {
+	case "pal":
+		return tap.VideoPAL, nil
+	case "ntsc":
+		return tap.VideoNTSC, nil
+	default:
+		return 0, fmt.Errorf("unknown clock: %s", name)
+	}
+}
+
+// commodoreTapMachine returns the TAP machine type for the --machine flag.
+func commodoreTapMachine(name string) (uint8, error) {
+	switch name {
+	case "c64":
+		return tap.MachineC64, nil
+	case "vic20":
+		return tap.MachineVIC20, nil
+	case "c16":
+		return tap.MachineC16, nil
+	default:
+		return 0, fmt.Errorf("unknown machine: %s", name)
+	}
+}
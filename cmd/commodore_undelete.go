@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/commodore/petscii"
+)
+
+var (
+	commodoreUndeleteDir     string
+	commodoreUndeleteRestore bool
+	commodoreUndeleteType    string
+)
+
+var commodoreUndeleteCmd = &cobra.Command{
+	Use:   "undelete IMAGE [FILE...]",
+	Short: "Recover scratched files from a Commodore disk image",
+	Long: `List the scratched files on a D64, D71 or D81 disk image, showing whether each
+file is intact, partially overwritten, or lost. A file is intact while all the
+sectors of its chain are still free in the BAM.
+
+Use -o to recover the files to a directory on the host system, which includes
+the data of partially overwritten files up to the first reused sector. Use
+--restore to return intact files to the disk directory, allocating their
+sectors in the BAM. The DOS does not keep the type of a scratched file, so
+restored files are given the --type value.
+
+When no FILE names are given, all scratched files are used.`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		typeValue, ok := commodoreWriteTypes[strings.ToLower(commodoreUndeleteType)]
+		if !ok {
+			fmt.Printf("unsupported file type: '%s'\n", commodoreUndeleteType)
+			return
+		}
+
+		charset, err := petscii.ParseCharset(commodoreCharsetFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		dsk, err := commodoreOpenDisk(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		dsk.SetCharset(charset)
+
+		if commodoreUndeleteDir != "" {
+			if err := os.MkdirAll(commodoreUndeleteDir, 0755); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		extension := strings.ToLower(commodoreUndeleteType)
+		written := make(map[string]bool)
+		restored := 0
+
+		for _, file := range dsk.ScratchedFiles() {
+			name := file.Entry.File.Name(charset)
+			if !selectedFile(name, args[1:]) {
+				continue
+			}
+
+			fmt.Printf("%-16s %s (%d of %d blocks)\n", name, file.Status, len(file.Chain.Sectors), file.Entry.File.FileSizeInSectors)
+			for _, p := range file.Problems {
+				fmt.Printf("    %s\n", p)
+			}
+
+			if commodoreUndeleteDir != "" && len(file.Chain.Data) > 0 {
+				outName := uniqueFilename(hostFilename(name), extension, written)
+				outPath := filepath.Join(commodoreUndeleteDir, outName)
+				if err := os.WriteFile(outPath, file.Chain.Data, 0644); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				fmt.Printf("    -> %s (%d bytes)\n", outPath, len(file.Chain.Data))
+			}
+
+			if commodoreUndeleteRestore {
+				if err := dsk.Undelete(file, typeValue); err != nil {
+					fmt.Printf("    not restored: %s\n", err)
+					continue
+				}
+				fmt.Println("    restored to the disk directory")
+				restored++
+			}
+		}
+
+		if restored > 0 {
+			commodoreSaveDisk(args[0], dsk)
+		}
+	},
+}
+
+func init() {
+	commodoreUndeleteCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreUndeleteCmd.Flags().StringVar(&commodoreCharsetFlag, "charset", "upper", `PETSCII character set: upper (upper/graphics) or lower (lower/upper)`)
+	commodoreUndeleteCmd.Flags().StringVarP(&commodoreUndeleteDir, "output", "o", "", `Recover the files to this directory`)
+	commodoreUndeleteCmd.Flags().BoolVar(&commodoreUndeleteRestore, "restore", false, `Restore intact files to the disk directory`)
+	commodoreUndeleteCmd.Flags().StringVarP(&commodoreUndeleteType, "type", "t", "prg", `File type of the recovered files: prg, seq, or usr`)
+	commodoreCmd.AddCommand(commodoreUndeleteCmd)
+}
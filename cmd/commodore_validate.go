@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var commodoreValidateFix bool
+
+var commodoreValidateCmd = &cobra.Command{
+	Use:   "validate IMAGE",
+	Short: "Check the BAM of a Commodore disk image",
+	Long: `Check the BAM of a D64, D71 or D81 disk image against the sectors actually
+used by the directory and files, found by following every file chain.
+
+Reported are: allocated sectors not used by any file, sectors shared by two
+files, files using sectors marked as free, and track free sector counts which
+disagree with the sector bitmap.
+
+With the --fix flag a corrected BAM is written to the image. As with the DOS
+VALIDATE command, unclosed (splat) files are scratched.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		dsk, err := commodoreOpenDisk(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := commodoreSetCharset(dsk); err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		problems := dsk.ValidateBAM()
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) == 0 {
+			fmt.Println("BAM OK")
+			return
+		}
+		fmt.Printf("\n%d problems found\n", len(problems))
+
+		if !commodoreValidateFix {
+			return
+		}
+
+		if _, err := dsk.RebuildBAM(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		commodoreSaveDisk(args[0], dsk)
+		fmt.Printf("BAM rebuilt, %d BLOCKS FREE.\n", dsk.FreeBlocks())
+	},
+}
+
+func init() {
+	commodoreValidateCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreValidateCmd.Flags().StringVar(&commodoreCharsetFlag, "charset", "upper", `PETSCII character set: upper (upper/graphics) or lower (lower/upper)`)
+	commodoreValidateCmd.Flags().BoolVar(&commodoreValidateFix, "fix", false, `Write a corrected BAM to the image`)
+	commodoreCmd.AddCommand(commodoreValidateCmd)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/commodore/tap"
+)
+
+var commodoreWav2TapCmd = &cobra.Command{
+	Use:   "wav2tap WAV TAP",
+	Short: "Convert a WAV recording of a tape to a TAP tape image",
+	Long: `Convert a recording of a Commodore tape, in an 8 or 16-bit PCM WAV file, to a
+TAP tape image. For stereo recordings only the left channel is used.
+
+The pulses are measured between the falling zero crossings of the signal, as
+the datasette does, and converted to the clock cycles of the PAL or NTSC C64,
+VIC-20 or C16. With --version 2 the length of every half-wave is stored
+instead, which is the default for the C16.
+
+A high sample rate, such as 96000 or more, gives the most accurate pulses.`,
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		machine, err := commodoreTapMachine(commodoreMachineFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		video, err := commodoreVideoStandard(commodoreClockFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if machine == tap.MachineC16 && !cmd.Flags().Changed("version") {
+			commodoreTapVersionFlag = 2
+		}
+		if commodoreTapVersionFlag > 2 {
+			fmt.Printf("invalid TAP version: %d\n", commodoreTapVersionFlag)
+			return
+		}
+
+		if _, err := os.Stat(args[1]); err == nil {
+			fmt.Printf("image already exists: %s\n", args[1])
+			return
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		t, err := tap.FromWAV(data, machine, video, commodoreTapVersionFlag)
+		if err != nil {
+			fmt.Println("Media read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if err := os.WriteFile(args[1], t.Bytes(), 0644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("TAP image written to %s (%d pulses)\n", args[1], len(t.Pulses))
+	},
+}
+
+func init() {
+	commodoreWav2TapCmd.Flags().StringVar(&commodoreMachineFlag, "machine", "c64", `Machine the tape is for: c64, vic20 or c16`)
+	commodoreWav2TapCmd.Flags().StringVar(&commodoreClockFlag, "clock", "pal", `Clock rate of the machine: pal or ntsc`)
+	commodoreWav2TapCmd.Flags().Uint8Var(&commodoreTapVersionFlag, "version", 1, `TAP version to write: 0, 1 or 2`)
+	commodoreCmd.AddCommand(commodoreWav2TapCmd)
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/pc64"
+)
+
+var (
+	commodoreFileName string
+	commodoreFileType string
+)
+
+// Directory file type values for the files which can be written.
+var commodoreWriteTypes = map[string]uint8{
+	"del": 0,
+	"seq": 1,
+	"prg": 2,
+	"usr": 3,
+}
+
+var commodoreWriteCmd = &cobra.Command{
+	Use:   "write IMAGE FILE...",
+	Short: "Write files to a Commodore disk image",
+	Long: `Write files from the host system to a D64, D71 or D81 disk image. Sectors are
+allocated with the standard interleave of the drive, and the BAM and directory
+are updated.
+
+The disk filename is taken from the host filename, without its extension,
+unless the --name flag is given. The file type (PRG, SEQ, USR or DEL) is taken
+from the file extension, or the --type flag, and defaults to PRG. PRG files
+must include their two byte load address.
+
+PC64 files (P00, S00, U00, R00, D00) are written using the PETSCII filename and
+file type of their header, with REL files given the record size of the
+header.`,
+	Args:                  cobra.MinimumNArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if commodoreFileName != "" && len(args) > 2 {
+			fmt.Println("the --name flag can only be used when writing a single file")
+			return
+		}
+
+		dsk, err := commodoreOpenDisk(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, hostFile := range args[1:] {
+			data, err := os.ReadFile(hostFile)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			ext := filepath.Ext(hostFile)
+			name := commodoreFileName
+			if name == "" {
+				name = strings.TrimSuffix(filepath.Base(hostFile), ext)
+			}
+
+			var filename []byte // the PETSCII name of a PC64 file
+			var recordLength uint8
+			fileType := commodoreFileType
+			if pc64.IsPC64(ext) {
+				file, _, err := pc64.Read(data, ext)
+				if err != nil {
+					fmt.Printf("%s: %s\n", hostFile, err)
+					os.Exit(1)
+				}
+				for _, w := range file.Warnings {
+					fmt.Printf("WARNING: %s: %s\n", hostFile, w)
+				}
+				if commodoreFileName == "" {
+					name, filename = file.Name, file.Filename
+				}
+				if fileType == "" {
+					fileType = file.Type
+				}
+				data, recordLength = file.Data, file.RecordLength
+			}
+
+			var diskName [16]uint8
+			if filename != nil {
+				diskName, err = disk.PaddedName(filename)
+			} else {
+				diskName, err = disk.PETSCIIName(name)
+			}
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if strings.ToLower(fileType) == "rel" {
+				if err := dsk.WriteNamedRelFile(diskName, recordLength, data); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				fmt.Printf("%-20s -> \"%s\" REL (%d bytes, %d byte records)\n", hostFile, strings.ToUpper(name), len(data), recordLength)
+				continue
+			}
+
+			if fileType == "" {
+				fileType = strings.ToLower(strings.TrimPrefix(ext, "."))
+				if _, ok := commodoreWriteTypes[fileType]; !ok {
+					fileType = "prg"
+				}
+			}
+			typeValue, ok := commodoreWriteTypes[strings.ToLower(fileType)]
+			if !ok {
+				fmt.Printf("unsupported file type: '%s'\n", fileType)
+				os.Exit(1)
+			}
+
+			if err := dsk.WriteNamedFile(diskName, typeValue, data); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Printf("%-20s -> \"%s\" %s (%d bytes)\n", hostFile, strings.ToUpper(name), strings.ToUpper(fileType), len(data))
+		}
+
+		commodoreSaveDisk(args[0], dsk)
+		fmt.Printf("%d BLOCKS FREE.\n", dsk.FreeBlocks())
+	},
+}
+
+func init() {
+	commodoreWriteCmd.Flags().StringVarP(&commodoreMediaTypeFlag, "media", "m", "", `Media type, default: file extension`)
+	commodoreWriteCmd.Flags().StringVarP(&commodoreFileName, "name", "n", "", `Filename on the disk, default: host filename`)
+	commodoreWriteCmd.Flags().StringVarP(&commodoreFileType, "type", "t", "", `File type: prg, seq, usr, or del, default: file extension`)
+	commodoreCmd.AddCommand(commodoreWriteCmd)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/spectrum"
+	"github.com/mrcook/retroio/spectrum/fdi"
+	"github.com/mrcook/retroio/spectrum/floppy"
+	"github.com/mrcook/retroio/spectrum/udi"
+	"github.com/mrcook/retroio/storage"
+)
+
+var speccyConvertCmd = &cobra.Command{
+	Use:   "convert IMAGE OUTPUT",
+	Short: "Convert a UDI or FDI disk image to TRD",
+	Long: `Convert a ZX Spectrum UDI or FDI raw disk image to a plain TRD image.
+
+A TRD only stores the logical sector data, so any copy protection on the disk
+(extra or missing sectors, non-standard sector sizes, CRC errors, deleted data
+marks) is lost during conversion. A warning is shown for each of these.`,
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+		reader := storage.NewReader(f)
+
+		var dsk spectrum.Image
+		var disk *floppy.Disk
+		dskType := mediaType(spectrumMediaType, filename)
+
+		switch dskType {
+		case "udi":
+			img := udi.New(reader)
+			dsk, disk = img, &img.Disk
+		case "fdi":
+			img := fdi.New(reader)
+			dsk, disk = img, &img.Disk
+		default:
+			fmt.Printf("Unsupported media type: '%s'", dskType)
+			return
+		}
+
+		if err := dsk.Read(); err != nil {
+			fmt.Println("Storage read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, w := range disk.TRDWarnings() {
+			fmt.Printf("WARNING: %s\n", w)
+		}
+
+		if err := os.WriteFile(args[1], disk.TRDImage(), 0644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("TRD image written to %s\n", args[1])
+	},
+}
+
+func init() {
+	speccyConvertCmd.Flags().StringVarP(&spectrumMediaType, "media", "m", "", `Media type, default: file extension`)
+	spectrumCmd.AddCommand(speccyConvertCmd)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/spectrum"
+	"github.com/mrcook/retroio/spectrum/fdi"
+	"github.com/mrcook/retroio/spectrum/mdr"
+	"github.com/mrcook/retroio/spectrum/mgt"
+	"github.com/mrcook/retroio/spectrum/trd"
+	"github.com/mrcook/retroio/spectrum/udi"
+	"github.com/mrcook/retroio/storage"
+)
+
+var speccyCommandDir = &cobra.Command{
+	Use:                   "dir FILE",
+	Aliases:               []string{"cat"},
+	Short:                 "Displays the directory of a disk or microdrive image",
+	Long:                  `Reads and displays the directory listing found on a ZX Spectrum TRD, UDI, FDI or MGT disk, or MDR microdrive cartridge image.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+		reader := storage.NewReader(f)
+
+		var dsk spectrum.Image
+		dskType := mediaType(spectrumMediaType, filename)
+
+		switch dskType {
+		case "trd":
+			dsk = trd.New(reader)
+		case "mdr":
+			dsk = mdr.New(reader)
+		case "mgt":
+			dsk = mgt.New(reader)
+		case "udi":
+			dsk = udi.New(reader)
+		case "fdi":
+			dsk = fdi.New(reader)
+		default:
+			fmt.Printf("Unsupported media type: '%s'", dskType)
+			return
+		}
+
+		if err := dsk.Read(); err != nil {
+			fmt.Println("Storage read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		dsk.CommandDir()
+	},
+}
+
+func init() {
+	speccyCommandDir.Flags().StringVarP(&spectrumMediaType, "media", "m", "", `Media type, default: file extension`)
+	spectrumCmd.AddCommand(speccyCommandDir)
+}
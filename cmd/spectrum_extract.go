@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/spectrum"
+	"github.com/mrcook/retroio/spectrum/mdr"
+	"github.com/mrcook/retroio/spectrum/mgt"
+	"github.com/mrcook/retroio/storage"
+)
+
+var spectrumOutputDir string
+
+var speccyExtractCmd = &cobra.Command{
+	Use:   "extract IMAGE [FILE...]",
+	Short: "Extract files from a ZX Spectrum disk or microdrive image",
+	Long: `Extract files from a ZX Spectrum MGT disk or MDR microdrive image. When
+no FILE names are given, all files on the media are extracted.
+
+Any DOS file header is removed, so only the file contents are written.`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+		reader := storage.NewReader(f)
+
+		var dsk spectrum.FileExtractor
+		dskType := mediaType(spectrumMediaType, filename)
+
+		switch dskType {
+		case "mdr":
+			dsk = mdr.New(reader)
+		case "mgt":
+			dsk = mgt.New(reader)
+		default:
+			fmt.Printf("Unsupported media type: '%s'", dskType)
+			return
+		}
+
+		if err := dsk.Read(); err != nil {
+			fmt.Println("Storage read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if err := os.MkdirAll(spectrumOutputDir, 0755); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, file := range dsk.ExtractFiles() {
+			if !selectedFile(file.Name, args[1:]) {
+				continue
+			}
+
+			outName := hostFilename(file.Name) + "." + file.Extension
+			outPath := filepath.Join(spectrumOutputDir, outName)
+			if err := os.WriteFile(outPath, file.Data, 0644); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("%-10s -> %s (%d bytes)\n", file.Name, outPath, len(file.Data))
+			for _, w := range file.Warnings {
+				fmt.Printf("    WARNING: %s\n", w)
+			}
+		}
+	},
+}
+
+func init() {
+	speccyExtractCmd.Flags().StringVarP(&spectrumMediaType, "media", "m", "", `Media type, default: file extension`)
+	speccyExtractCmd.Flags().StringVarP(&spectrumOutputDir, "output", "o", ".", `Output directory`)
+	spectrumCmd.AddCommand(speccyExtractCmd)
+}
+
+// selectedFile returns true if no filenames were requested, or if the name
+// matches one of them (case insensitive).
+func selectedFile(name string, requested []string) bool {
+	if len(requested) == 0 {
+		return true
+	}
+	for _, r := range requested {
+		if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostFilename replaces any characters in a retro filename that are not safe
+// to use in a filename on the host system.
+func hostFilename(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7F:
+			return '_'
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	if safe == "" || safe == "." || safe == ".." {
+		safe = "_"
+	}
+	return safe
+}
@@ -7,9 +7,13 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/mrcook/retroio/spectrum"
+	"github.com/mrcook/retroio/spectrum/fdi"
+	"github.com/mrcook/retroio/spectrum/mdr"
+	"github.com/mrcook/retroio/spectrum/mgt"
 	"github.com/mrcook/retroio/spectrum/tap"
 	"github.com/mrcook/retroio/spectrum/trd"
 	"github.com/mrcook/retroio/spectrum/tzx"
+	"github.com/mrcook/retroio/spectrum/udi"
 	"github.com/mrcook/retroio/storage"
 )
 
@@ -17,7 +21,7 @@ var speccyGeometryCmd = &cobra.Command{
 	Use:   "geometry FILE",
 	Short: "Read the ZX Spectrum tape geometry",
 	Long: `Read the geometry - headers and data tracks/sectors/blocks - from a
-ZX Spectrum emulator TZX or TAP file.`,
+ZX Spectrum emulator TZX, TAP, TRD, UDI, FDI, MGT or MDR file.`,
 	Args:                  cobra.ExactArgs(1),
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -39,6 +43,14 @@ ZX Spectrum emulator TZX or TAP file.`,
 			dsk = tap.New(reader)
 		case "tzx":
 			dsk = tzx.New(reader)
+		case "mdr":
+			dsk = mdr.New(reader)
+		case "mgt":
+			dsk = mgt.New(reader)
+		case "udi":
+			dsk = udi.New(reader)
+		case "fdi":
+			dsk = fdi.New(reader)
 		case "trd":
 			dsk = trd.New(reader)
 		default:
@@ -7,6 +7,8 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/mrcook/retroio/spectrum"
+	"github.com/mrcook/retroio/spectrum/mdr"
+	"github.com/mrcook/retroio/spectrum/mgt"
 	"github.com/mrcook/retroio/spectrum/tap"
 	"github.com/mrcook/retroio/spectrum/tzx"
 	"github.com/mrcook/retroio/storage"
@@ -14,8 +16,8 @@ import (
 
 var speccyReadCmd = &cobra.Command{
 	Use:                   "read FILE",
-	Short:                 "Read a ZX Spectrum tape file",
-	Long:                  `Read the contents of a ZX Spectrum emulator TAP or TZX tape file.`,
+	Short:                 "Read a ZX Spectrum tape, disk or microdrive file",
+	Long:                  `Read the contents of a ZX Spectrum emulator TAP or TZX tape file, an MGT disk, or an MDR microdrive cartridge.`,
 	Args:                  cobra.ExactArgs(1),
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -37,6 +39,10 @@ var speccyReadCmd = &cobra.Command{
 			dsk = tap.New(reader)
 		case "tzx":
 			dsk = tzx.New(reader)
+		case "mdr":
+			dsk = mdr.New(reader)
+		case "mgt":
+			dsk = mgt.New(reader)
 		default:
 			fmt.Printf("Unsupported media type: '%s'", dskType)
 			return
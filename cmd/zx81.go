@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/storage"
+	"github.com/mrcook/retroio/zx81"
+	"github.com/mrcook/retroio/zx81/o"
+	"github.com/mrcook/retroio/zx81/p"
+	"github.com/mrcook/retroio/zx81/tzx"
+)
+
+var (
+	zx81MediaType  string
+	zx81BasListing bool
+	zx81OutputFile string
+)
+
+// zx81Cmd represents the zx81 command
+var zx81Cmd = &cobra.Command{
+	Use:     "zx81",
+	Aliases: []string{"zx80"},
+	Short:   "System command for the ZX81 and ZX80",
+	Long: `The computer system command for working with tape images for the
+Sinclair ZX81 and ZX80 home computers.
+
+This is a top-level system command only and requires a sub-command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(zx81Cmd)
+}
+
+// zx81Image returns the reader for the media type, or nil when unsupported.
+func zx81Image(reader *storage.Reader, dskType string) zx81.Image {
+	switch dskType {
+	case "p", "81":
+		return p.New(reader)
+	case "p81":
+		return p.NewP81(reader)
+	case "o", "80":
+		return o.New(reader)
+	case "tzx":
+		return tzx.New(reader)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/storage"
+)
+
+var zx81ReadCmd = &cobra.Command{
+	Use:                   "read FILE",
+	Short:                 "Read a ZX81/ZX80 program or tape file",
+	Long:                  `Read the contents of a ZX81 P, 81 or P81 program, a ZX80 O or 80 program, or a ZX81 TZX tape file.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+		reader := storage.NewReader(f)
+
+		dskType := mediaType(zx81MediaType, filename)
+		dsk := zx81Image(reader, dskType)
+		if dsk == nil {
+			fmt.Printf("Unsupported media type: '%s'", dskType)
+			return
+		}
+
+		if err := dsk.Read(); err != nil {
+			fmt.Println("Storage read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if zx81BasListing {
+			dsk.DisplayBASIC()
+		} else {
+			cmd.Help()
+			fmt.Println("\nPlease select '--bas' for BASIC program listing.")
+		}
+	},
+}
+
+func init() {
+	zx81ReadCmd.Flags().StringVarP(&zx81MediaType, "media", "m", "", `Media type, default: file extension`)
+	zx81ReadCmd.Flags().BoolVar(&zx81BasListing, "bas", false, `BASIC program listing`)
+	zx81Cmd.AddCommand(zx81ReadCmd)
+}
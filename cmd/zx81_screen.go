@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrcook/retroio/storage"
+)
+
+var zx81ScreenCmd = &cobra.Command{
+	Use:   "screen FILE",
+	Short: "Export the ZX81 display file",
+	Long: `Decode the display file saved with a ZX81 program, and export the screen as
+text, using Unicode block characters for the graphics.
+
+When a tape holds more than one program, each screen is exported in turn.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+		reader := storage.NewReader(f)
+
+		dskType := mediaType(zx81MediaType, filename)
+		dsk := zx81Image(reader, dskType)
+		if dsk == nil {
+			fmt.Printf("Unsupported media type: '%s'", dskType)
+			return
+		}
+
+		if err := dsk.Read(); err != nil {
+			fmt.Println("Storage read error!")
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		output := ""
+		for i, program := range dsk.Programs() {
+			screen, err := program.Screen()
+			if err != nil {
+				fmt.Printf("Program #%02d: %s\n", i+1, err)
+				continue
+			}
+			if output != "" {
+				output += "\n"
+			}
+			output += screen.String()
+		}
+
+		if zx81OutputFile == "" {
+			fmt.Print(output)
+			return
+		}
+		if err := os.WriteFile(zx81OutputFile, []byte(output), 0644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	zx81ScreenCmd.Flags().StringVarP(&zx81MediaType, "media", "m", "", `Media type, default: file extension`)
+	zx81ScreenCmd.Flags().StringVarP(&zx81OutputFile, "output", "o", "", `Write the screen to a file, default: stdout`)
+	zx81Cmd.AddCommand(zx81ScreenCmd)
+}
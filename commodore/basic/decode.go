@@ -0,0 +1,164 @@
+// Package basic is a decoder for Commodore BASIC programs, as stored in PRG
+// files: BASIC v2 of the C64 and VIC-20, v3.5 of the C16 and Plus/4, and v7
+// of the C128.
+//
+// A PRG starts with the two byte load address, followed by the program
+// lines. Each line is stored as a 2 byte link to the address of the next
+// line, a 2 byte line number, the tokenised text, and a $00 terminator. A
+// link of $0000 marks the end of the program. All values are low byte first.
+package basic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mrcook/retroio/commodore/petscii"
+)
+
+// Version of Commodore BASIC.
+type Version int
+
+const (
+	V2  Version = iota // C64, VIC-20
+	V35                // C16, Plus/4
+	V7                 // C128
+)
+
+func (v Version) String() string {
+	switch v {
+	case V35:
+		return "BASIC v3.5"
+	case V7:
+		return "BASIC v7"
+	default:
+		return "BASIC v2"
+	}
+}
+
+// ParseVersion converts a version name (v2, v3.5, or v7) to its Version.
+func ParseVersion(name string) (Version, error) {
+	switch strings.TrimPrefix(strings.ToLower(name), "v") {
+	case "2":
+		return V2, nil
+	case "3.5", "35":
+		return V35, nil
+	case "7":
+		return V7, nil
+	}
+	return V2, fmt.Errorf("unknown BASIC version: '%s'", name)
+}
+
+// VersionForLoadAddress returns the BASIC version of the machine which
+// normally stores its programs at the load address: $1C01 for the C128,
+// otherwise v2. Programs at $1001 may be for the C16/Plus4 (v3.5), but this
+// is also the start of BASIC on an unexpanded VIC-20, so v2 is used.
+func VersionForLoadAddress(address uint16) Version {
+	if address == 0x1C01 {
+		return V7
+	}
+	return V2
+}
+
+// Decode the PRG data as a BASIC program, returning one string per line.
+// Text is converted using the PETSCII character set.
+//
+// Lines are followed using their links, relative to the load address. When
+// a link does not point to the following line, as is often the case with
+// relocated or protected programs, the line terminator is used instead.
+func Decode(prg []byte, version Version, charset petscii.Charset) ([]string, error) {
+	var basic []string
+
+	if len(prg) < 2 {
+		return nil, fmt.Errorf("program is too short to contain a load address")
+	}
+	load := int(prg[0]) | int(prg[1])<<8
+	program := prg[2:]
+
+	for pos := 0; pos < len(program); {
+		if pos+2 > len(program) {
+			return basic, fmt.Errorf("unexpected end of program at line link")
+		}
+		link := int(program[pos]) | int(program[pos+1])<<8
+		if link == 0 {
+			return basic, nil
+		}
+		if pos+4 > len(program) {
+			return basic, fmt.Errorf("unexpected end of program at line number")
+		}
+		lineNum := int(program[pos+2]) | int(program[pos+3])<<8
+
+		end := pos + 4
+		for end < len(program) && program[end] != 0x00 {
+			end++
+		}
+		if end == len(program) {
+			return basic, fmt.Errorf("line %d: missing line terminator", lineNum)
+		}
+
+		basic = append(basic, fmt.Sprintf("%d %s", lineNum, decodeLine(program[pos+4:end], version, charset)))
+
+		next := link - load
+		if next != end+1 && (next <= pos || next >= len(program) || program[next-1] != 0x00) {
+			next = end + 1
+		}
+		pos = next
+	}
+
+	return basic, nil
+}
+
+// decodeLine expands the tokens of a line. Text in quotes, and following a
+// REM, is not tokenised, so is shown as PETSCII.
+func decodeLine(line []byte, version Version, charset petscii.Charset) string {
+	var text strings.Builder
+
+	quoted := false
+	remark := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case c == '"':
+			quoted = !quoted
+			text.WriteByte('"')
+		case quoted || remark || c < 0x80:
+			text.WriteString(petscii.Character(c, charset))
+		case c == 0xFF:
+			text.WriteString("π")
+		case version == V7 && (c == v7PrefixCE || c == v7PrefixFE) && i+1 < len(line):
+			tokens := v7TokensCE
+			if c == v7PrefixFE {
+				tokens = v7TokensFE
+			}
+			i++
+			if token, ok := tokens[line[i]]; ok {
+				text.WriteString(token)
+			} else {
+				text.WriteString(fmt.Sprintf("{$%02x%02x}", c, line[i]))
+			}
+		default:
+			token := Token(c, version)
+			remark = token == "REM"
+			if charset == petscii.Lower {
+				// keywords are listed in lowercase with the lowercase/uppercase set
+				token = strings.ToLower(token)
+			}
+			text.WriteString(token)
+		}
+	}
+
+	return text.String()
+}
+
+// Token returns the keyword for the token byte, or the byte value in braces
+// when it is not a token of this BASIC version.
+func Token(c byte, version Version) string {
+	switch {
+	case c >= 0x80 && int(c) < 0x80+len(v2Tokens):
+		return v2Tokens[c-0x80]
+	case version != V2 && c >= 0xCC && int(c) < 0xCC+len(v35Tokens):
+		return v35Tokens[c-0xCC]
+	}
+	return fmt.Sprintf("{$%02x}", c)
+}
@@ -0,0 +1,85 @@
+package basic
+
+// BASIC v2 tokens, as used by the C64 and VIC-20. Tokens start at $80, and
+// $FF is the pi character.
+var v2Tokens = []string{
+	"END", "FOR", "NEXT", "DATA", "INPUT#", "INPUT", "DIM", "READ",
+	"LET", "GOTO", "RUN", "IF", "RESTORE", "GOSUB", "RETURN", "REM",
+	"STOP", "ON", "WAIT", "LOAD", "SAVE", "VERIFY", "DEF", "POKE",
+	"PRINT#", "PRINT", "CONT", "LIST", "CLR", "CMD", "SYS", "OPEN",
+	"CLOSE", "GET", "NEW", "TAB(", "TO", "FN", "SPC(", "THEN",
+	"NOT", "STEP", "+", "-", "*", "/", "^", "AND",
+	"OR", ">", "=", "<", "SGN", "INT", "ABS", "USR",
+	"FRE", "POS", "SQR", "RND", "LOG", "EXP", "COS", "SIN",
+	"TAN", "ATN", "PEEK", "LEN", "STR$", "VAL", "ASC", "CHR$",
+	"LEFT$", "RIGHT$", "MID$", "GO",
+}
+
+// BASIC v3.5 tokens of the C16 and Plus/4, following on from the v2 tokens
+// at $CC.
+var v35Tokens = []string{
+	"RGR", "RCLR", "RLUM", "JOY", "RDOT", "DEC", "HEX$", "ERR$",
+	"INSTR", "ELSE", "RESUME", "TRAP", "TRON", "TROFF", "SOUND", "VOL",
+	"AUTO", "PUDEF", "GRAPHIC", "PAINT", "CHAR", "BOX", "CIRCLE", "GSHAPE",
+	"SSHAPE", "DRAW", "LOCATE", "COLOR", "SCNCLR", "SCALE", "HELP", "DO",
+	"LOOP", "EXIT", "DIRECTORY", "DSAVE", "DLOAD", "HEADER", "SCRATCH", "COLLECT",
+	"COPY", "RENAME", "BACKUP", "DELETE", "RENUMBER", "KEY", "MONITOR", "USING",
+	"UNTIL", "WHILE",
+}
+
+// BASIC v7 of the C128 uses the v3.5 tokens, except $CE and $FE, which are
+// prefixes for a second token byte.
+const (
+	v7PrefixCE = 0xCE
+	v7PrefixFE = 0xFE
+)
+
+var v7TokensCE = map[byte]string{
+	0x02: "POT",
+	0x03: "BUMP",
+	0x04: "PEN",
+	0x05: "RSPPOS",
+	0x06: "RSPRITE",
+	0x07: "RSPCOLOR",
+	0x08: "XOR",
+	0x09: "RWINDOW",
+	0x0A: "POINTER",
+}
+
+var v7TokensFE = map[byte]string{
+	0x02: "BANK",
+	0x03: "FILTER",
+	0x04: "PLAY",
+	0x05: "TEMPO",
+	0x06: "MOVSPR",
+	0x07: "SPRITE",
+	0x08: "SPRCOLOR",
+	0x09: "RREG",
+	0x0A: "ENVELOPE",
+	0x0B: "SLEEP",
+	0x0C: "CATALOG",
+	0x0D: "DOPEN",
+	0x0E: "APPEND",
+	0x0F: "DCLOSE",
+	0x10: "BSAVE",
+	0x11: "BLOAD",
+	0x12: "RECORD",
+	0x13: "CONCAT",
+	0x14: "DVERIFY",
+	0x15: "DCLEAR",
+	0x16: "SPRSAV",
+	0x17: "COLLISION",
+	0x18: "BEGIN",
+	0x19: "BEND",
+	0x1A: "WINDOW",
+	0x1B: "BOOT",
+	0x1C: "WIDTH",
+	0x1D: "SPRDEF",
+	0x1E: "QUIT",
+	0x1F: "STASH",
+	0x21: "FETCH",
+	0x23: "SWAP",
+	0x24: "OFF",
+	0x25: "FAST",
+	0x26: "SLOW",
+}
@@ -0,0 +1,333 @@
+// Package cmdfd implements reading of the D1M, D2M and D4M disk images of the
+// CMD FD-2000 and FD-4000 drives.
+//
+// The images are a copy of the whole disk, with 81 tracks of 256 byte
+// sectors, the number of sectors depending on the density of the disk:
+//
+//	D1M:  40 sectors per track,  829440 bytes (DD, 1581 compatible)
+//	D2M:  80 sectors per track, 1658880 bytes (HD)
+//	D4M: 160 sectors per track, 3317760 bytes (ED)
+//
+// The disk is divided into partitions, listed in the system partition on
+// the last track. The partition directory is made up of 32 byte entries,
+// with the first entry being the SYSTEM partition itself:
+//
+//	$00-$01: T/S link to the next partition directory sector (first entry only)
+//	$02:     partition type
+//	$03-$04: unused
+//	$05-$14: partition name, padded with $A0
+//	$15-$17: start of the partition, in 512 byte blocks (high/mid/low)
+//	$18-$1C: reserved
+//	$1D-$1F: size of the partition, in 512 byte blocks (high/mid/low)
+//
+// Native partitions are laid out as a DNP image, emulation partitions as the
+// image of the emulated drive.
+package cmdfd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/dnp"
+	"github.com/mrcook/retroio/commodore/petscii"
+	"github.com/mrcook/retroio/storage"
+)
+
+const (
+	tracks           = 81
+	blockSize        = 512
+	entriesPerSector = 8
+	partitionSectors = 4 // 31 partitions, plus the system partition
+	systemPartition  = 0xFF
+	nativePartition  = 1
+)
+
+// PartitionTypes are the names of the partition types, as shown in the
+// partition directory listing.
+var PartitionTypes = map[uint8]string{
+	0:    "EMPTY",
+	1:    "NATIVE",
+	2:    "1541",
+	3:    "1571",
+	4:    "1581",
+	5:    "1581 CP/M",
+	6:    "PRINT BUFFER",
+	7:    "FOREIGN",
+	0xFF: "SYSTEM",
+}
+
+// layout of each of the disk image types
+type layout struct {
+	mediaType       commodore.MediaType
+	diskSize        int
+	sectorsPerTrack int
+	description     string
+}
+
+var layouts = []layout{
+	{commodore.D1M, 829440, 40, "CMD FD-2000 DD (D1M)"},
+	{commodore.D2M, 1658880, 80, "CMD FD-2000 HD (D2M)"},
+	{commodore.D4M, 3317760, 160, "CMD FD-4000 ED (D4M)"},
+}
+
+// Partition is an entry of the partition directory.
+type Partition struct {
+	NextTrack  uint8
+	NextSector uint8
+
+	Type uint8
+
+	Unused [2]uint8
+
+	// 16 character partition name (padded with $A0)
+	Name [16]byte
+
+	Start [3]uint8 // big endian, in 512 byte blocks
+
+	Reserved [5]uint8
+
+	Size [3]uint8 // big endian, in 512 byte blocks
+}
+
+// StartBlock returns the first 512 byte block of the partition.
+func (p Partition) StartBlock() int {
+	return int(p.Start[0])<<16 | int(p.Start[1])<<8 | int(p.Start[2])
+}
+
+// Blocks returns the size of the partition in 512 byte blocks.
+func (p Partition) Blocks() int {
+	return int(p.Size[0])<<16 | int(p.Size[1])<<8 | int(p.Size[2])
+}
+
+// TypeName returns the name of the partition type.
+func (p Partition) TypeName() string {
+	if name, ok := PartitionTypes[p.Type]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN ($%02X)", p.Type)
+}
+
+// PrintableName returns the partition name, up to the first $A0 padding
+// character, using the PETSCII character set.
+func (p Partition) PrintableName(cs petscii.Charset) string {
+	return petscii.Filename(p.Name[:], cs)
+}
+
+// CMDFD is a D1M, D2M or D4M disk image.
+type CMDFD struct {
+	reader *storage.Reader
+	layout layout
+
+	image      []byte
+	Partitions []Partition // indexed by partition number, 0 being the system partition
+
+	number  int         // the selected partition, 0 for the partition directory
+	native  *dnp.Native // the selected native partition
+	charset petscii.Charset
+}
+
+func New(reader *storage.Reader, mediaType commodore.MediaType, diskSize uint32) (*CMDFD, error) {
+	for _, l := range layouts {
+		if l.mediaType == mediaType && l.diskSize == int(diskSize) {
+			return &CMDFD{reader: reader, layout: l}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid file - unexpected file size")
+}
+
+func (c *CMDFD) Read() error {
+	c.image = make([]byte, c.reader.FileSize)
+	if _, err := c.reader.Read(c.image); err != nil {
+		return err
+	}
+
+	return c.readPartitions()
+}
+
+// readPartitions finds the partition directory on the system track, by
+// looking for the SYSTEM partition, which is always the first entry. Sectors
+// which only look like the start of a directory, such as filler sectors of
+// $FF bytes, are skipped by checking the entries of the directory.
+func (c *CMDFD) readPartitions() error {
+	trackSize := c.layout.sectorsPerTrack * 256
+	systemTrack := c.image[(tracks-1)*trackSize:]
+
+	for s := 0; s+partitionSectors <= c.layout.sectorsPerTrack; s++ {
+		if systemTrack[s*256+2] != systemPartition {
+			continue
+		}
+
+		entries := make([]Partition, partitionSectors*entriesPerSector)
+		reader := bytes.NewReader(systemTrack[s*256 : (s+partitionSectors)*256])
+		if err := binary.Read(reader, binary.LittleEndian, entries); err != nil {
+			return fmt.Errorf("error reading the partition directory: %w", err)
+		}
+		if !c.validDirectory(entries) {
+			continue
+		}
+		c.Partitions = entries
+		return nil
+	}
+
+	return fmt.Errorf("partition directory not found on the system track")
+}
+
+// validDirectory reports whether the entries are a partition directory: the
+// first a named SYSTEM partition, and the others empty or of a known type,
+// with every partition inside the disk.
+func (c CMDFD) validDirectory(entries []Partition) bool {
+	diskBlocks := len(c.image) / blockSize
+
+	for i, p := range entries {
+		if _, ok := PartitionTypes[p.Type]; !ok || (i == 0) != (p.Type == systemPartition) {
+			return false
+		}
+		if p.Type == 0 {
+			continue
+		}
+		if p.StartBlock()+p.Blocks() > diskBlocks {
+			return false
+		}
+		if i > 0 && p.Blocks() == 0 {
+			return false
+		}
+	}
+
+	return validName(entries[0].Name[:])
+}
+
+// validName reports whether the partition name is made of printable PETSCII
+// characters, padded with $A0.
+func validName(name []byte) bool {
+	name = bytes.SplitN(name, []byte{0xA0}, 2)[0]
+	if len(name) == 0 {
+		return false
+	}
+	for _, c := range name {
+		if _, ok := petscii.Rune(c, petscii.Upper); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// SetCharset sets the PETSCII character set used to display names.
+func (c *CMDFD) SetCharset(cs petscii.Charset) {
+	c.charset = cs
+}
+
+// SetPath selects a partition by its number or name, followed by the path
+// of a sub-directory within a native partition, e.g. "1/" or "GAMES/ARCADE/".
+// An empty path selects the partition directory.
+func (c *CMDFD) SetPath(path string) error {
+	parts := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+	if parts[0] == "" {
+		c.number, c.native = 0, nil
+		return nil
+	}
+
+	number, err := c.partitionNumber(parts[0])
+	if err != nil {
+		return err
+	}
+	p := c.Partitions[number]
+	if p.Type != nativePartition {
+		return fmt.Errorf("%s: %s partitions are not supported", parts[0], p.TypeName())
+	}
+
+	start := p.StartBlock() * blockSize
+	end := start + p.Blocks()*blockSize
+	if end > len(c.image) {
+		return fmt.Errorf("%s: partition is outside of the disk", parts[0])
+	}
+	native, err := dnp.NewNative(c.image[start:end])
+	if err != nil {
+		return fmt.Errorf("%s: %w", parts[0], err)
+	}
+	if len(parts) > 1 {
+		if err := native.SetPath(parts[1]); err != nil {
+			return err
+		}
+	}
+
+	c.number, c.native = number, native
+	return nil
+}
+
+// partitionNumber finds the partition by its number, or its name.
+func (c CMDFD) partitionNumber(name string) (int, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n >= len(c.Partitions) || c.Partitions[n].Type == 0 {
+			return 0, fmt.Errorf("partition not found: %d", n)
+		}
+		return n, nil
+	}
+	for i, p := range c.Partitions {
+		if i > 0 && p.Type != 0 && strings.EqualFold(p.PrintableName(petscii.Upper), name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("partition not found: %s", name)
+}
+
+func (c CMDFD) DisplayGeometry() {
+	fmt.Println("DISK INFORMATION:")
+	fmt.Println()
+	fmt.Printf("Type:        %s\n", c.layout.description)
+	fmt.Printf("Size:        %.2fKB\n", float64(len(c.image))/1024)
+	fmt.Printf("Tracks:      %d\n", tracks)
+	fmt.Printf("Sectors:     %d\n", tracks*c.layout.sectorsPerTrack)
+	fmt.Println()
+
+	fmt.Println("PARTITIONS:")
+	fmt.Println()
+	for i, p := range c.Partitions {
+		if p.Type == 0 {
+			continue
+		}
+		fmt.Printf("  %-3d %-18s %-12s start %-6d %d blocks\n", i, fmt.Sprintf("\"%s\"", p.PrintableName(c.charset)), p.TypeName(), p.StartBlock(), p.Blocks())
+	}
+	fmt.Println()
+
+	if c.native != nil {
+		fmt.Printf("PARTITION %d:\n", c.number)
+		fmt.Println()
+		c.native.DisplayGeometry(c.charset)
+		fmt.Println()
+	}
+}
+
+// CommandDir prints the partition directory, or the directory of the
+// selected native partition.
+func (c CMDFD) CommandDir() {
+	if c.native != nil {
+		fmt.Printf("LOAD\"$%d:\",8\n", c.number)
+		fmt.Printf("SEARCHING FOR $%d:\n", c.number)
+		fmt.Println("LOADING")
+		fmt.Println("READY.")
+		fmt.Println("LIST")
+		fmt.Println()
+		c.native.CommandDir(c.charset)
+		fmt.Println()
+		return
+	}
+
+	fmt.Println("LOAD\"$=P\",8")
+	fmt.Println("SEARCHING FOR $=P")
+	fmt.Println("LOADING")
+	fmt.Println("READY.")
+	fmt.Println("LIST")
+	fmt.Println()
+	for i, p := range c.Partitions {
+		if p.Type == 0 || p.Type == systemPartition {
+			continue
+		}
+		name := fmt.Sprintf("\"%s\"", p.PrintableName(c.charset))
+		fmt.Printf("%-5d  %-18s %s\n", i, name, p.TypeName())
+	}
+	fmt.Println()
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/petscii"
 )
 
 const (
@@ -196,14 +197,14 @@ func (b *BlockAvailabilityMap) Read(sector disk.Sector) error {
 	return nil
 }
 
-func (b BlockAvailabilityMap) PrintableDiskName() []byte {
-	name := b.DiskName
-	for i, c := range name {
-		if c == 0xA0 {
-			name[i] = ' '
-		}
-	}
-	return name[:]
+// PrintableDiskName returns the disk name, with the $A0 padding shown as spaces.
+func (b BlockAvailabilityMap) PrintableDiskName(cs petscii.Charset) string {
+	return petscii.Padded(b.DiskName[:], cs)
+}
+
+// PrintableDiskID returns the disk ID using the PETSCII character set.
+func (b BlockAvailabilityMap) PrintableDiskID(cs petscii.Charset) string {
+	return petscii.Padded(b.DiskID[:], cs)
 }
 
 func (b BlockAvailabilityMap) DosTypeDescription() string {
@@ -0,0 +1,70 @@
+package d64
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/disk"
+)
+
+// Format creates a blank 35 track D64 disk, as done by the 1541 with the
+// `NEW` command: `OPEN 15,8,15,"N:NAME,ID"`.
+func Format(name, id string) (*disk.Disk, error) {
+	dsk, err := disk.NewBlank(commodore.D64)
+	if err != nil {
+		return nil, err
+	}
+
+	bam, err := NewBlockAvailabilityMap(name, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := FormatDirectory(dsk, bam); err != nil {
+		return nil, err
+	}
+
+	return dsk, nil
+}
+
+// NewBlockAvailabilityMap returns the BAM sector of a newly formatted disk.
+// The track entries are set when the BAM is rebuilt.
+func NewBlockAvailabilityMap(name, id string) (BlockAvailabilityMap, error) {
+	bam := BlockAvailabilityMap{
+		FirstDirTrack:  DirectoryTrackNumber + 1,
+		FirstDirSector: 1,
+		DiskDosVersion: 'A',
+		Filler1:        [2]uint8{0xA0, 0xA0},
+		Unknown:        0xA0,
+		DosVersion:     '2',
+		DiskVersion:    'A',
+		Filler2:        [4]uint8{0xA0, 0xA0, 0xA0, 0xA0},
+	}
+
+	diskName, err := disk.PETSCIIName(name)
+	if err != nil {
+		return bam, fmt.Errorf("disk name: %w", err)
+	}
+	bam.DiskName = diskName
+
+	diskID, err := disk.PETSCIIName(id)
+	if err != nil || len(id) > 2 {
+		return bam, fmt.Errorf("disk ID must be 1-2 characters: '%s'", id)
+	}
+	copy(bam.DiskID[:], diskID[:2])
+
+	return bam, nil
+}
+
+// FormatDirectory writes the BAM and an empty directory sector to track 18,
+// then rebuilds the BAM entries for every track.
+func FormatDirectory(dsk *disk.Disk, bam BlockAvailabilityMap) error {
+	if err := dsk.WriteSector(disk.Location{Track: DirectoryTrackNumber + 1, Sector: 0}, bam); err != nil {
+		return err
+	}
+	if err := dsk.WriteSector(disk.Location{Track: DirectoryTrackNumber + 1, Sector: 1}, []uint8{0x00, 0xFF}); err != nil {
+		return err
+	}
+
+	_, err := dsk.RebuildBAM()
+	return err
+}
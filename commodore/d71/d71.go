@@ -16,12 +16,14 @@ import (
 	"github.com/mrcook/retroio/commodore"
 	"github.com/mrcook/retroio/commodore/d64"
 	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/petscii"
 	"github.com/mrcook/retroio/storage"
 )
 
 type D71 struct {
-	disk *disk.Disk
-	cbm  *Directory
+	disk    *disk.Disk
+	cbm     *Directory
+	charset petscii.Charset
 }
 
 func New(reader *storage.Reader, diskSize uint32) (*D71, error) {
@@ -46,6 +48,12 @@ func (d *D71) Read() error {
 	return nil
 }
 
+// SetCharset sets the PETSCII character set used to display names.
+func (d *D71) SetCharset(cs petscii.Charset) {
+	d.charset = cs
+	d.disk.SetCharset(cs)
+}
+
 func (d D71) DisplayGeometry() {
 	totalSectorCount := 0
 	for _, t := range d.disk.Tracks {
@@ -60,10 +68,22 @@ func (d D71) DisplayGeometry() {
 	fmt.Printf("Tracks:      %d\n", len(d.disk.Tracks))
 	fmt.Printf("Sectors:     %d\n", totalSectorCount)
 	fmt.Println()
-	fmt.Printf("Name:        %s\n", d.cbm.bam.PrintableDiskName())
+	fmt.Printf("Name:        %s\n", d.cbm.bam.PrintableDiskName(d.charset))
 	fmt.Printf("Files:       %d\n", len(d.cbm.directories))
 	fmt.Printf("Free Blocks: %d\n", d.freeBlocks())
+	if geos := d.disk.GEOSSignature(); geos != "" {
+		fmt.Printf("GEOS:        %s\n", geos)
+	}
 	fmt.Println()
+
+	if errs := d.disk.SectorErrors(); len(errs) > 0 {
+		fmt.Println("SECTOR ERRORS:")
+		fmt.Println()
+		for _, e := range errs {
+			fmt.Printf("  %s\n", e)
+		}
+		fmt.Println()
+	}
 }
 
 func (d D71) CommandDir() {
@@ -73,10 +93,14 @@ func (d D71) CommandDir() {
 	fmt.Println("READY.")
 	fmt.Println("LIST")
 	fmt.Println()
-	fmt.Printf("0 \"%-16s\" %s %c%c\n", d.cbm.bam.PrintableDiskName(), d.cbm.bam.DiskID, d.cbm.bam.DosVersion, d.cbm.bam.DiskVersion)
+	fmt.Printf("0 \"%-16s\" %s %c%c\n", d.cbm.bam.PrintableDiskName(d.charset), d.cbm.bam.PrintableDiskID(d.charset), d.cbm.bam.DosVersion, d.cbm.bam.DiskVersion)
 
 	for _, dir := range d.cbm.directories {
-		filename := fmt.Sprintf("\"%s\"", dir.Filename)
+		filename := fmt.Sprintf("\"%s\"", dir.DirEntry.Name(d.charset))
+		if geos := d.disk.GEOSDescription(dir.DirEntry); geos != "" {
+			fmt.Printf("%-3d  %-18s %s  %s\n", dir.DirEntry.FileSizeInSectors, filename, dir.FileType, geos)
+			continue
+		}
 		fmt.Printf("%-3d  %-18s %s\n", dir.DirEntry.FileSizeInSectors, filename, dir.FileType)
 	}
 
@@ -84,6 +108,22 @@ func (d D71) CommandDir() {
 	fmt.Println()
 }
 
+// ExtractFiles reads the PRG, SEQ, USR and REL files by following their
+// track/sector chains.
+func (d D71) ExtractFiles() []commodore.File {
+	var files []commodore.File
+	for _, dir := range d.cbm.directories {
+		if dir.DirEntry.IsGEOS() {
+			files = append(files, d.disk.ReadGEOSFile(dir.DirEntry))
+		} else if dir.DirEntry.Extractable() {
+			files = append(files, d.disk.ReadFile(dir.DirEntry))
+		} else if dir.DirEntry.IsRel() {
+			files = append(files, d.disk.ReadRelFile(dir.DirEntry))
+		}
+	}
+	return files
+}
+
 func (d D71) freeBlocks() int {
 	freeSectors := 0
 	for i, b := range d.cbm.bam.Entries {
@@ -0,0 +1,29 @@
+package d71
+
+import (
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/d64"
+	"github.com/mrcook/retroio/commodore/disk"
+)
+
+// Format creates a blank double-sided D71 disk, as done by the 1571 with the
+// `NEW` command. Track 53 holds the BAM bitmaps for side 2, and is left
+// fully allocated.
+func Format(name, id string) (*disk.Disk, error) {
+	dsk, err := disk.NewBlank(commodore.D71)
+	if err != nil {
+		return nil, err
+	}
+
+	bam, err := d64.NewBlockAvailabilityMap(name, id)
+	if err != nil {
+		return nil, err
+	}
+	bam.NumberOfSidesFlag = 0x80
+
+	if err := d64.FormatDirectory(dsk, bam); err != nil {
+		return nil, err
+	}
+
+	return dsk, nil
+}
@@ -0,0 +1,129 @@
+// Package d80 implements reading Commodore D80 and D82 image files.
+//
+// The D80 is a sector-for-sector copy of a CBM 8050 disk, a single-sided
+// drive for the PET/CBM computers. The disk has 77 tracks, in four speed
+// zones of 29, 27, 25 and 23 sectors, making a DOS file size of 533248 bytes.
+// If the error byte block (2083 bytes) is attached, the file size will be
+// 535331 bytes.
+//
+// The D82 is a copy of a double-sided CBM 8250 (or SFD-1001) disk, with the
+// 77 tracks of the second side numbered 78-154, for a file size of 1066496
+// bytes, or 1070662 bytes with the error bytes (4166 bytes) attached.
+//
+// Additional D80/D82 documentation can be found in the `docs.md` file.
+package d80
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/petscii"
+	"github.com/mrcook/retroio/storage"
+)
+
+type D80 struct {
+	disk    *disk.Disk
+	cbm     *Directory
+	charset petscii.Charset
+}
+
+// New initializes a D80 or D82 disk, as given by the media type.
+func New(reader *storage.Reader, mediaType commodore.MediaType, diskSize uint32) (*D80, error) {
+	if mediaType != commodore.D80 && mediaType != commodore.D82 {
+		return nil, fmt.Errorf("invalid media type #%d for a D80/D82 disk", mediaType)
+	}
+
+	dsk, err := disk.New(reader, mediaType, diskSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &D80{disk: dsk}, nil
+}
+
+func (d *D80) Read() error {
+	if err := d.disk.Read(); err != nil {
+		return fmt.Errorf("error reading the disk: %w", err)
+	}
+
+	d.cbm = newDirectory(d.disk)
+	if err := d.cbm.Read(); err != nil {
+		return fmt.Errorf("error reading the directory: %w", err)
+	}
+
+	return nil
+}
+
+// SetCharset sets the PETSCII character set used to display names.
+func (d *D80) SetCharset(cs petscii.Charset) {
+	d.charset = cs
+}
+
+func (d D80) DisplayGeometry() {
+	totalSectorCount := 0
+	for _, t := range d.disk.Tracks {
+		totalSectorCount += len(t.Sectors)
+	}
+
+	fmt.Println("DISK INFORMATION:")
+	fmt.Println()
+	fmt.Printf("Type:        %s\n", d.disk.DiskType())
+	fmt.Printf("DOS Type:    %s\n", d.cbm.header.DosTypeDescription())
+	fmt.Printf("Size:        %.2fKB\n", d.disk.DiskSizeInKB())
+	fmt.Printf("Tracks:      %d\n", len(d.disk.Tracks))
+	fmt.Printf("Sectors:     %d\n", totalSectorCount)
+	fmt.Println()
+	fmt.Printf("Name:        %s\n", d.cbm.header.PrintableDiskName(d.charset))
+	fmt.Printf("Files:       %d\n", len(d.cbm.directories))
+	fmt.Printf("Free Blocks: %d\n", d.freeBlocks())
+	fmt.Println()
+
+	fmt.Println("BAM SECTORS:")
+	fmt.Println()
+	for i, b := range d.cbm.bams {
+		fmt.Printf("  %d/%-2d tracks %d-%d\n", BAMTrackNumber+1, i*BAMSectorInterleave, b.LowTrack, int(b.HighTrack)-1)
+	}
+	fmt.Println()
+
+	if errs := d.disk.SectorErrors(); len(errs) > 0 {
+		fmt.Println("SECTOR ERRORS:")
+		fmt.Println()
+		for _, e := range errs {
+			fmt.Printf("  %s\n", e)
+		}
+		fmt.Println()
+	}
+}
+
+func (d D80) CommandDir() {
+	fmt.Println("LOAD\"$0\",8")
+	fmt.Println("SEARCHING FOR $0")
+	fmt.Println("LOADING")
+	fmt.Println("READY.")
+	fmt.Println("LIST")
+	fmt.Println()
+	fmt.Printf("0 \"%-16s\" %s %c%c\n", d.cbm.header.PrintableDiskName(d.charset), d.cbm.header.PrintableDiskID(d.charset), d.cbm.header.DosVersion, d.cbm.header.DiskVersion)
+
+	for _, dir := range d.cbm.directories {
+		filename := fmt.Sprintf("\"%s\"", dir.DirEntry.Name(d.charset))
+		fmt.Printf("%-4d  %-18s %s\n", dir.DirEntry.FileSizeInSectors, filename, dir.FileType)
+	}
+
+	fmt.Printf("%d BLOCKS FREE.\n", d.freeBlocks())
+	fmt.Println()
+}
+
+func (d D80) freeBlocks() int {
+	freeSectors := 0
+	for _, b := range d.cbm.bams {
+		for i := 0; i < int(b.HighTrack)-int(b.LowTrack) && i < len(b.Entries); i++ {
+			// like the 8050, don't count the directory track
+			if int(b.LowTrack)+i == DirectoryTrackNumber+1 {
+				continue
+			}
+			freeSectors += int(b.Entries[i].FreeSectors)
+		}
+	}
+	return freeSectors
+}
@@ -0,0 +1,232 @@
+// D80/D82 Directory
+//
+// The header of a D80/D82 disk is on track 39, sector 0, and holds the disk
+// name and ID. The directory follows on the same track, starting at 39/1,
+// using a sector interleave of 3 (39/1, 39/4, 39/7 etc.), with each sector
+// holding eight entries.
+//
+// Unlike the other Commodore disks, the BAM is not stored on the directory
+// track, but on track 38, where each BAM sector manages 50 tracks:
+//
+//	D80: 38/0 for tracks 1-50, and 38/3 for tracks 51-77
+//	D82: 38/0, 38/3, 38/6 and 38/9, for tracks 1-50, 51-100, 101-150
+//	     and 151-154
+//
+// The BAM sectors are chained together, with the last sector linking to the
+// first directory sector.
+package d80
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/petscii"
+)
+
+const (
+	DirectoryTrackNumber = 39 - 1 // tracks count from 1
+	BAMTrackNumber       = 38 - 1
+	BAMSectorInterleave  = 3
+	TracksPerBAMSector   = 50
+	DirEntriesPerSector  = 8
+)
+
+// Directory represents a D80/D82 directory as stored on a disk.
+type Directory struct {
+	disk *disk.Disk
+
+	header      Header
+	bams        []BlockAvailabilityMap
+	directories []DirectoryFile
+}
+
+// DirectoryFile contains metadata for each entry, along with the 32-byte
+// directory file entry found on the disk.
+type DirectoryFile struct {
+	Track  uint8
+	Sector uint8
+
+	Filename string
+	FileType disk.FileType
+
+	DirEntry disk.DirectoryFile
+}
+
+func newDirectory(dsk *disk.Disk) *Directory {
+	return &Directory{disk: dsk}
+}
+
+func (d *Directory) Read() error {
+	d.header = Header{}
+	if err := d.header.Read(d.disk.Tracks[DirectoryTrackNumber].Sectors[0]); err != nil {
+		return fmt.Errorf("error reading Header: %w", err)
+	}
+
+	// Don't trust the BAM chain, the number of BAM sectors is fixed by the
+	// number of tracks on the disk.
+	d.bams = nil
+	bamSectors := (len(d.disk.Tracks) + TracksPerBAMSector - 1) / TracksPerBAMSector
+	for i := 0; i < bamSectors; i++ {
+		bam := BlockAvailabilityMap{}
+		if err := bam.Read(d.disk.Tracks[BAMTrackNumber].Sectors[i*BAMSectorInterleave]); err != nil {
+			return fmt.Errorf("error reading BAM sector %d: %w", i*BAMSectorInterleave, err)
+		}
+		d.bams = append(d.bams, bam)
+	}
+
+	if err := d.readDirectory(); err != nil {
+		return fmt.Errorf("directory track error: %w", err)
+	}
+
+	return nil
+}
+
+// Read all 32-byte directory entries, following the T/S chain.
+func (d *Directory) readDirectory() error {
+	// Header is in sector[0], dirs start at sector #1
+	var startSector uint8 = 1
+
+	for t := DirectoryTrackNumber; t < len(d.disk.Tracks); {
+		sectors := d.disk.Tracks[t].Sectors
+
+		// Read all directories from each allocated sector
+		for s := startSector; s < uint8(len(sectors)); {
+			reader := bytes.NewReader(sectors[s][:])
+			entries := make([]disk.DirectoryFile, DirEntriesPerSector)
+			if err := binary.Read(reader, binary.LittleEndian, entries); err != nil {
+				return fmt.Errorf("error reading directory entry from sector: %w", err)
+			}
+
+			for _, dir := range entries {
+				if dir.FileType == 0 && dir.FileSizeInSectors == 0 {
+					continue
+				}
+
+				entry := DirectoryFile{
+					Track:    uint8(t),
+					Sector:   s,
+					Filename: dir.PrintableFilename(),
+					FileType: dir.FileTypeFromID(),
+					DirEntry: dir,
+				}
+				d.directories = append(d.directories, entry)
+			}
+
+			// Fetch the next sector to jump to
+			s = entries[0].NextSector
+
+			// that was the last sector, stop reading the directory
+			if s == 0xFF {
+				return nil
+			}
+
+			nextTrack := int(entries[0].NextTrack)
+			if nextTrack == 0x00 {
+				return nil
+			}
+
+			// If a multi-track directory disk, change tracks
+			if t != nextTrack-1 {
+				startSector = s
+				t = nextTrack - 1
+				break // break the sector reading loop
+			}
+		}
+	}
+
+	return nil
+}
+
+// Header Sector
+//
+// The header sector is stored at 39/0, and contains the disk name, ID and DOS
+// version bytes. The layout is similar to the D81 header.
+type Header struct {
+	// Track/Sector location of the first BAM sector, 38/0
+	FirstBamTrack  uint8
+	FirstBamSector uint8
+
+	// Disk DOS version (see `docs.md` for more information)
+	DiskDosVersion byte // Usually 'C' ($43)
+
+	Reserved uint8 // ($00)
+
+	Unused1 [2]uint8 // ($00)
+
+	// 16 character Disk Name (padded with $A0)
+	DiskName [16]byte
+
+	Filler1 [2]uint8 // Filled with $A0
+
+	DiskID [2]uint8
+
+	Unknown uint8 // Usually $A0
+
+	// see `docs.md` for more information
+	DosVersion  byte // "2"
+	DiskVersion byte // "C"
+
+	Filler2 [4]uint8 // Filled with $A0
+
+	Unused2 [223]uint8 // Unused (usually $00)
+}
+
+func (h *Header) Read(sector disk.Sector) error {
+	reader := bytes.NewReader(sector[:])
+	if err := binary.Read(reader, binary.LittleEndian, h); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (h Header) DosTypeDescription() string {
+	dosType := fmt.Sprintf("%c%c", h.DosVersion, h.DiskVersion)
+	return disk.DosTypes[dosType]
+}
+
+// PrintableDiskName returns the disk name, with the $A0 padding shown as spaces.
+func (h Header) PrintableDiskName(cs petscii.Charset) string {
+	return petscii.Padded(h.DiskName[:], cs)
+}
+
+// PrintableDiskID returns the disk ID using the PETSCII character set.
+func (h Header) PrintableDiskID(cs petscii.Charset) string {
+	return petscii.Padded(h.DiskID[:], cs)
+}
+
+// BAM Layout for the D80/D82
+//
+// Each BAM sector manages a range of up to 50 tracks, with five bytes for
+// each track: the free sector count, and four bytes of allocation bitmap, as
+// the tracks have at most 29 sectors.
+type BlockAvailabilityMap struct {
+	// Track/Sector location of the next BAM sector, or for the last BAM
+	// sector, the first directory sector (39/1).
+	NextBamTrack  uint8
+	NextBamSector uint8
+
+	// Disk DOS version (see `docs.md` for more information)
+	DiskDosVersion uint8 // Usually 'C' ($43)
+
+	Reserved uint8 // ($00)
+
+	// The range of tracks managed by this BAM sector, the highest track
+	// being one more than the last track, e.g. 1 and 51.
+	LowTrack  uint8
+	HighTrack uint8
+
+	// BAM entries for each track in the range
+	Entries [TracksPerBAMSector]disk.BamEntry32Bit
+}
+
+func (b *BlockAvailabilityMap) Read(sector disk.Sector) error {
+	reader := bytes.NewReader(sector[:])
+	if err := binary.Read(reader, binary.LittleEndian, b); err != nil {
+		return err
+	}
+
+	return nil
+}
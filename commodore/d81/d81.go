@@ -12,12 +12,14 @@ import (
 
 	"github.com/mrcook/retroio/commodore"
 	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/petscii"
 	"github.com/mrcook/retroio/storage"
 )
 
 type D81 struct {
-	disk *disk.Disk
-	cbm  *Directory
+	disk    *disk.Disk
+	cbm     *Directory
+	charset petscii.Charset
 }
 
 func New(reader *storage.Reader, diskSize uint32) (*D81, error) {
@@ -34,7 +36,7 @@ func (d *D81) Read() error {
 		return fmt.Errorf("error reading the disk: %w", err)
 	}
 
-	d.cbm = newDirectory(d.disk)
+	d.cbm = newDirectory(d.disk, DirectoryTrackNumber)
 	if err := d.cbm.Read(); err != nil {
 		return fmt.Errorf("error reading the directory: %w", err)
 	}
@@ -42,6 +44,12 @@ func (d *D81) Read() error {
 	return nil
 }
 
+// SetCharset sets the PETSCII character set used to display names.
+func (d *D81) SetCharset(cs petscii.Charset) {
+	d.charset = cs
+	d.disk.SetCharset(cs)
+}
+
 func (d D81) DisplayGeometry() {
 	totalSectorCount := 0
 	for _, t := range d.disk.Tracks {
@@ -56,10 +64,22 @@ func (d D81) DisplayGeometry() {
 	fmt.Printf("Tracks:      %d\n", len(d.disk.Tracks))
 	fmt.Printf("Sectors:     %d\n", totalSectorCount)
 	fmt.Println()
-	fmt.Printf("Name:        %s\n", d.cbm.header.PrintableDiskName())
+	fmt.Printf("Name:        %s\n", d.cbm.header.PrintableDiskName(d.charset))
 	fmt.Printf("Files:       %d\n", len(d.cbm.directories))
 	fmt.Printf("Free Blocks: %d\n", d.freeBlocks())
+	if geos := d.disk.GEOSSignature(); geos != "" {
+		fmt.Printf("GEOS:        %s\n", geos)
+	}
 	fmt.Println()
+
+	if errs := d.disk.SectorErrors(); len(errs) > 0 {
+		fmt.Println("SECTOR ERRORS:")
+		fmt.Println()
+		for _, e := range errs {
+			fmt.Printf("  %s\n", e)
+		}
+		fmt.Println()
+	}
 }
 
 func (d D81) CommandDir() {
@@ -69,10 +89,14 @@ func (d D81) CommandDir() {
 	fmt.Println("READY.")
 	fmt.Println("LIST")
 	fmt.Println()
-	fmt.Printf("0 \"%-16s\" %s %c%c\n", d.cbm.header.PrintableDiskName(), d.cbm.header.DiskID, d.cbm.header.DosVersion, d.cbm.header.DiskVersion)
+	fmt.Printf("0 \"%-16s\" %s %c%c\n", d.cbm.header.PrintableDiskName(d.charset), d.cbm.header.PrintableDiskID(d.charset), d.cbm.header.DosVersion, d.cbm.header.DiskVersion)
 
 	for _, dir := range d.cbm.directories {
-		filename := fmt.Sprintf("\"%s\"", dir.Filename)
+		filename := fmt.Sprintf("\"%s\"", dir.DirEntry.Name(d.charset))
+		if geos := d.disk.GEOSDescription(dir.DirEntry); geos != "" {
+			fmt.Printf("%-3d  %-18s %s  %s\n", dir.DirEntry.FileSizeInSectors, filename, dir.FileType, geos)
+			continue
+		}
 		fmt.Printf("%-3d  %-18s %s\n", dir.DirEntry.FileSizeInSectors, filename, dir.FileType)
 	}
 
@@ -80,12 +104,30 @@ func (d D81) CommandDir() {
 	fmt.Println()
 }
 
+// ExtractFiles reads the PRG, SEQ, USR and REL files by following their
+// track/sector chains.
+func (d D81) ExtractFiles() []commodore.File {
+	var files []commodore.File
+	for _, dir := range d.cbm.directories {
+		if dir.DirEntry.IsGEOS() {
+			files = append(files, d.disk.ReadGEOSFile(dir.DirEntry))
+		} else if dir.DirEntry.Extractable() {
+			files = append(files, d.disk.ReadFile(dir.DirEntry))
+		} else if dir.DirEntry.IsRel() {
+			files = append(files, d.disk.ReadRelFile(dir.DirEntry))
+		}
+	}
+	return files
+}
+
 func (d D81) freeBlocks() int {
 	freeSectors := 0
-	for _, b := range d.cbm.bamSide1.Entries {
-		freeSectors += int(b.FreeSectors)
-	}
-	for _, b := range d.cbm.bamSide2.Entries {
+	entries := append(d.cbm.bamSide1.Entries[:], d.cbm.bamSide2.Entries[:]...)
+	for i, b := range entries {
+		// like the 1581, don't count the directory track
+		if i == d.cbm.track {
+			continue
+		}
 		freeSectors += int(b.FreeSectors)
 	}
 	return freeSectors
@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/petscii"
 )
 
 const (
@@ -28,7 +29,8 @@ const (
 // Directory represents a D81 directory as stored on a disk.
 // There are a maximum of 296 entries.
 type Directory struct {
-	disk *disk.Disk
+	disk  *disk.Disk
+	track int // system track index, 39 for the root directory
 
 	header      Header
 	bamSide1    BlockAvailabilityMap
@@ -36,23 +38,23 @@ type Directory struct {
 	directories []DirectoryFile
 }
 
-func newDirectory(dsk *disk.Disk) *Directory {
-	return &Directory{disk: dsk}
+func newDirectory(dsk *disk.Disk, track int) *Directory {
+	return &Directory{disk: dsk, track: track}
 }
 
 func (d *Directory) Read() error {
 	d.header = Header{}
-	if err := d.header.Read(d.disk.Tracks[DirectoryTrackNumber].Sectors[0]); err != nil {
+	if err := d.header.Read(d.disk.Tracks[d.track].Sectors[0]); err != nil {
 		return fmt.Errorf("error reading Header: %w", err)
 	}
 
 	d.bamSide1 = BlockAvailabilityMap{}
-	if err := d.bamSide1.Read(d.disk.Tracks[DirectoryTrackNumber].Sectors[1]); err != nil {
+	if err := d.bamSide1.Read(d.disk.Tracks[d.track].Sectors[1]); err != nil {
 		return fmt.Errorf("error reading Side 1 BAM: %w", err)
 	}
 
 	d.bamSide2 = BlockAvailabilityMap{}
-	if err := d.bamSide2.Read(d.disk.Tracks[DirectoryTrackNumber].Sectors[2]); err != nil {
+	if err := d.bamSide2.Read(d.disk.Tracks[d.track].Sectors[2]); err != nil {
 		return fmt.Errorf("error reading Side 2 BAM: %w", err)
 	}
 
@@ -69,7 +71,7 @@ func (d *Directory) readDirectory() error {
 	// the dirs start at sector #3
 	var startSector uint8 = 3
 
-	for t := DirectoryTrackNumber; t < len(d.disk.Tracks); {
+	for t := d.track; t < len(d.disk.Tracks); {
 		sectors := d.disk.Tracks[t].Sectors
 
 		// Read all directories from each allocated sector
@@ -181,14 +183,14 @@ func (h Header) DosTypeDescription() string {
 	return disk.DosTypes[dosType]
 }
 
-func (h Header) PrintableDiskName() []byte {
-	name := h.DiskName
-	for i, c := range name {
-		if c == 0xA0 {
-			name[i] = ' '
-		}
-	}
-	return name[:]
+// PrintableDiskName returns the disk name, with the $A0 padding shown as spaces.
+func (h Header) PrintableDiskName(cs petscii.Charset) string {
+	return petscii.Padded(h.DiskName[:], cs)
+}
+
+// PrintableDiskID returns the disk ID using the PETSCII character set.
+func (h Header) PrintableDiskID(cs petscii.Charset) string {
+	return petscii.Padded(h.DiskID[:], cs)
 }
 
 // BAM Layout for the D81
@@ -0,0 +1,66 @@
+package d81
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/disk"
+)
+
+// Format creates a blank D81 disk, as done by the 1581 with the `NEW`
+// command. Track 40 holds the header (sector 0), the BAM for each side
+// (sectors 1 and 2), and the first directory sector (sector 3).
+func Format(name, id string) (*disk.Disk, error) {
+	dsk, err := disk.NewBlank(commodore.D81)
+	if err != nil {
+		return nil, err
+	}
+
+	diskName, err := disk.PETSCIIName(name)
+	if err != nil {
+		return nil, fmt.Errorf("disk name: %w", err)
+	}
+	diskID, err := disk.PETSCIIName(id)
+	if err != nil || len(id) > 2 {
+		return nil, fmt.Errorf("disk ID must be 1-2 characters: '%s'", id)
+	}
+
+	header := Header{
+		FirstDirTrack:  DirectoryTrackNumber + 1,
+		FirstDirSector: 3,
+		DiskDosVersion: 'D',
+		DiskName:       diskName,
+		Filler1:        [2]uint8{0xA0, 0xA0},
+		DiskID:         [2]uint8{diskID[0], diskID[1]},
+		Unknown:        0xA0,
+		DosVersion:     '3',
+		DiskVersion:    'D',
+		Filler2:        [2]uint8{0xA0, 0xA0},
+	}
+
+	side1 := BlockAvailabilityMap{
+		NextBamTrack:           DirectoryTrackNumber + 1,
+		NextBamSector:          2,
+		DiskDosVersion:         'D',
+		DiskDosVersionInverted: 0xBB,
+		DiskId:                 header.DiskID,
+		IO:                     0xC0,
+	}
+	side2 := side1
+	side2.NextBamTrack = 0x00
+	side2.NextBamSector = 0xFF
+
+	track := uint8(DirectoryTrackNumber + 1)
+	sectors := []interface{}{header, side1, side2, []uint8{0x00, 0xFF}}
+	for s, value := range sectors {
+		if err := dsk.WriteSector(disk.Location{Track: track, Sector: uint8(s)}, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := dsk.RebuildBAM(); err != nil {
+		return nil, err
+	}
+
+	return dsk, nil
+}
@@ -0,0 +1,96 @@
+// D81 Partitions and Sub-directories
+//
+// The 1581 can reserve an area of the disk as a partition, which is listed in
+// the directory with the CBM file type. The entry gives the first sector of
+// the partition and its size in sectors.
+//
+// A partition can be used as a sub-directory when it:
+//
+//   - starts on sector 0 of a track,
+//   - is a multiple of 40 sectors (whole tracks) in size,
+//   - is at least 120 sectors (3 tracks) in size,
+//   - does not include the directory track of its parent.
+//
+// The first track of a sub-directory is laid out as the root directory track:
+// sector 0 is the header, sectors 1 and 2 are the BAM, and the directory
+// starts on sector 3. The BAM covers all 80 tracks, with the tracks outside
+// of the partition marked as allocated, so the blocks free are those of the
+// partition. Sub-directories may themselves contain partitions.
+package d81
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	partitionFileType = 5 // CBM
+	minPartitionSize  = 120
+)
+
+// SetPath changes to the sub-directory at the path, e.g. "GAMES/", or
+// "GAMES/ARCADE/" for a nested sub-directory, so that the directory listing
+// and extracted files are those of the sub-directory. An empty path, or "/",
+// is the root directory.
+func (d *D81) SetPath(path string) error {
+	dir := newDirectory(d.disk, DirectoryTrackNumber)
+	if err := dir.Read(); err != nil {
+		return fmt.Errorf("error reading the directory: %w", err)
+	}
+
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		sub, err := dir.subDirectory(name)
+		if err != nil {
+			return err
+		}
+		dir = sub
+	}
+
+	d.cbm = dir
+	return nil
+}
+
+// subDirectory returns the directory of the named partition.
+func (d *Directory) subDirectory(name string) (*Directory, error) {
+	var partition *DirectoryFile
+	for i, dir := range d.directories {
+		if strings.EqualFold(dir.Filename, name) {
+			partition = &d.directories[i]
+			break
+		}
+	}
+	if partition == nil {
+		return nil, fmt.Errorf("directory not found: %s", name)
+	}
+
+	entry := partition.DirEntry
+	if entry.FileType&0b00000111 != partitionFileType {
+		return nil, fmt.Errorf("%s is not a partition", name)
+	}
+
+	first := int(entry.FirstSectorLocation[0])
+	size := int(entry.FileSizeInSectors)
+	last := first + size/40 - 1
+
+	switch {
+	case entry.FirstSectorLocation[1] != 0, size%40 != 0, size < minPartitionSize:
+		return nil, fmt.Errorf("%s is a partition, but not a sub-directory", name)
+	case first <= d.track+1 && last >= d.track+1:
+		return nil, fmt.Errorf("%s: partition includes the directory track", name)
+	case first < 1 || last > len(d.disk.Tracks):
+		return nil, fmt.Errorf("%s: partition is outside of the disk", name)
+	}
+
+	sub := newDirectory(d.disk, first-1)
+	if err := sub.Read(); err != nil {
+		return nil, fmt.Errorf("%s: error reading the directory: %w", name, err)
+	}
+	if sub.bamSide1.DiskDosVersion != 'D' || sub.bamSide1.DiskDosVersionInverted != 0xBB {
+		return nil, fmt.Errorf("%s is a partition, but has not been formatted as a sub-directory", name)
+	}
+
+	return sub, nil
+}
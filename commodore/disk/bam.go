@@ -0,0 +1,156 @@
+package disk
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore"
+)
+
+// bamEntry returns the free sector count and the sector bitmap for a track,
+// as stored in the BAM sectors of the disk.
+//
+//	D64: 18/0, four bytes per track from offset $04
+//	D71: tracks 1-35 as the D64, tracks 36-70 have their free sector count
+//	     at 18/0 from offset $DD, and their bitmaps on 53/0
+//	D81: 40/1 for tracks 1-40, and 40/2 for tracks 41-80, six bytes per
+//	     track from offset $10
+func (d Disk) bamEntry(track uint8) (*uint8, []uint8, error) {
+	if track == 0 || track > d.dos().bamTracks || int(track) > len(d.Tracks) {
+		return nil, nil, fmt.Errorf("track %d is not managed by the BAM", track)
+	}
+
+	switch d.Variation.mediaType {
+	case commodore.D64, commodore.D71:
+		bam, err := d.Sector(18, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		if track <= 35 {
+			offset := 4 + int(track-1)*4
+			return &bam[offset], bam[offset+1 : offset+4], nil
+		}
+		extra, err := d.Sector(53, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		offset := int(track-36) * 3
+		return &bam[0xDD+int(track-36)], extra[offset : offset+3], nil
+	case commodore.D81:
+		bamSector := uint8(1)
+		if track > 40 {
+			bamSector = 2
+			track -= 40
+		}
+		bam, err := d.Sector(40, bamSector)
+		if err != nil {
+			return nil, nil, err
+		}
+		offset := 0x10 + int(track-1)*6
+		return &bam[offset], bam[offset+1 : offset+6], nil
+	}
+
+	return nil, nil, fmt.Errorf("no BAM layout for this disk type")
+}
+
+// IsFree checks the BAM to see if the sector is free.
+func (d Disk) IsFree(l Location) (bool, error) {
+	if _, err := d.Sector(l.Track, l.Sector); err != nil {
+		return false, err
+	}
+	_, bitmap, err := d.bamEntry(l.Track)
+	if err != nil {
+		return false, err
+	}
+	return !UsedSectorBitmap(l.Sector, bitmap), nil
+}
+
+// Allocate marks the sector as used in the BAM.
+func (d Disk) Allocate(l Location) error {
+	return d.setFree(l, false)
+}
+
+// Free marks the sector as unused in the BAM.
+func (d Disk) Free(l Location) error {
+	return d.setFree(l, true)
+}
+
+func (d Disk) setFree(l Location, free bool) error {
+	if _, err := d.Sector(l.Track, l.Sector); err != nil {
+		return err
+	}
+	count, bitmap, err := d.bamEntry(l.Track)
+	if err != nil {
+		return err
+	}
+
+	bit := uint8(1) << (l.Sector % 8)
+	isFree := bitmap[l.Sector/8]&bit > 0
+	switch {
+	case free && !isFree:
+		bitmap[l.Sector/8] |= bit
+		*count++
+	case !free && isFree:
+		bitmap[l.Sector/8] &^= bit
+		*count--
+	}
+	return nil
+}
+
+// BAMFreeCount returns the free sector count stored in the BAM for the track.
+func (d Disk) BAMFreeCount(track uint8) (int, error) {
+	count, _, err := d.bamEntry(track)
+	if err != nil {
+		return 0, err
+	}
+	return int(*count), nil
+}
+
+// FreeBlocks returns the number of free sectors in the BAM, not counting
+// the directory track, as reported by the DOS.
+func (d Disk) FreeBlocks() int {
+	free := 0
+	for track := uint8(1); track <= d.dos().bamTracks && int(track) <= len(d.Tracks); track++ {
+		if track == d.dos().directoryTrack {
+			continue
+		}
+		if count, err := d.BAMFreeCount(track); err == nil {
+			free += count
+		}
+	}
+	return free
+}
+
+// clearBAM marks every sector of the tracks managed by the BAM as free,
+// except for the reserved tracks. Bits for sectors which do not exist on a
+// track are left as allocated.
+func (d Disk) clearBAM() error {
+	for track := uint8(1); track <= d.dos().bamTracks && int(track) <= len(d.Tracks); track++ {
+		count, bitmap, err := d.bamEntry(track)
+		if err != nil {
+			return err
+		}
+		for i := range bitmap {
+			bitmap[i] = 0
+		}
+		*count = 0
+
+		if d.isReservedTrack(track) {
+			continue
+		}
+		for s := 0; s < len(d.Tracks[track-1].Sectors); s++ {
+			if err := d.Free(Location{Track: track, Sector: uint8(s)}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d Disk) isReservedTrack(track uint8) bool {
+	for _, t := range d.dos().reservedTracks {
+		if t == track {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package disk
+
+import (
+	"fmt"
+)
+
+// Location of a sector on the disk. Tracks count from 1, sectors from 0.
+type Location struct {
+	Track  uint8
+	Sector uint8
+}
+
+func (l Location) String() string {
+	return fmt.Sprintf("%d/%d", l.Track, l.Sector)
+}
+
+// Sector returns the sector at the track/sector location.
+func (d Disk) Sector(track, sector uint8) (*Sector, error) {
+	if track == 0 || int(track) > len(d.Tracks) {
+		return nil, fmt.Errorf("track %d out of range", track)
+	}
+	t := d.Tracks[track-1]
+	if int(sector) >= len(t.Sectors) {
+		return nil, fmt.Errorf("sector %d out of range for track %d", sector, track)
+	}
+	return &t.Sectors[sector], nil
+}
+
+// Chain is the result of following a track/sector chain: the sectors
+// visited, and the data bytes they contain.
+type Chain struct {
+	Sectors []Location
+	Data    []byte
+	Err     error // why the chain was broken, Data holds what could be read
+}
+
+// FollowChain reads the sectors linked from the start location.
+//
+// The first two bytes of every sector hold the location of the next sector,
+// followed by 254 bytes of data. In the last sector the track is $00, and the
+// sector byte holds the index of the last data byte used.
+//
+// The chain is broken when a link points outside the disk, or to a sector
+// already visited (a circular chain).
+func (d Disk) FollowChain(start Location) Chain {
+	var chain Chain
+	visited := make(map[Location]bool)
+
+	for next := start; ; {
+		sector, err := d.Sector(next.Track, next.Sector)
+		if err != nil {
+			chain.Err = fmt.Errorf("chain out of range at %s: %w", next, err)
+			return chain
+		}
+		if visited[next] {
+			chain.Err = fmt.Errorf("circular chain at %s", next)
+			return chain
+		}
+		visited[next] = true
+		chain.Sectors = append(chain.Sectors, next)
+
+		if sector[0] == 0 {
+			last := int(sector[1])
+			if last < 1 {
+				chain.Err = fmt.Errorf("invalid last byte index %d at %s", last, next)
+				return chain
+			}
+			chain.Data = append(chain.Data, sector[2:last+1]...)
+			return chain
+		}
+
+		chain.Data = append(chain.Data, sector[2:]...)
+		next = Location{Track: sector[0], Sector: sector[1]}
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+
+	"github.com/mrcook/retroio/commodore/petscii"
 )
 
 // A BAM Entry for each track on the disk
 //
-// The sector bitmap bytes (three for the D64/D71 and five for D81) indicate
-// which sectors are used/free for one track.
+// The sector bitmap bytes (three for the D64/D71, four for the D80/D82 and
+// five for D81) indicate which sectors are used/free for one track.
 //
 // A D81 disk with a five byte bitmap gives a total of 40 bits of storage
 // (8 bits per byte), one for each sector on the track. If track 40 has these
@@ -42,6 +44,13 @@ type BamEntry24Bit struct {
 	// Bitmap of which sectors are used/free.
 	SectorBitmap [3]uint8
 }
+type BamEntry32Bit struct {
+	// The number of free sectors on this track
+	FreeSectors uint8
+
+	// Bitmap of which sectors are used/free.
+	SectorBitmap [4]uint8
+}
 type BamEntry40Bit struct {
 	// The number of free sectors on this track
 	FreeSectors uint8
@@ -76,6 +85,7 @@ type DirectoryFile struct {
 	//          0011     3       USR
 	//          0100     4       REL
 	//          0101     5       CBM (partition or sub-directory, only D81)
+	//          0110     6       DIR (sub-directory, only CMD native partitions)
 	//         See `docs.md` for info on illegal values 5-15 (D64/D71) and 6-15 (D81).
 	//   4   Unused
 	//   5   Used only during SAVE-@ replacement
@@ -159,14 +169,15 @@ func (f DirectoryFile) FileTypeFromID() FileType {
 	}
 }
 
+// PrintableFilename returns the filename using the uppercase/graphics set.
 func (f DirectoryFile) PrintableFilename() string {
-	name := ""
-	for _, c := range f.Filename {
-		if c != 0xA0 {
-			name += string(c)
-		}
-	}
-	return name
+	return f.Name(petscii.Upper)
+}
+
+// Name returns the filename, up to the first $A0 padding character, using
+// the PETSCII character set.
+func (f DirectoryFile) Name(cs petscii.Charset) string {
+	return petscii.Filename(f.Filename[:], cs)
 }
 
 type FileType struct {
@@ -205,52 +216,22 @@ var fileTypes = []FileType{
 
 	// D81 ONLY
 	{5, "CBM", false, false, true, "Partition/Sub-directory"},
+
+	// CMD native partitions ONLY
+	{6, "DIR", false, false, true, "Native Sub-directory"},
 }
 
 // UsedSectorBitmap is a helper function to determine if a sector is allocated
 // in the BAM sector bitmap table. This function will work for both the 24-bit
 // and 40-bit maps of the D64, D71, and D81 disk images.
+//
+// Sector bits are stored least significant bit first, with a set bit marking
+// the sector as free.
 func UsedSectorBitmap(sector uint8, bitmap []uint8) bool {
 	// safety check to make sure we don't try accessing a non-existent byte
-	if int(sector) > len(bitmap)*8 {
+	if int(sector) >= len(bitmap)*8 {
 		return false
 	}
 
-	if sector <= 7 {
-		if reverseBits(bitmap[0])&(1<<sector) > 0 {
-			return true
-		}
-	} else if sector <= 15 {
-		sector -= 8
-		if reverseBits(bitmap[1])&(1<<sector) > 0 {
-			return true
-		}
-	} else if sector <= 23 {
-		sector -= 16
-		if reverseBits(bitmap[2])&(1<<sector) > 0 {
-			return true
-		}
-	} else if sector <= 31 {
-		sector -= 24
-		if reverseBits(bitmap[3])&(1<<sector) > 0 {
-			return true
-		}
-	} else if sector <= 39 {
-		sector -= 32
-		if reverseBits(bitmap[4])&(1<<sector) > 0 {
-			return true
-		}
-	}
-
-	return false
-}
-
-func reverseBits(b byte) byte {
-	var d byte
-	for i := 0; i < 8; i++ {
-		d <<= 1
-		d |= b & 1
-		b >>= 1
-	}
-	return d
+	return bitmap[sector/8]&(1<<(sector%8)) == 0
 }
@@ -7,10 +7,13 @@
 package disk
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/petscii"
 	"github.com/mrcook/retroio/storage"
 )
 
@@ -20,6 +23,17 @@ type Disk struct {
 
 	Variation layout
 	Tracks    []Track
+
+	// One error code per sector, when included in the image.
+	ErrorBytes []byte
+
+	charset petscii.Charset
+}
+
+// SetCharset sets the PETSCII character set used for the filenames of the
+// files read, and when matching and reporting filenames.
+func (d *Disk) SetCharset(cs petscii.Charset) {
+	d.charset = cs
 }
 
 // Initializes a new disk using the reader and media type/size values.
@@ -67,9 +81,67 @@ func (d *Disk) Read() error {
 		return fmt.Errorf("incorrect sector count for disk, expected %d, got %d\n", d.Variation.totalSectors, totalSectorCount)
 	}
 
+	if d.Variation.errorBytes > 0 {
+		d.ErrorBytes = make([]byte, d.Variation.errorBytes)
+		if _, err := io.ReadFull(d.reader, d.ErrorBytes); err != nil {
+			return fmt.Errorf("error reading the error bytes: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// NewBlank creates a disk of the standard size for the media type, without
+// error bytes, with all sectors zero filled.
+func NewBlank(mediaType commodore.MediaType) (*Disk, error) {
+	for _, v := range diskLayouts {
+		if v.mediaType == mediaType && v.errorBytes == 0 {
+			return NewBlankTracks(mediaType, v.tracks, false)
+		}
+	}
+	return nil, fmt.Errorf("no disk layout found for media type #%d", mediaType)
+}
+
+// NewBlankTracks creates a zero filled disk with the number of tracks, e.g.
+// a 40 track D64. When errorBytes is set, the error bytes are included with
+// every sector marked as OK.
+func NewBlankTracks(mediaType commodore.MediaType, tracks uint8, errorBytes bool) (*Disk, error) {
+	for _, v := range diskLayouts {
+		if v.mediaType != mediaType || v.tracks != tracks || (v.errorBytes > 0) != errorBytes {
+			continue
+		}
+
+		d := &Disk{Variation: v}
+		for i := uint8(1); i <= v.tracks; i++ {
+			geometry, err := trackLayout(mediaType, i)
+			if err != nil {
+				return nil, fmt.Errorf("unable to determine geomery for this track number: %w", err)
+			}
+			track := Track{Number: i, Geometry: geometry}
+			track.Sectors = make([]Sector, geometry.sectorsPerTrack)
+			d.Tracks = append(d.Tracks, track)
+		}
+		if errorBytes {
+			d.ErrorBytes = bytes.Repeat([]byte{CodeOK}, int(v.errorBytes))
+		}
+		return d, nil
+	}
+
+	return nil, fmt.Errorf("no disk layout found for media type #%d with %d tracks", mediaType, tracks)
+}
+
+// Bytes returns the disk image, with any error bytes, as stored in the file.
+func (d Disk) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, track := range d.Tracks {
+		for _, sector := range track.Sectors {
+			buf.Write(sector[:])
+		}
+	}
+	buf.Write(d.ErrorBytes)
+	return buf.Bytes()
+}
+
 func (d Disk) DiskType() string {
 	return d.Variation.description
 }
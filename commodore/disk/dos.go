@@ -0,0 +1,38 @@
+package disk
+
+import (
+	"github.com/mrcook/retroio/commodore"
+)
+
+// dosLayout describes where the DOS of each drive stores the directory, and
+// the sector interleave used when writing.
+type dosLayout struct {
+	directoryTrack      uint8
+	firstDirSector      uint8
+	directoryInterleave uint8
+	fileInterleave      uint8
+
+	// sectors on the directory track holding the header and BAM
+	systemSectors []uint8
+
+	// tracks which are never used for files, other than the directory track
+	reservedTracks []uint8
+
+	// highest track number managed by the BAM
+	bamTracks uint8
+}
+
+var dosLayouts = map[commodore.MediaType]dosLayout{
+	commodore.D64: {18, 1, 3, 10, []uint8{0}, nil, 35},
+	commodore.D71: {18, 1, 3, 6, []uint8{0}, []uint8{53}, 70},
+	commodore.D81: {40, 3, 1, 1, []uint8{0, 1, 2}, nil, 80},
+}
+
+func (d Disk) dos() dosLayout {
+	return dosLayouts[d.Variation.mediaType]
+}
+
+// DirectoryTrack returns the track number holding the directory.
+func (d Disk) DirectoryTrack() uint8 {
+	return d.dos().directoryTrack
+}
@@ -4,6 +4,8 @@
 // computer when an error occurs on a real disk drive.
 package disk
 
+import "fmt"
+
 const (
 	None fdcJob = iota // actually an N/A
 	Seek
@@ -22,6 +24,17 @@ type dosError struct {
 	message string
 }
 
+// FDC codes for the read errors, as stored in the error bytes.
+const (
+	CodeOK             = 0x01
+	CodeHeaderNotFound = 0x02
+	CodeNoSync         = 0x03
+	CodeDataNotFound   = 0x04
+	CodeDataChecksum   = 0x05
+	CodeHeaderChecksum = 0x09
+	CodeIDMismatch     = 0x0B
+)
+
 // See the `docs.md` for a more detailed explanation of these codes and descriptions.
 var errorCodes = map[fdcCode]dosError{
 	0x01: {00, None, true, "OK"},
@@ -37,3 +50,108 @@ var errorCodes = map[fdcCode]dosError{
 	0x0B: {29, Seek, true, "Disk Sector ID Mismatch"},
 	0x0F: {74, Read, false, "Drive Not Ready (no disk in drive or no device 1)"},
 }
+
+// DOS error channel messages for the IP error codes.
+var dosMessages = map[uint8]string{
+	20: "READ ERROR",
+	21: "READ ERROR",
+	22: "READ ERROR",
+	23: "READ ERROR",
+	24: "READ ERROR",
+	25: "WRITE ERROR",
+	26: "WRITE PROTECT ON",
+	27: "READ ERROR",
+	28: "WRITE ERROR",
+	29: "DISK ID MISMATCH",
+	74: "DRIVE NOT READY",
+}
+
+// SectorError is the drive error recorded for a sector in the error bytes
+// of a disk image. These errors were often used for copy protection.
+type SectorError struct {
+	Location Location
+	Code     uint8 // FDC code, as stored in the error bytes
+	IPCode   uint8 // DOS error number reported on the error channel
+	Message  string
+}
+
+func (e SectorError) String() string {
+	return fmt.Sprintf("track %d sector %d: %s", e.Location.Track, e.Location.Sector, e.Description())
+}
+
+// Description of the error, as reported by the DOS, e.g. "23 READ ERROR".
+func (e SectorError) Description() string {
+	if e.IPCode == 0 {
+		return fmt.Sprintf("unknown error code $%02X", e.Code)
+	}
+	return fmt.Sprintf("%02d %s, %s", e.IPCode, dosMessages[e.IPCode], e.Message)
+}
+
+// SectorErrors returns the sectors which have an error recorded in the
+// error bytes. Disk images without error bytes return none.
+func (d Disk) SectorErrors() []SectorError {
+	var errs []SectorError
+	for _, track := range d.Tracks {
+		for s := range track.Sectors {
+			if e, ok := d.SectorError(Location{Track: track.Number, Sector: uint8(s)}); ok {
+				errs = append(errs, e)
+			}
+		}
+	}
+	return errs
+}
+
+// SectorError returns the error recorded for the sector, if any. Both $00
+// and $01 mean no error, as $00 is used by many imaging tools.
+func (d Disk) SectorError(l Location) (SectorError, bool) {
+	index := d.sectorIndex(l)
+	if index < 0 || index >= len(d.ErrorBytes) {
+		return SectorError{}, false
+	}
+
+	code := d.ErrorBytes[index]
+	if code == 0x00 || code == 0x01 {
+		return SectorError{}, false
+	}
+
+	e := SectorError{Location: l, Code: code}
+	if dosErr, ok := errorCodes[fdcCode(code)]; ok {
+		e.IPCode = dosErr.ipCode
+		e.Message = dosErr.message
+	}
+	return e, true
+}
+
+// SetSectorError records the FDC code for the sector in the error bytes.
+func (d *Disk) SetSectorError(l Location, code uint8) error {
+	index := d.sectorIndex(l)
+	if index < 0 || index >= len(d.ErrorBytes) {
+		return fmt.Errorf("no error byte for sector %s", l)
+	}
+	d.ErrorBytes[index] = code
+	return nil
+}
+
+// sectorIndex returns the position of the sector in the image, counting
+// from the first sector of track 1.
+func (d Disk) sectorIndex(l Location) int {
+	if l.Track == 0 || int(l.Track) > len(d.Tracks) || int(l.Sector) >= len(d.Tracks[l.Track-1].Sectors) {
+		return -1
+	}
+	index := 0
+	for _, track := range d.Tracks[:l.Track-1] {
+		index += len(track.Sectors)
+	}
+	return index + int(l.Sector)
+}
+
+// sectorErrorWarnings returns a warning for each of the sectors with an error.
+func (d Disk) sectorErrorWarnings(sectors []Location) []string {
+	var warnings []string
+	for _, l := range sectors {
+		if e, ok := d.SectorError(l); ok {
+			warnings = append(warnings, fmt.Sprintf("sector %s has a drive error: %s", l, e.Description()))
+		}
+	}
+	return warnings
+}
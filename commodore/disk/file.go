@@ -0,0 +1,54 @@
+package disk
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mrcook/retroio/commodore"
+)
+
+// Extractable returns true for the file types which store their data in a
+// single track/sector chain: PRG, SEQ and USR files.
+func (f DirectoryFile) Extractable() bool {
+	if f.FileType == 0x00 {
+		return false
+	}
+	switch f.FileType & 0b00000111 {
+	case 1, 2, 3:
+		return true
+	}
+	return false
+}
+
+// ReadFile follows the track/sector chain of the directory entry, returning
+// the file exactly as stored on the disk, so a PRG keeps its load address.
+func (d Disk) ReadFile(entry DirectoryFile) commodore.File {
+	fileType := entry.FileTypeFromID()
+
+	file := commodore.File{
+		Name:     entry.Name(d.charset),
+		Filename: bytes.SplitN(entry.Filename[:], []byte{0xA0}, 2)[0],
+		Type:     strings.ToLower(fileType.Type),
+	}
+
+	start := Location{Track: entry.FirstSectorLocation[0], Sector: entry.FirstSectorLocation[1]}
+	chain := d.FollowChain(start)
+	file.Data = chain.Data
+
+	if chain.Err != nil {
+		file.Warnings = append(file.Warnings, chain.Err.Error())
+	}
+	file.Warnings = append(file.Warnings, d.sectorErrorWarnings(chain.Sectors)...)
+	if !fileType.ClosedFlag {
+		file.Warnings = append(file.Warnings, "file was not closed (splat file), data may be incomplete")
+	}
+	// REL and GEOS files also count their side sectors or info block, which
+	// are checked by ReadRelFile and ReadGEOSFile
+	if !entry.IsRel() && !entry.IsGEOS() && len(chain.Sectors) != int(entry.FileSizeInSectors) {
+		msg := fmt.Sprintf("directory lists %d blocks, the sector chain has %d", entry.FileSizeInSectors, len(chain.Sectors))
+		file.Warnings = append(file.Warnings, msg)
+	}
+
+	return file
+}
@@ -31,6 +31,10 @@ var diskLayouts = []layout{
 	{commodore.D71, 351062, 70, 1366, 1366, "Standard D71 (1571) with error bytes"},
 	{commodore.D81, 819200, 80, 3200, 0, "Standard D81 (1581)"},
 	{commodore.D81, 822400, 80, 3200, 3200, "Standard D81 (1581) with error bytes"},
+	{commodore.D80, 533248, 77, 2083, 0, "Standard D80 (8050)"},
+	{commodore.D80, 535331, 77, 2083, 2083, "Standard D80 (8050) with error bytes"},
+	{commodore.D82, 1066496, 154, 4166, 0, "Standard D82 (8250)"},
+	{commodore.D82, 1070662, 154, 4166, 4166, "Standard D82 (8250) with error bytes"},
 }
 
 func layoutForMedia(mediaType commodore.MediaType, fileSize uint32) (layout, error) {
@@ -57,6 +61,7 @@ func layoutForMedia(mediaType commodore.MediaType, fileSize uint32) (layout, err
 var DosVersions = map[uint8]string{
 	0x00: "Not Set",
 	0x41: "('A') CBM DOS v2.6 (1540/41/71), and others",
+	0x43: "('C') CBM DOS v2.5/v2.7 (8050/8250)",
 	0x44: "('D') CBM DOS v3.x (1581)",
 	0x50: "('P') PrologicDOS 1541 and ProSpeed 1571 2.0",
 }
@@ -67,6 +72,7 @@ var DosTypes = map[string]string{
 	"2A": "CBM DOS v2.6",
 	"2P": "PrologicDOS 1541 and ProSpeed 1571 2.0",
 	"3D": "CBM DOS v3.x",
+	"2C": "CBM DOS v2.5/v2.7 (8050/8250)",
 	"4A": "Professional DOS Release",
 }
 
@@ -79,8 +85,9 @@ type trackGeometry struct {
 	totalSectors    int // total sectors for this range of tracks
 }
 
-// Track layouts for all 6 variations of the single-sided D64, and the
-// double-sided D71 and D81 formats. This data is used to aid in reading the
+// Track layouts for all 6 variations of the single-sided D64, the
+// double-sided D71 and D81 formats, and the 77 track D80 and its double-sided
+// D82 variant. This data is used to aid in reading the
 // correct number of track sectors for a given media type.
 var trackGeometries = map[commodore.MediaType][]trackGeometry{
 	commodore.D64: {
@@ -105,6 +112,22 @@ var trackGeometries = map[commodore.MediaType][]trackGeometry{
 		{0, 1, 40, 40, 1600},
 		{1, 41, 80, 40, 1600},
 	},
+	commodore.D80: {
+		{0, 1, 39, 29, 1131},
+		{0, 40, 53, 27, 378},
+		{0, 54, 64, 25, 275},
+		{0, 65, 77, 23, 299},
+	},
+	commodore.D82: {
+		{0, 1, 39, 29, 1131},
+		{0, 40, 53, 27, 378},
+		{0, 54, 64, 25, 275},
+		{0, 65, 77, 23, 299},
+		{1, 78, 116, 29, 1131},
+		{1, 117, 130, 27, 378},
+		{1, 131, 141, 25, 275},
+		{1, 142, 154, 23, 299},
+	},
 }
 
 // Returns the layout for the track number of a media type.
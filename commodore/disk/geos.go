@@ -0,0 +1,392 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mrcook/retroio/commodore"
+)
+
+// GEOS disks and files
+//
+// A GEOS disk is marked with the signature "GEOS format V1.x" at offset $AD
+// of the BAM sector (the header sector on a D81), with the track/sector of
+// the border block at $AB. The border block holds the directory entries of
+// files dragged to the border of the deskTop.
+//
+// GEOS files reuse some of the directory entry bytes:
+//
+//	$15-$16: track/sector of the info block (the REL side sector bytes)
+//	$17:     structure, $00 sequential, $01 VLIR (the REL record length)
+//	$18:     GEOS file type
+//	$19-$1D: date: year, month, day, hour, minute
+//
+// A sequential file is stored as a normal sector chain. The first sector of
+// a VLIR file is an index of up to 127 records, each a track/sector pair
+// pointing to a separate chain. An empty record is $00/$FF, and the first
+// $00/$00 pair marks the end of the records.
+const (
+	geosSignatureOffset = 0xAD
+	geosBorderOffset    = 0xAB
+
+	GEOSSequential = 0x00
+	GEOSVLIR       = 0x01
+)
+
+// GEOS file types.
+var GEOSFileTypes = []string{
+	"Non-GEOS",
+	"BASIC",
+	"Assembler",
+	"Data file",
+	"System file",
+	"Desk Accessory",
+	"Application",
+	"Application data",
+	"Font file",
+	"Printer driver",
+	"Input driver",
+	"Disk driver",
+	"System boot file",
+	"Temporary",
+	"Auto-execute",
+	"Input 128",
+}
+
+// GEOSInfo is the info block of a GEOS file.
+type GEOSInfo struct {
+	Link [2]uint8 // $00/$FF
+
+	IconWidth  uint8 // width in bytes, always 3
+	IconHeight uint8 // height in lines, always 21
+	IconFormat uint8 // $BF, 63 bytes of uncompressed bitmap follow
+	Icon       [63]uint8
+
+	FileType     uint8 // CBM file type, as in the directory entry
+	GEOSFileType uint8
+	Structure    uint8
+
+	LoadAddress  uint16
+	EndAddress   uint16 // desk accessories only
+	StartAddress uint16
+
+	Class       [20]byte // class name and version, $00 terminated
+	Author      [20]byte
+	Parent      [20]byte // data files: the parent application class
+	Application [23]byte // reserved for use by the application
+	Description [96]byte
+}
+
+// IconString draws the 24x21 pixel icon using block characters.
+func (g GEOSInfo) IconString() string {
+	var icon strings.Builder
+	for row := 0; row < 21; row++ {
+		for col := 0; col < 3; col++ {
+			b := g.Icon[row*3+col]
+			for bit := 7; bit >= 0; bit-- {
+				if b&(1<<bit) > 0 {
+					icon.WriteString("█")
+				} else {
+					icon.WriteString(" ")
+				}
+			}
+		}
+		icon.WriteString("\n")
+	}
+	return icon.String()
+}
+
+// ClassName returns the class name, e.g. "geoWrite    V2.1".
+func (g GEOSInfo) ClassName() string {
+	return geosString(g.Class[:])
+}
+
+func (g GEOSInfo) AuthorName() string {
+	return geosString(g.Author[:])
+}
+
+func (g GEOSInfo) ParentName() string {
+	return geosString(g.Parent[:])
+}
+
+func (g GEOSInfo) DescriptionText() string {
+	return geosString(g.Description[:])
+}
+
+// geosString converts a $00 terminated GEOS string, which uses ASCII
+// rather than PETSCII.
+func geosString(data []byte) string {
+	if i := bytes.IndexByte(data, 0x00); i >= 0 {
+		data = data[:i]
+	}
+	text := make([]rune, 0, len(data))
+	for _, c := range data {
+		switch {
+		case c == 0x0D:
+			text = append(text, '\n')
+		case c < 0x20 || c > 0x7E:
+			text = append(text, '.')
+		default:
+			text = append(text, rune(c))
+		}
+	}
+	return string(text)
+}
+
+// GEOSSignature returns the GEOS format signature of the disk, which is
+// empty for a non-GEOS disk.
+func (d Disk) GEOSSignature() string {
+	sector, err := d.Sector(d.dos().directoryTrack, 0)
+	if err != nil {
+		return ""
+	}
+	signature := sector[geosSignatureOffset : geosSignatureOffset+16]
+	if !bytes.HasPrefix(signature, []byte("GEOS format")) {
+		return ""
+	}
+	return string(bytes.TrimRight(signature, "\x00\xa0 "))
+}
+
+// GEOSBorderBlock returns the location of the border block of a GEOS disk.
+func (d Disk) GEOSBorderBlock() (Location, bool) {
+	if d.GEOSSignature() == "" {
+		return Location{}, false
+	}
+	sector, _ := d.Sector(d.dos().directoryTrack, 0)
+	l := Location{Track: sector[geosBorderOffset], Sector: sector[geosBorderOffset+1]}
+	return l, l.Track != 0
+}
+
+// IsGEOS returns true when the entry is a GEOS file, which has a GEOS file
+// type and an info block.
+func (f DirectoryFile) IsGEOS() bool {
+	return f.FileType != 0x00 && f.GEOSFileType() != 0 && f.FirstSideSectorTrack != 0 && !f.IsRel()
+}
+
+func (f DirectoryFile) GEOSFileType() uint8 {
+	return f.Unused[0]
+}
+
+// GEOSFileTypeName returns the description of the GEOS file type.
+func (f DirectoryFile) GEOSFileTypeName() string {
+	if int(f.GEOSFileType()) < len(GEOSFileTypes) {
+		return GEOSFileTypes[f.GEOSFileType()]
+	}
+	return fmt.Sprintf("Unknown ($%02X)", f.GEOSFileType())
+}
+
+// GEOSStructure returns "VLIR" or "SEQ".
+func (f DirectoryFile) GEOSStructure() string {
+	if f.RecordLength == GEOSVLIR {
+		return "VLIR"
+	}
+	return "SEQ"
+}
+
+// GEOSDate returns the date and time the file was last modified. The year
+// is stored as an offset from 1900.
+func (f DirectoryFile) GEOSDate() (time.Time, bool) {
+	year, month, day := int(f.Unused[1]), int(f.Unused[2]), int(f.Unused[3])
+	hour, minute := int(f.ReplacementFileTrack), int(f.ReplacementFileSector)
+
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 {
+		return time.Time{}, false
+	}
+	if year < 80 {
+		year += 100 // 2000 and later
+	}
+	return time.Date(1900+year, time.Month(month), day, hour, minute, 0, 0, time.UTC), true
+}
+
+// GEOSInfo reads the info block of a GEOS file.
+func (d Disk) GEOSInfo(entry DirectoryFile) (GEOSInfo, error) {
+	var info GEOSInfo
+
+	sector, err := d.Sector(entry.FirstSideSectorTrack, entry.FirstSideSectorSector)
+	if err != nil {
+		return info, fmt.Errorf("info block: %w", err)
+	}
+	if err := binary.Read(bytes.NewReader(sector[:]), binary.LittleEndian, &info); err != nil {
+		return info, fmt.Errorf("info block: %w", err)
+	}
+	return info, nil
+}
+
+// VLIRRecord is a record of a VLIR file. Empty records have no sectors.
+type VLIRRecord struct {
+	Empty bool
+	Chain Chain
+}
+
+// VLIRRecords reads the record chains listed in the index sector of a VLIR file.
+func (d Disk) VLIRRecords(entry DirectoryFile) ([]VLIRRecord, error) {
+	index, err := d.Sector(entry.FirstSectorLocation[0], entry.FirstSectorLocation[1])
+	if err != nil {
+		return nil, fmt.Errorf("VLIR index: %w", err)
+	}
+
+	var records []VLIRRecord
+	for i := 2; i < len(index); i += 2 {
+		l := Location{Track: index[i], Sector: index[i+1]}
+		if l.Track == 0 && l.Sector == 0 {
+			break
+		}
+		if l.Track == 0 {
+			records = append(records, VLIRRecord{Empty: true})
+			continue
+		}
+		records = append(records, VLIRRecord{Chain: d.FollowChain(l)})
+	}
+
+	return records, nil
+}
+
+// GEOSConvert returns the GEOS file in the CVT (ConVerT) format, used to
+// store GEOS files as a single sequential file.
+//
+// The first 254 byte block holds the directory entry (without the link
+// bytes), followed by the signature. The second block is the info block.
+// A sequential file then has its data. A VLIR file has a record block, with
+// the track/sector pairs of the index replaced by the number of sectors and
+// the last byte index of each record, followed by the sectors of each
+// record in turn.
+func (d Disk) GEOSConvert(entry DirectoryFile) ([]byte, error) {
+	if !entry.IsGEOS() {
+		return nil, fmt.Errorf("%s is not a GEOS file", entry.Name(d.charset))
+	}
+
+	var cvt bytes.Buffer
+
+	var dirEntry bytes.Buffer
+	if err := binary.Write(&dirEntry, binary.LittleEndian, entry); err != nil {
+		return nil, err
+	}
+	signature := "SEQ formatted GEOS file V1.0"
+	if entry.RecordLength == GEOSVLIR {
+		signature = "PRG formatted GEOS file V1.0"
+	}
+	block := make([]byte, 254)
+	copy(block, dirEntry.Bytes()[2:])
+	copy(block[30:], signature)
+	cvt.Write(block)
+
+	info, err := d.Sector(entry.FirstSideSectorTrack, entry.FirstSideSectorSector)
+	if err != nil {
+		return nil, fmt.Errorf("info block: %w", err)
+	}
+	cvt.Write(info[2:])
+
+	if entry.RecordLength != GEOSVLIR {
+		chain := d.FollowChain(Location{Track: entry.FirstSectorLocation[0], Sector: entry.FirstSectorLocation[1]})
+		if chain.Err != nil {
+			return nil, chain.Err
+		}
+		cvt.Write(chain.Data)
+		return cvt.Bytes(), nil
+	}
+
+	records, err := d.VLIRRecords(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	recordBlock := make([]byte, 254)
+	var data bytes.Buffer
+	for i, r := range records {
+		if r.Empty {
+			recordBlock[i*2+1] = 0xFF
+			continue
+		}
+		if r.Chain.Err != nil {
+			return nil, fmt.Errorf("VLIR record %d: %w", i, r.Chain.Err)
+		}
+
+		last := r.Chain.Sectors[len(r.Chain.Sectors)-1]
+		lastSector, _ := d.Sector(last.Track, last.Sector)
+		recordBlock[i*2] = uint8(len(r.Chain.Sectors))
+		recordBlock[i*2+1] = lastSector[1]
+
+		// each record is stored as whole sectors
+		padded := make([]byte, len(r.Chain.Sectors)*254)
+		copy(padded, r.Chain.Data)
+		data.Write(padded)
+	}
+	cvt.Write(recordBlock)
+	cvt.Write(data.Bytes())
+
+	return cvt.Bytes(), nil
+}
+
+// ReadGEOSFile reads a GEOS file: the data chain of a sequential file, or
+// the records of a VLIR file joined together.
+func (d Disk) ReadGEOSFile(entry DirectoryFile) commodore.File {
+	file := d.ReadFile(entry)
+	sectors, err := d.geosSectors(entry)
+
+	if entry.RecordLength == GEOSVLIR {
+		// ReadFile has only read the index sector
+		file.Data = nil
+		file.Warnings = nil
+		records, _ := d.VLIRRecords(entry)
+		for _, r := range records {
+			file.Data = append(file.Data, r.Chain.Data...)
+		}
+		if err != nil {
+			file.Warnings = append(file.Warnings, err.Error())
+		}
+		file.Warnings = append(file.Warnings, d.sectorErrorWarnings(sectors)...)
+	}
+
+	if len(sectors) != int(entry.FileSizeInSectors) {
+		msg := fmt.Sprintf("directory lists %d blocks, the file has %d", entry.FileSizeInSectors, len(sectors))
+		file.Warnings = append(file.Warnings, msg)
+	}
+
+	return file
+}
+
+// GEOSDescription summarises a GEOS file for directory listings: its
+// structure, file type, date and class name. Non-GEOS files return an empty
+// string.
+func (d Disk) GEOSDescription(entry DirectoryFile) string {
+	if !entry.IsGEOS() {
+		return ""
+	}
+
+	desc := fmt.Sprintf("%-4s %s", entry.GEOSStructure(), entry.GEOSFileTypeName())
+	if date, ok := entry.GEOSDate(); ok {
+		desc += date.Format(", 2006-01-02 15:04")
+	}
+	if info, err := d.GEOSInfo(entry); err == nil && info.ClassName() != "" {
+		desc += fmt.Sprintf(", \"%s\"", info.ClassName())
+	}
+	return desc
+}
+
+// geosSectors returns all sectors used by a GEOS file: the info block, and
+// either the data chain, or the VLIR index and the chain of each record.
+func (d Disk) geosSectors(entry DirectoryFile) ([]Location, error) {
+	sectors := []Location{{Track: entry.FirstSideSectorTrack, Sector: entry.FirstSideSectorSector}}
+
+	start := Location{Track: entry.FirstSectorLocation[0], Sector: entry.FirstSectorLocation[1]}
+	if entry.RecordLength != GEOSVLIR {
+		chain := d.FollowChain(start)
+		return append(sectors, chain.Sectors...), chain.Err
+	}
+
+	records, err := d.VLIRRecords(entry)
+	if err != nil {
+		return sectors, err
+	}
+	sectors = append(sectors, start)
+	for i, r := range records {
+		sectors = append(sectors, r.Chain.Sectors...)
+		if r.Chain.Err != nil {
+			return sectors, fmt.Errorf("VLIR record %d: %w", i, r.Chain.Err)
+		}
+	}
+	return sectors, nil
+}
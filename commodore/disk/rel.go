@@ -1,5 +1,14 @@
 package disk
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/petscii"
+)
+
 // SideSector of a REL file type.
 //
 // Suitable for D64, D71, and D81 formats.
@@ -53,3 +62,337 @@ type SuperSideSector struct {
 
 	Unused uint8 // Unused (likely $00)
 }
+
+// IsRel returns true for a REL file entry that has not been scratched.
+func (f DirectoryFile) IsRel() bool {
+	return f.FileType != 0x00 && f.FileType&0b00000111 == 4
+}
+
+// ReadRelFile reads a REL file, following the data chain and checking it
+// against the data sector list stored in the side sectors. The D81 stores a
+// super side sector in the directory entry, pointing to the groups of side
+// sectors, while the D64/D71 point directly to the first side sector.
+func (d Disk) ReadRelFile(entry DirectoryFile) commodore.File {
+	file := d.ReadFile(entry)
+	file.RecordLength = entry.RecordLength
+
+	if entry.RecordLength == 0 {
+		file.Warnings = append(file.Warnings, "REL file has a record length of 0")
+		return file
+	}
+
+	first := Location{Track: entry.FirstSideSectorTrack, Sector: entry.FirstSideSectorSector}
+
+	var groups []Location
+	if d.Variation.mediaType == commodore.D81 {
+		super, err := d.readSuperSideSector(first)
+		if err != nil {
+			file.Warnings = append(file.Warnings, err.Error())
+			return file
+		}
+		for _, g := range super.TrackSectorGroupChains {
+			if g[0] == 0 {
+				break
+			}
+			groups = append(groups, Location{Track: g[0], Sector: g[1]})
+		}
+	} else {
+		groups = []Location{first}
+	}
+
+	sideSectorCount := 0
+	if d.Variation.mediaType == commodore.D81 {
+		sideSectorCount++ // the super side sector
+	}
+
+	var listed []Location
+	for i, group := range groups {
+		sectors, count, warnings := d.readSideSectorGroup(group, entry.RecordLength)
+		sideSectorCount += count
+		for _, w := range warnings {
+			file.Warnings = append(file.Warnings, fmt.Sprintf("side sector group %d: %s", i, w))
+		}
+		listed = append(listed, sectors...)
+	}
+
+	chain := d.FollowChain(Location{Track: entry.FirstSectorLocation[0], Sector: entry.FirstSectorLocation[1]})
+	file.Warnings = append(file.Warnings, compareSectorLists(listed, chain.Sectors)...)
+	file.Warnings = append(file.Warnings, d.sectorErrorWarnings(d.sideSectorLocations(entry))...)
+
+	if total := len(chain.Sectors) + sideSectorCount; total != int(entry.FileSizeInSectors) {
+		msg := fmt.Sprintf("directory lists %d blocks, the file uses %d (including side sectors)", entry.FileSizeInSectors, total)
+		file.Warnings = append(file.Warnings, msg)
+	}
+
+	return file
+}
+
+// readSideSectorGroup follows the chain of up to six side sectors, returning
+// the data sector locations they list, and the number of side sectors read.
+func (d Disk) readSideSectorGroup(first Location, recordLength uint8) ([]Location, int, []string) {
+	var sectors []Location
+	var warnings []string
+	var group [6][2]uint8
+
+	next := first
+	block := 0
+	for ; next.Track != 0; block++ {
+		if block >= len(group) {
+			warnings = append(warnings, "more than six side sectors in the group")
+			break
+		}
+
+		side, err := d.readSideSector(next)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+			break
+		}
+
+		if block == 0 {
+			group = side.AllSideSectorLocations
+		} else if side.AllSideSectorLocations != group {
+			warnings = append(warnings, fmt.Sprintf("side sector %d lists different side sector locations", block))
+		}
+		if int(side.BlockNumber) != block {
+			warnings = append(warnings, fmt.Sprintf("side sector at %s has block number %d, expected %d", next, side.BlockNumber, block))
+		}
+		if side.RecordSize != recordLength {
+			warnings = append(warnings, fmt.Sprintf("side sector at %s has record size %d, expected %d", next, side.RecordSize, recordLength))
+		}
+		if group[block] != [2]uint8{next.Track, next.Sector} {
+			warnings = append(warnings, fmt.Sprintf("side sector at %s is not listed in the side sector locations", next))
+		}
+
+		for _, ts := range side.TrackSectorChains {
+			if ts[0] == 0 {
+				break
+			}
+			sectors = append(sectors, Location{Track: ts[0], Sector: ts[1]})
+		}
+
+		next = Location{Track: side.TrackLocation, Sector: side.SectorLocation}
+	}
+
+	return sectors, block, warnings
+}
+
+func (d Disk) readSideSector(location Location) (SideSector, error) {
+	var side SideSector
+	sector, err := d.Sector(location.Track, location.Sector)
+	if err != nil {
+		return side, fmt.Errorf("side sector %s: %w", location, err)
+	}
+	err = binary.Read(bytes.NewReader(sector[:]), binary.LittleEndian, &side)
+	return side, err
+}
+
+func (d Disk) readSuperSideSector(location Location) (SuperSideSector, error) {
+	var super SuperSideSector
+	sector, err := d.Sector(location.Track, location.Sector)
+	if err != nil {
+		return super, fmt.Errorf("super side sector %s: %w", location, err)
+	}
+	if err := binary.Read(bytes.NewReader(sector[:]), binary.LittleEndian, &super); err != nil {
+		return super, err
+	}
+	if super.Unknown != 0xFE {
+		return super, fmt.Errorf("super side sector %s has an invalid marker byte $%02X", location, super.Unknown)
+	}
+	return super, nil
+}
+
+// compareSectorLists checks the data sectors listed in the side sectors
+// match the data chain.
+func compareSectorLists(listed, chain []Location) []string {
+	var warnings []string
+
+	if len(listed) != len(chain) {
+		warnings = append(warnings, fmt.Sprintf("side sectors list %d data sectors, the data chain has %d", len(listed), len(chain)))
+	}
+	for i := 0; i < len(listed) && i < len(chain); i++ {
+		if listed[i] != chain[i] {
+			warnings = append(warnings, fmt.Sprintf("data sector %d is %s in the side sectors, but %s in the data chain", i, listed[i], chain[i]))
+			break
+		}
+	}
+
+	return warnings
+}
+
+// sideSectorLocations returns the locations of the side sectors of a REL
+// file, including the super side sector of a D81.
+func (d Disk) sideSectorLocations(entry DirectoryFile) []Location {
+	first := Location{Track: entry.FirstSideSectorTrack, Sector: entry.FirstSideSectorSector}
+	if first.Track == 0 {
+		return nil
+	}
+
+	var locations []Location
+	groups := []Location{first}
+	if d.Variation.mediaType == commodore.D81 {
+		locations = append(locations, first)
+		super, err := d.readSuperSideSector(first)
+		if err != nil {
+			return locations
+		}
+		groups = nil
+		for _, g := range super.TrackSectorGroupChains {
+			if g[0] == 0 {
+				break
+			}
+			groups = append(groups, Location{Track: g[0], Sector: g[1]})
+		}
+	}
+
+	visited := make(map[Location]bool)
+	for _, next := range groups {
+		for next.Track != 0 && !visited[next] {
+			side, err := d.readSideSector(next)
+			if err != nil {
+				break
+			}
+			visited[next] = true
+			locations = append(locations, next)
+			next = Location{Track: side.TrackLocation, Sector: side.SectorLocation}
+		}
+	}
+
+	return locations
+}
+
+const (
+	sideSectorEntries   = 120 // data sectors listed in each side sector
+	sideSectorsPerGroup = 6
+	superSideGroups     = 126
+)
+
+// WriteRelFile saves the data as a new REL file with the given record
+// length, adding the side sectors which list its data sectors. On a D81 the
+// side sectors are listed in groups of six by a super side sector, which the
+// directory entry points to.
+func (d Disk) WriteRelFile(name string, recordLength uint8, data []byte) error {
+	filename, err := PETSCIIName(name)
+	if err != nil {
+		return err
+	}
+	return d.WriteNamedRelFile(filename, recordLength, data)
+}
+
+// WriteNamedRelFile saves the data as a new REL file, as WriteRelFile, using
+// the PETSCII filename exactly as given, padded with $A0.
+func (d Disk) WriteNamedRelFile(filename [16]uint8, recordLength uint8, data []byte) error {
+	name := petscii.Filename(filename[:], petscii.Upper)
+	if recordLength == 0 || recordLength > 254 {
+		return fmt.Errorf("invalid REL record length: %d", recordLength)
+	}
+	if _, err := d.findFilename(filename); err == nil {
+		return fmt.Errorf("file exists: %s", name)
+	}
+
+	dataBlocks := (len(data) + 253) / 254
+	if dataBlocks == 0 {
+		dataBlocks = 1
+	}
+	sideSectors := (dataBlocks + sideSectorEntries - 1) / sideSectorEntries
+	groups := (sideSectors + sideSectorsPerGroup - 1) / sideSectorsPerGroup
+
+	super := d.Variation.mediaType == commodore.D81
+	blocks := dataBlocks + sideSectors
+	if super {
+		blocks++
+		if groups > superSideGroups {
+			return fmt.Errorf("REL file too large: %s needs %d side sector groups, max %d", name, groups, superSideGroups)
+		}
+	} else if groups > 1 {
+		return fmt.Errorf("REL file too large: %s needs %d data blocks, max %d", name, dataBlocks, sideSectorEntries*sideSectorsPerGroup)
+	}
+	if blocks > d.FreeBlocks() {
+		return fmt.Errorf("disk full: %s needs %d blocks, %d free", name, blocks, d.FreeBlocks())
+	}
+
+	entry, err := d.freeDirectoryEntry()
+	if err != nil {
+		return err
+	}
+
+	sectors, err := d.allocateSectors(blocks)
+	if err != nil {
+		return err
+	}
+	side, sectors := sectors[dataBlocks:], sectors[:dataBlocks]
+	d.writeChain(sectors, data)
+
+	var first Location
+	if super {
+		first, side = side[0], side[1:]
+	}
+
+	var groupFirst []Location
+	for g := 0; g < groups; g++ {
+		start := g * sideSectorsPerGroup
+		end := start + sideSectorsPerGroup
+		if end > len(side) {
+			end = len(side)
+		}
+		d.writeSideSectorGroup(side[start:end], sectors[start*sideSectorEntries:], recordLength)
+		groupFirst = append(groupFirst, side[start])
+	}
+
+	if super {
+		s := SuperSideSector{TrackLocation: groupFirst[0].Track, SectorLocation: groupFirst[0].Sector, Unknown: 0xFE}
+		for i, l := range groupFirst {
+			s.TrackSectorGroupChains[i] = [2]uint8{l.Track, l.Sector}
+		}
+		if err := d.WriteSector(first, s); err != nil {
+			return err
+		}
+	} else {
+		first = groupFirst[0]
+	}
+
+	entry.File = DirectoryFile{
+		FileType:              0x84,
+		FirstSectorLocation:   [2]uint8{sectors[0].Track, sectors[0].Sector},
+		Filename:              filename,
+		FirstSideSectorTrack:  first.Track,
+		FirstSideSectorSector: first.Sector,
+		RecordLength:          recordLength,
+		FileSizeInSectors:     uint16(blocks),
+	}
+
+	return d.writeEntry(entry)
+}
+
+// writeSideSectorGroup writes a group of up to six side sectors, listing the
+// data sectors starting with the first sector of the group. The last side
+// sector holds the position of its last used byte in place of the link.
+func (d Disk) writeSideSectorGroup(group []Location, sectors []Location, recordLength uint8) {
+	var locations [6][2]uint8
+	for i, l := range group {
+		locations[i] = [2]uint8{l.Track, l.Sector}
+	}
+
+	for i, l := range group {
+		side := SideSector{
+			BlockNumber:            uint8(i),
+			RecordSize:             recordLength,
+			AllSideSectorLocations: locations,
+		}
+
+		listed := sectors[i*sideSectorEntries:]
+		if len(listed) > sideSectorEntries {
+			listed = listed[:sideSectorEntries]
+		}
+		for j, s := range listed {
+			side.TrackSectorChains[j] = [2]uint8{s.Track, s.Sector}
+		}
+
+		if i < len(group)-1 {
+			side.TrackLocation, side.SectorLocation = group[i+1].Track, group[i+1].Sector
+		} else {
+			side.SectorLocation = uint8(16 + len(listed)*2 - 1)
+		}
+
+		_ = d.WriteSector(l, side)
+	}
+}
@@ -0,0 +1,123 @@
+package disk
+
+import (
+	"fmt"
+)
+
+// RecoveryStatus of a scratched file.
+type RecoveryStatus int
+
+const (
+	Intact               RecoveryStatus = iota // all sectors are still free, the chain is complete
+	PartiallyOverwritten                       // some of the sectors have been reused, or the chain is broken
+	Lost                                       // the first sector has been reused, or is invalid
+)
+
+func (s RecoveryStatus) String() string {
+	switch s {
+	case Intact:
+		return "intact"
+	case PartiallyOverwritten:
+		return "partially overwritten"
+	default:
+		return "lost"
+	}
+}
+
+// ScratchedFile is a scratched directory entry, and the state of its data.
+type ScratchedFile struct {
+	Entry    DirectoryEntry
+	Status   RecoveryStatus
+	Chain    Chain
+	Problems []string
+}
+
+// ScratchedFiles checks every scratched directory entry which still points
+// to a file. When a file is scratched the DOS only clears its file type, and
+// frees its sectors in the BAM, so the data remains until the sectors are
+// reused by another file.
+func (d Disk) ScratchedFiles() []ScratchedFile {
+	var files []ScratchedFile
+
+	entries, _ := d.Directory()
+	usage := d.sectorUsage()
+
+	for _, e := range entries {
+		if e.File.FileType != 0x00 || e.File.FirstSectorLocation[0] == 0 {
+			continue
+		}
+		files = append(files, d.checkScratched(e, usage))
+	}
+
+	return files
+}
+
+func (d Disk) checkScratched(entry DirectoryEntry, usage sectorUsage) ScratchedFile {
+	file := ScratchedFile{Entry: entry}
+
+	start := Location{Track: entry.File.FirstSectorLocation[0], Sector: entry.File.FirstSectorLocation[1]}
+	file.Chain = d.FollowChain(start)
+	if file.Chain.Err != nil {
+		file.Problems = append(file.Problems, file.Chain.Err.Error())
+	}
+
+	// once a sector is reused its link can no longer be trusted, so only the
+	// sectors before it are kept
+	for i, l := range file.Chain.Sectors {
+		owner, inUse := usage.owners[l]
+		free, _ := d.IsFree(l)
+		if free && !inUse {
+			continue
+		}
+
+		if inUse {
+			file.Problems = append(file.Problems, fmt.Sprintf("sector %s is now used by %s", l, owner))
+		} else {
+			file.Problems = append(file.Problems, fmt.Sprintf("sector %s is allocated in the BAM", l))
+		}
+		file.Chain.Sectors = file.Chain.Sectors[:i]
+		if len(file.Chain.Data) > i*254 {
+			file.Chain.Data = file.Chain.Data[:i*254]
+		}
+		break
+	}
+
+	if len(file.Problems) == 0 && len(file.Chain.Sectors) != int(entry.File.FileSizeInSectors) {
+		msg := fmt.Sprintf("directory lists %d blocks, the sector chain has %d", entry.File.FileSizeInSectors, len(file.Chain.Sectors))
+		file.Problems = append(file.Problems, msg)
+	}
+
+	switch {
+	case len(file.Chain.Sectors) == 0:
+		file.Status = Lost
+	case len(file.Problems) > 0:
+		file.Status = PartiallyOverwritten
+	default:
+		file.Status = Intact
+	}
+
+	return file
+}
+
+// Undelete restores an intact scratched file to the directory, allocating
+// its sectors in the BAM. The original file type is not kept by the DOS, so
+// it must be given, e.g. 2 for a PRG.
+func (d Disk) Undelete(file ScratchedFile, fileType uint8) error {
+	name := file.Entry.File.Name(d.charset)
+
+	if file.Status != Intact {
+		return fmt.Errorf("%s can not be restored, the file is %s", name, file.Status)
+	}
+	if _, err := d.FindFile(name); err == nil {
+		return fmt.Errorf("file exists: %s", name)
+	}
+
+	for _, l := range file.Chain.Sectors {
+		if err := d.Allocate(l); err != nil {
+			return err
+		}
+	}
+
+	file.Entry.File.FileType = 0x80 | fileType&0b00000111
+	return d.writeEntry(file.Entry)
+}
@@ -0,0 +1,172 @@
+package disk
+
+import (
+	"fmt"
+	"sort"
+)
+
+// sectorUsage records which sectors are in use, found by following the
+// directory and the chains of every file.
+type sectorUsage struct {
+	owners   map[Location]string // closed files, the directory, and the BAM
+	unclosed map[Location]string // files which were not closed (splat files)
+	splats   []DirectoryEntry
+	problems []string
+}
+
+func (d Disk) sectorUsage() sectorUsage {
+	usage := sectorUsage{
+		owners:   make(map[Location]string),
+		unclosed: make(map[Location]string),
+	}
+
+	for _, s := range d.dos().systemSectors {
+		usage.owners[Location{Track: d.dos().directoryTrack, Sector: s}] = "header/BAM"
+	}
+	for _, t := range d.dos().reservedTracks {
+		for s := range d.Tracks[t-1].Sectors {
+			usage.owners[Location{Track: t, Sector: uint8(s)}] = "BAM"
+		}
+	}
+
+	entries, err := d.Directory()
+	if err != nil {
+		usage.problems = append(usage.problems, err.Error())
+	}
+	dirSectors, _ := d.directorySectors()
+	usage.add("directory", dirSectors)
+	if border, ok := d.GEOSBorderBlock(); ok {
+		usage.add("GEOS border block", []Location{border})
+	}
+
+	for _, e := range entries {
+		if e.File.FileType == 0x00 {
+			continue
+		}
+		name := e.File.Name(d.charset)
+
+		sectors, err := d.fileSectors(e.File)
+		if err != nil {
+			usage.problems = append(usage.problems, fmt.Sprintf("%s: %s", name, err))
+		}
+
+		if !e.File.FileTypeFromID().ClosedFlag {
+			usage.splats = append(usage.splats, e)
+			for _, l := range sectors {
+				usage.unclosed[l] = name
+			}
+			continue
+		}
+		usage.add(name, sectors)
+	}
+
+	return usage
+}
+
+// add the file sectors, reporting any which are already used by another file.
+func (u *sectorUsage) add(name string, sectors []Location) {
+	for _, l := range sectors {
+		if owner, ok := u.owners[l]; ok {
+			u.problems = append(u.problems, fmt.Sprintf("sector %s is used by both %s and %s", l, owner, name))
+			continue
+		}
+		u.owners[l] = name
+	}
+}
+
+// RebuildBAM recreates the BAM from the directory, as done by the DOS
+// VALIDATE command: the header, BAM and directory sectors, and the sectors
+// of every closed file are allocated, and all other sectors are freed.
+// Files which were not closed (splat files) are scratched.
+//
+// A report of the changes to the directory, and any problems found with the
+// file chains, is returned.
+func (d Disk) RebuildBAM() ([]string, error) {
+	usage := d.sectorUsage()
+	report := usage.problems
+
+	for _, e := range usage.splats {
+		e.File.FileType = 0x00
+		if err := d.writeEntry(e); err != nil {
+			return report, err
+		}
+		report = append(report, fmt.Sprintf("%s: scratched, the file was not closed", e.File.Name(d.charset)))
+	}
+
+	if err := d.clearBAM(); err != nil {
+		return report, err
+	}
+	for l, owner := range usage.owners {
+		if d.isReservedTrack(l.Track) {
+			continue
+		}
+		if err := d.Allocate(l); err != nil {
+			report = append(report, fmt.Sprintf("%s: %s", owner, err))
+		}
+	}
+
+	return report, nil
+}
+
+// ValidateBAM compares the BAM with the sectors used by the directory and
+// files, reporting:
+//
+//   - allocated sectors not used by any file (orphaned sectors),
+//   - sectors used by more than one file,
+//   - files using sectors marked as free,
+//   - track free sector counts that disagree with the sector bitmap.
+//
+// The BAM is not changed, use RebuildBAM to correct it.
+func (d Disk) ValidateBAM() []string {
+	usage := d.sectorUsage()
+	report := usage.problems
+
+	for _, e := range usage.splats {
+		report = append(report, fmt.Sprintf("%s: the file was not closed (splat file)", e.File.Name(d.charset)))
+	}
+
+	var problems []string
+	for t := uint8(1); t <= d.dos().bamTracks && int(t) <= len(d.Tracks); t++ {
+		count, bitmap, err := d.bamEntry(t)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		free := 0
+		for s := range d.Tracks[t-1].Sectors {
+			l := Location{Track: t, Sector: uint8(s)}
+			used := UsedSectorBitmap(l.Sector, bitmap)
+			owner, inUse := usage.owners[l]
+
+			switch {
+			case !used:
+				free++
+				if inUse {
+					problems = append(problems, fmt.Sprintf("sector %s is used by %s, but is marked free in the BAM", l, owner))
+				}
+			case inUse:
+			case usage.unclosed[l] != "":
+				problems = append(problems, fmt.Sprintf("sector %s is allocated to the unclosed file %s", l, usage.unclosed[l]))
+			default:
+				problems = append(problems, fmt.Sprintf("sector %s is allocated in the BAM, but not used by any file", l))
+			}
+		}
+
+		if free != int(*count) {
+			problems = append(problems, fmt.Sprintf("track %d has a free sector count of %d, but the bitmap has %d free sectors", t, *count, free))
+		}
+	}
+
+	// sectors used by files, but outside the tracks managed by the BAM
+	var outside []string
+	for l, owner := range usage.owners {
+		if _, _, err := d.bamEntry(l.Track); err != nil {
+			outside = append(outside, fmt.Sprintf("sector %s is used by %s, but its track is not in the BAM", l, owner))
+		}
+	}
+	sort.Strings(outside)
+
+	report = append(report, problems...)
+	return append(report, outside...)
+}
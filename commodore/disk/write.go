@@ -0,0 +1,440 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/petscii"
+)
+
+// DirectoryEntry is a 32-byte directory slot, and where it is stored on the disk.
+type DirectoryEntry struct {
+	Location Location
+	Index    int // slot number within the sector, 0-7
+	File     DirectoryFile
+}
+
+// Directory returns every slot of the directory chain, including the unused
+// and scratched entries.
+func (d Disk) Directory() ([]DirectoryEntry, error) {
+	locations, err := d.directorySectors()
+
+	var entries []DirectoryEntry
+	for _, l := range locations {
+		sector, _ := d.Sector(l.Track, l.Sector)
+
+		files := make([]DirectoryFile, 8)
+		if err := binary.Read(bytes.NewReader(sector[:]), binary.LittleEndian, files); err != nil {
+			return nil, err
+		}
+		for i, f := range files {
+			entries = append(entries, DirectoryEntry{Location: l, Index: i, File: f})
+		}
+	}
+
+	return entries, err
+}
+
+// directorySectors follows the directory chain from the first directory sector.
+func (d Disk) directorySectors() ([]Location, error) {
+	var locations []Location
+	visited := make(map[Location]bool)
+
+	next := Location{Track: d.dos().directoryTrack, Sector: d.dos().firstDirSector}
+	for {
+		sector, err := d.Sector(next.Track, next.Sector)
+		if err != nil {
+			return locations, fmt.Errorf("directory chain out of range at %s: %w", next, err)
+		}
+		if visited[next] {
+			return locations, fmt.Errorf("circular directory chain at %s", next)
+		}
+		visited[next] = true
+		locations = append(locations, next)
+
+		if sector[0] == 0 {
+			return locations, nil
+		}
+		next = Location{Track: sector[0], Sector: sector[1]}
+	}
+}
+
+// writeEntry stores the directory entry back in its sector. The first two
+// bytes of a slot hold the directory chain link, and are left unchanged.
+func (d Disk) writeEntry(entry DirectoryEntry) error {
+	sector, err := d.Sector(entry.Location.Track, entry.Location.Sector)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, entry.File); err != nil {
+		return err
+	}
+	offset := entry.Index * 32
+	copy(sector[offset+2:offset+32], buf.Bytes()[2:])
+
+	return nil
+}
+
+// FindFile returns the directory entry for the named file. Scratched files
+// are ignored, and names are not case sensitive.
+func (d Disk) FindFile(name string) (DirectoryEntry, error) {
+	entries, err := d.Directory()
+	if err != nil {
+		return DirectoryEntry{}, err
+	}
+	for _, e := range entries {
+		if e.File.FileType != 0x00 && strings.EqualFold(e.File.Name(d.charset), name) {
+			return e, nil
+		}
+	}
+	return DirectoryEntry{}, fmt.Errorf("file not found: %s", name)
+}
+
+// PETSCIIName converts a filename to PETSCII, padded with $A0. Lowercase
+// letters are converted to uppercase, which is the unshifted character set.
+func PETSCIIName(name string) ([16]uint8, error) {
+	var filename [16]uint8
+
+	runes := []rune(strings.ToUpper(name))
+	if len(runes) == 0 || len(runes) > len(filename) {
+		return filename, fmt.Errorf("filename must be 1-16 characters: '%s'", name)
+	}
+	for i := range filename {
+		filename[i] = 0xA0
+	}
+	for i, c := range runes {
+		if c < 0x20 || c > 0x5F || strings.ContainsRune(`",:*?=`, c) {
+			return filename, fmt.Errorf("invalid character '%c' in filename: '%s'", c, name)
+		}
+		filename[i] = uint8(c)
+	}
+
+	return filename, nil
+}
+
+// PaddedName pads a PETSCII filename with $A0, as stored in a directory
+// entry. The name is used as given, so it may hold any PETSCII character.
+func PaddedName(name []byte) ([16]uint8, error) {
+	var filename [16]uint8
+
+	if len(name) == 0 || len(name) > len(filename) {
+		return filename, fmt.Errorf("filename must be 1-16 characters: '%s'", petscii.String(name, petscii.Upper))
+	}
+	for i := range filename {
+		filename[i] = 0xA0
+	}
+	copy(filename[:], name)
+
+	return filename, nil
+}
+
+// findFilename returns the directory entry with exactly the PETSCII filename.
+func (d Disk) findFilename(filename [16]uint8) (DirectoryEntry, error) {
+	entries, err := d.Directory()
+	if err != nil {
+		return DirectoryEntry{}, err
+	}
+	for _, e := range entries {
+		if e.File.FileType != 0x00 && e.File.Filename == filename {
+			return e, nil
+		}
+	}
+	return DirectoryEntry{}, fmt.Errorf("file not found: %s", petscii.Filename(filename[:], petscii.Upper))
+}
+
+// WriteFile saves the data as a new file, allocating the sectors with the
+// standard interleave of the drive, and adding a directory entry. The file
+// type is the value of the directory file type bits, e.g. 2 for a PRG.
+func (d Disk) WriteFile(name string, fileType uint8, data []byte) error {
+	filename, err := PETSCIIName(name)
+	if err != nil {
+		return err
+	}
+	return d.WriteNamedFile(filename, fileType, data)
+}
+
+// WriteNamedFile saves the data as a new file, as WriteFile, using the
+// PETSCII filename exactly as given, padded with $A0.
+func (d Disk) WriteNamedFile(filename [16]uint8, fileType uint8, data []byte) error {
+	name := petscii.Filename(filename[:], petscii.Upper)
+	if _, err := d.findFilename(filename); err == nil {
+		return fmt.Errorf("file exists: %s", name)
+	}
+
+	blocks := (len(data) + 253) / 254
+	if blocks == 0 {
+		blocks = 1
+	}
+	if blocks > d.FreeBlocks() {
+		return fmt.Errorf("disk full: %s needs %d blocks, %d free", name, blocks, d.FreeBlocks())
+	}
+
+	entry, err := d.freeDirectoryEntry()
+	if err != nil {
+		return err
+	}
+
+	sectors, err := d.allocateSectors(blocks)
+	if err != nil {
+		return err
+	}
+
+	d.writeChain(sectors, data)
+
+	entry.File = DirectoryFile{
+		FileType:            0x80 | fileType&0b00000111,
+		FirstSectorLocation: [2]uint8{sectors[0].Track, sectors[0].Sector},
+		Filename:            filename,
+		FileSizeInSectors:   uint16(blocks),
+	}
+
+	return d.writeEntry(entry)
+}
+
+// writeChain stores the data in the sectors, linking each to the next. The
+// last sector holds the position of its last byte in place of the link.
+func (d Disk) writeChain(sectors []Location, data []byte) {
+	for i, l := range sectors {
+		sector, _ := d.Sector(l.Track, l.Sector)
+		*sector = Sector{}
+
+		start := i * 254
+		end := start + 254
+		if end > len(data) {
+			end = len(data)
+		}
+		copy(sector[2:], data[start:end])
+
+		if i < len(sectors)-1 {
+			sector[0] = sectors[i+1].Track
+			sector[1] = sectors[i+1].Sector
+		} else {
+			sector[1] = uint8(end - start + 1)
+		}
+	}
+}
+
+// Scratch deletes the file, freeing its sectors in the BAM. Only the file
+// type byte of the directory entry is cleared, as is done by the DOS.
+func (d Disk) Scratch(name string) error {
+	entry, err := d.FindFile(name)
+	if err != nil {
+		return err
+	}
+	if entry.File.FileTypeFromID().LockedFlag {
+		return fmt.Errorf("file locked: %s", name)
+	}
+
+	sectors, _ := d.fileSectors(entry.File)
+	for _, l := range sectors {
+		_ = d.Free(l)
+	}
+
+	entry.File.FileType = 0x00
+	return d.writeEntry(entry)
+}
+
+// Rename changes the name of a file.
+func (d Disk) Rename(from, to string) error {
+	entry, err := d.FindFile(from)
+	if err != nil {
+		return err
+	}
+	if _, err := d.FindFile(to); err == nil {
+		return fmt.Errorf("file exists: %s", to)
+	}
+
+	if entry.File.Filename, err = PETSCIIName(to); err != nil {
+		return err
+	}
+	return d.writeEntry(entry)
+}
+
+// SetLocked sets or clears the locked flag of a file, which protects it
+// from being scratched.
+func (d Disk) SetLocked(name string, locked bool) error {
+	entry, err := d.FindFile(name)
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		entry.File.FileType |= 0b01000000
+	} else {
+		entry.File.FileType &^= 0b01000000
+	}
+	return d.writeEntry(entry)
+}
+
+// fileSectors returns all sectors used by a file: the data chain, any REL
+// side sectors, or the sectors of a D81 partition. When the data chain is
+// broken, the sectors up to the break are returned with the error.
+func (d Disk) fileSectors(entry DirectoryFile) ([]Location, error) {
+	if entry.FirstSectorLocation[0] == 0 {
+		return nil, nil
+	}
+	if entry.FileType&0b00000111 == 5 {
+		return d.partitionSectors(entry), nil
+	}
+
+	if entry.IsGEOS() {
+		return d.geosSectors(entry)
+	}
+
+	chain := d.FollowChain(Location{Track: entry.FirstSectorLocation[0], Sector: entry.FirstSectorLocation[1]})
+	return append(chain.Sectors, d.sideSectorLocations(entry)...), chain.Err
+}
+
+// partitionSectors returns the contiguous sectors of a D81 partition.
+func (d Disk) partitionSectors(entry DirectoryFile) []Location {
+	var sectors []Location
+
+	l := Location{Track: entry.FirstSectorLocation[0], Sector: entry.FirstSectorLocation[1]}
+	for i := 0; i < int(entry.FileSizeInSectors); i++ {
+		if _, err := d.Sector(l.Track, l.Sector); err != nil {
+			break
+		}
+		sectors = append(sectors, l)
+
+		l.Sector++
+		if int(l.Sector) >= len(d.Tracks[l.Track-1].Sectors) {
+			l = Location{Track: l.Track + 1, Sector: 0}
+		}
+	}
+
+	return sectors
+}
+
+// freeDirectoryEntry returns the first unused directory slot, adding a new
+// sector to the directory chain when all slots are in use.
+func (d Disk) freeDirectoryEntry() (DirectoryEntry, error) {
+	entries, err := d.Directory()
+	if err != nil {
+		return DirectoryEntry{}, err
+	}
+	for _, e := range entries {
+		if e.File.FileType == 0x00 {
+			return e, nil
+		}
+	}
+
+	last := entries[len(entries)-1].Location
+	track := d.dos().directoryTrack
+	spt := len(d.Tracks[track-1].Sectors)
+
+	for i := 0; i < spt; i++ {
+		l := Location{Track: track, Sector: uint8((int(last.Sector) + int(d.dos().directoryInterleave) + i) % spt)}
+		if free, _ := d.IsFree(l); !free {
+			continue
+		}
+		if err := d.Allocate(l); err != nil {
+			return DirectoryEntry{}, err
+		}
+
+		sector, _ := d.Sector(l.Track, l.Sector)
+		*sector = Sector{0x00, 0xFF}
+
+		previous, _ := d.Sector(last.Track, last.Sector)
+		previous[0] = l.Track
+		previous[1] = l.Sector
+
+		return DirectoryEntry{Location: l}, nil
+	}
+
+	return DirectoryEntry{}, fmt.Errorf("directory full")
+}
+
+// allocateSectors allocates the sectors for a new file. Files start on the
+// track closest to the directory, with each following sector placed the
+// interleave distance from the last, moving outwards when a track is full.
+func (d Disk) allocateSectors(count int) ([]Location, error) {
+	var sectors []Location
+
+	tracks := d.allocationTracks()
+	start := 0
+	for t := 0; t < len(tracks) && len(sectors) < count; {
+		track := tracks[t]
+		spt := len(d.Tracks[track-1].Sectors)
+
+		found := false
+		for i := 0; i < spt; i++ {
+			l := Location{Track: track, Sector: uint8((start + i) % spt)}
+			if free, _ := d.IsFree(l); free {
+				_ = d.Allocate(l)
+				sectors = append(sectors, l)
+				start = (int(l.Sector) + int(d.dos().fileInterleave)) % spt
+				found = true
+				break
+			}
+		}
+		if !found {
+			t++
+			start = 0
+		}
+	}
+
+	if len(sectors) < count {
+		for _, l := range sectors {
+			_ = d.Free(l)
+		}
+		return nil, fmt.Errorf("disk full")
+	}
+	return sectors, nil
+}
+
+// allocationTracks returns the tracks available for files, ordered by their
+// distance from the directory track. On a D71 the distance is measured from
+// the directory track position on each side of the disk.
+func (d Disk) allocationTracks() []uint8 {
+	dirTrack := int(d.dos().directoryTrack)
+
+	var tracks []uint8
+	for t := uint8(1); t <= d.dos().bamTracks && int(t) <= len(d.Tracks); t++ {
+		if int(t) == dirTrack || d.isReservedTrack(t) {
+			continue
+		}
+		tracks = append(tracks, t)
+	}
+
+	distance := func(t uint8) int {
+		centre := dirTrack
+		if d.Variation.mediaType == commodore.D71 && t > 35 {
+			centre += 35
+		}
+		if int(t) < centre {
+			return centre - int(t)
+		}
+		return int(t) - centre
+	}
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return distance(tracks[i]) < distance(tracks[j])
+	})
+
+	return tracks
+}
+
+// WriteSector encodes the value into the sector at the location, e.g. a
+// BAM or header structure.
+func (d Disk) WriteSector(l Location, value interface{}) error {
+	sector, err := d.Sector(l.Track, l.Sector)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, value); err != nil {
+		return err
+	}
+	if buf.Len() > len(sector) {
+		return fmt.Errorf("%d bytes will not fit in sector %s", buf.Len(), l)
+	}
+	copy(sector[:], buf.Bytes())
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Package dnp implements reading of CMD native partitions, as used by the
+// CMD FD and HD drives and RAMLink, along with DNP images which hold a single
+// native partition.
+//
+// A DNP image is a copy of a native partition, track by track, making the
+// file size a multiple of 65536 bytes (one track of 256 sectors), up to a
+// maximum of 255 tracks (16711680 bytes).
+package dnp
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore/petscii"
+	"github.com/mrcook/retroio/storage"
+)
+
+// DNP image holding a single native partition.
+type DNP struct {
+	reader *storage.Reader
+
+	native  *Native
+	charset petscii.Charset
+}
+
+func New(reader *storage.Reader) *DNP {
+	return &DNP{reader: reader}
+}
+
+func (d *DNP) Read() error {
+	image := make([]byte, d.reader.FileSize)
+	if _, err := d.reader.Read(image); err != nil {
+		return err
+	}
+
+	var err error
+	d.native, err = NewNative(image)
+	if err != nil {
+		return fmt.Errorf("error reading the partition: %w", err)
+	}
+
+	return nil
+}
+
+// SetCharset sets the PETSCII character set used to display names.
+func (d *DNP) SetCharset(cs petscii.Charset) {
+	d.charset = cs
+}
+
+// SetPath changes to the sub-directory at the path, e.g. "GAMES/".
+func (d *DNP) SetPath(path string) error {
+	return d.native.SetPath(path)
+}
+
+func (d DNP) DisplayGeometry() {
+	fmt.Println("DISK INFORMATION:")
+	fmt.Println()
+	fmt.Printf("Type:        CMD Native Partition\n")
+	fmt.Printf("Size:        %.2fKB\n", float64(d.native.Tracks()*TrackSize)/1024)
+	d.native.DisplayGeometry(d.charset)
+	fmt.Println()
+}
+
+func (d DNP) CommandDir() {
+	fmt.Println("LOAD\"$\",8")
+	fmt.Println("SEARCHING FOR $")
+	fmt.Println("LOADING")
+	fmt.Println("READY.")
+	fmt.Println("LIST")
+	fmt.Println()
+	d.native.CommandDir(d.charset)
+	fmt.Println()
+}
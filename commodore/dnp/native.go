@@ -0,0 +1,315 @@
+// CMD Native Partitions
+//
+// A native partition is made up of tracks of 256 sectors, with up to 255
+// tracks, giving a maximum size of almost 16MB. Track 1 holds the system
+// sectors of the root directory:
+//
+//	1/0:     unused, except as a boot sector
+//	1/1:     the header, holding the disk name and ID
+//	1/2-33:  the BAM, 32 bytes (256 bits) for each track
+//	1/34:    the first directory sector
+//
+// The BAM of track N is stored at offset N*32 from the start of 1/2, so the
+// first 32 bytes of 1/2, where track 0 would be, hold the BAM header with the
+// number of tracks in the partition. The BAM only spans as many sectors as
+// the partition has tracks. Unlike the other Commodore disks, the bits of
+// each byte are stored most significant bit first, and a set bit marks the
+// sector as free.
+//
+// Directory entries have the same 32 byte layout as the D64, with the DIR
+// file type ($86) added for sub-directories. The first T/S of a DIR entry is
+// the header sector of the sub-directory, laid out as the 1/1 header, but
+// with a link back to its parent. Sub-directories share the BAM of the
+// partition, so the blocks free are always those of the whole partition.
+package dnp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/petscii"
+)
+
+const (
+	SectorsPerTrack     = 256
+	TrackSize           = SectorsPerTrack * 256
+	MaxTracks           = 255
+	DirEntriesPerSector = 8
+
+	headerSector        = 1
+	bamSector           = 2
+	bytesPerTrackBitmap = 32
+	dirFileType         = 6 // DIR
+)
+
+// Native is a CMD native mode partition, either a DNP image or a partition
+// of a CMD FD/HD disk.
+type Native struct {
+	data   []byte
+	tracks int
+
+	BAM       BAMHeader
+	root      *Directory
+	directory *Directory
+}
+
+// NewNative reads the native partition stored in the data, which must be a
+// whole number of tracks.
+func NewNative(data []byte) (*Native, error) {
+	if len(data) == 0 || len(data)%TrackSize != 0 || len(data)/TrackSize > MaxTracks {
+		return nil, fmt.Errorf("invalid native partition size: %d bytes", len(data))
+	}
+
+	n := &Native{data: data, tracks: len(data) / TrackSize}
+
+	sector, err := n.sector(1, bamSector)
+	if err != nil {
+		return nil, err
+	}
+	if err := binary.Read(bytes.NewReader(sector), binary.LittleEndian, &n.BAM); err != nil {
+		return nil, fmt.Errorf("error reading the BAM: %w", err)
+	}
+	if n.BAM.LastTrack == 0 || int(n.BAM.LastTrack) > n.tracks {
+		return nil, fmt.Errorf("invalid BAM: %d tracks, partition has %d", n.BAM.LastTrack, n.tracks)
+	}
+
+	n.root, err = n.readDirectory(disk.Location{Track: 1, Sector: headerSector})
+	if err != nil {
+		return nil, err
+	}
+	n.directory = n.root
+
+	return n, nil
+}
+
+// Tracks returns the number of tracks in the partition.
+func (n Native) Tracks() int {
+	return n.tracks
+}
+
+// sector returns the 256 bytes of the sector, with tracks counting from 1.
+func (n Native) sector(track, sector uint8) ([]byte, error) {
+	if track == 0 || int(track) > n.tracks {
+		return nil, fmt.Errorf("invalid track number: %d", track)
+	}
+	offset := (int(track)-1)*TrackSize + int(sector)*256
+	return n.data[offset : offset+256], nil
+}
+
+// bitmap returns the 32 BAM bytes of the track.
+func (n Native) bitmap(track uint8) ([]byte, error) {
+	offset := int(track) * bytesPerTrackBitmap
+	sector, err := n.sector(1, uint8(bamSector+offset/256))
+	if err != nil {
+		return nil, err
+	}
+	return sector[offset%256 : offset%256+bytesPerTrackBitmap], nil
+}
+
+// FreeBlocks returns the number of free sectors in the partition.
+func (n Native) FreeBlocks() int {
+	free := 0
+	for t := 1; t <= int(n.BAM.LastTrack); t++ {
+		bitmap, err := n.bitmap(uint8(t))
+		if err != nil {
+			break
+		}
+		for _, b := range bitmap {
+			for ; b > 0; b &= b - 1 {
+				free++
+			}
+		}
+	}
+	return free
+}
+
+// SetPath changes to the sub-directory at the path, e.g. "GAMES/", or
+// "GAMES/ARCADE/" for a nested sub-directory. An empty path, or "/", is the
+// root directory.
+func (n *Native) SetPath(path string) error {
+	dir := n.root
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		sub, err := n.subDirectory(dir, name)
+		if err != nil {
+			return err
+		}
+		dir = sub
+	}
+
+	n.directory = dir
+	return nil
+}
+
+func (n Native) subDirectory(dir *Directory, name string) (*Directory, error) {
+	for _, entry := range dir.Files {
+		if !strings.EqualFold(entry.PrintableFilename(), name) {
+			continue
+		}
+		if entry.FileType&0b00000111 != dirFileType {
+			return nil, fmt.Errorf("%s is not a directory", name)
+		}
+		header := disk.Location{Track: entry.FirstSectorLocation[0], Sector: entry.FirstSectorLocation[1]}
+		sub, err := n.readDirectory(header)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return sub, nil
+	}
+	return nil, fmt.Errorf("directory not found: %s", name)
+}
+
+// Directory is a native mode directory, with its header and file entries.
+type Directory struct {
+	Location disk.Location // of the header sector
+	Header   Header
+	Files    []disk.DirectoryFile
+}
+
+// readDirectory reads the header at the location, and follows the chain of
+// directory sectors.
+func (n Native) readDirectory(l disk.Location) (*Directory, error) {
+	dir := &Directory{Location: l}
+
+	sector, err := n.sector(l.Track, l.Sector)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the header at %s: %w", l, err)
+	}
+	if err := binary.Read(bytes.NewReader(sector), binary.LittleEndian, &dir.Header); err != nil {
+		return nil, fmt.Errorf("error reading the header at %s: %w", l, err)
+	}
+	if dir.Header.DiskDosVersion != 'H' {
+		return nil, fmt.Errorf("invalid native directory header at %s", l)
+	}
+
+	visited := make(map[disk.Location]bool)
+	next := disk.Location{Track: dir.Header.FirstDirTrack, Sector: dir.Header.FirstDirSector}
+	for next.Track != 0 {
+		if visited[next] {
+			return dir, fmt.Errorf("circular directory chain at %s", next)
+		}
+		visited[next] = true
+
+		sector, err := n.sector(next.Track, next.Sector)
+		if err != nil {
+			return dir, fmt.Errorf("directory chain out of range at %s: %w", next, err)
+		}
+		entries := make([]disk.DirectoryFile, DirEntriesPerSector)
+		if err := binary.Read(bytes.NewReader(sector), binary.LittleEndian, entries); err != nil {
+			return dir, fmt.Errorf("error reading directory entry from sector: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.FileType == 0 && entry.FileSizeInSectors == 0 {
+				continue
+			}
+			dir.Files = append(dir.Files, entry)
+		}
+
+		next = disk.Location{Track: entries[0].NextTrack, Sector: entries[0].NextSector}
+	}
+
+	return dir, nil
+}
+
+// CommandDir prints the listing of the current directory.
+func (n Native) CommandDir(cs petscii.Charset) {
+	h := n.directory.Header
+	fmt.Printf("0 \"%-16s\" %s %c%c\n", h.PrintableDiskName(cs), h.PrintableDiskID(cs), h.DosVersion, h.DiskVersion)
+
+	for _, entry := range n.directory.Files {
+		filename := fmt.Sprintf("\"%s\"", entry.Name(cs))
+		fmt.Printf("%-5d  %-18s %s\n", entry.FileSizeInSectors, filename, entry.FileTypeFromID())
+	}
+
+	fmt.Printf("%d BLOCKS FREE.\n", n.FreeBlocks())
+}
+
+// DisplayGeometry prints the size and BAM details of the partition.
+func (n Native) DisplayGeometry(cs petscii.Charset) {
+	fmt.Printf("Tracks:      %d\n", n.tracks)
+	fmt.Printf("Sectors:     %d\n", n.tracks*SectorsPerTrack)
+	fmt.Printf("BAM Tracks:  %d\n", n.BAM.LastTrack)
+	fmt.Println()
+	fmt.Printf("Name:        %s\n", n.root.Header.PrintableDiskName(cs))
+	fmt.Printf("Files:       %d\n", len(n.root.Files))
+	fmt.Printf("Free Blocks: %d\n", n.FreeBlocks())
+}
+
+// Header Sector
+//
+// The header of the root directory is stored at 1/1, and of a sub-directory
+// at the sector given by its directory entry.
+type Header struct {
+	// Track/Sector location of the first directory sector, 1/34 for the root
+	FirstDirTrack  uint8
+	FirstDirSector uint8
+
+	DiskDosVersion byte // 'H' ($48)
+
+	Unused1 uint8 // ($00)
+
+	// 16 character Disk Name (padded with $A0)
+	DiskName [16]byte
+
+	Filler1 [2]uint8 // Filled with $A0
+
+	DiskID [2]uint8
+
+	Unknown uint8 // Usually $A0
+
+	DosVersion  byte // "1"
+	DiskVersion byte // "H"
+
+	Filler2 [2]uint8 // Filled with $A0
+
+	Unused2 [3]uint8
+
+	// Track/Sector location of this header
+	HeaderTrack  uint8
+	HeaderSector uint8
+
+	// Track/Sector location of the parent directory header, $00/$00 for
+	// the root directory
+	ParentTrack  uint8
+	ParentSector uint8
+
+	// Position of this directory's entry in the parent directory sector
+	ParentEntry uint8
+
+	Unused3 [219]uint8
+}
+
+// PrintableDiskName returns the disk name, with the $A0 padding shown as spaces.
+func (h Header) PrintableDiskName(cs petscii.Charset) string {
+	return petscii.Padded(h.DiskName[:], cs)
+}
+
+// PrintableDiskID returns the disk ID using the PETSCII character set.
+func (h Header) PrintableDiskID(cs petscii.Charset) string {
+	return petscii.Padded(h.DiskID[:], cs)
+}
+
+// BAMHeader is stored in the first 32 bytes of the first BAM sector, 1/2.
+type BAMHeader struct {
+	// Track/Sector location of the next BAM sector, 1/3
+	NextBamTrack  uint8
+	NextBamSector uint8
+
+	DiskDosVersion         uint8 // 'H' ($48)
+	DiskDosVersionInverted uint8 // One's complement of version# ($B7)
+
+	DiskID [2]uint8
+
+	IO       uint8
+	AutoBoot uint8
+
+	// The last track of the partition, i.e. the number of tracks
+	LastTrack uint8
+
+	Reserved [23]uint8
+}
@@ -0,0 +1,180 @@
+// Package g64 implements reading of Commodore G64 GCR disk images, as
+// specified at: https://ist.uwaterloo.ca/~schepers/formats/G64.TXT
+//
+// The G64 format was designed by Per Hakan Sundell for the CCS64 emulator,
+// and holds the raw GCR encoded bits of each track, as read by the 1541 drive
+// head. This preserves the copy protection of many original disks, which use
+// non-standard sector layouts, extra tracks, half-tracks, and deliberately
+// bad sectors.
+//
+// The file starts with a 12 byte header, followed by a table of the offsets
+// of each half-track, and a table of their speed zones. Each track starts
+// with its length in bytes (a WORD), followed by the GCR data.
+//
+// Note: all WORD and DWORD values are stored in low/high byte order.
+package g64
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/d64"
+	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/gcr"
+	"github.com/mrcook/retroio/commodore/petscii"
+	"github.com/mrcook/retroio/storage"
+)
+
+const signature = "GCR-1541"
+
+// Header of the G64 file.
+type Header struct {
+	Signature    [8]byte // "GCR-1541"
+	Version      uint8   // $00
+	HalfTracks   uint8   // number of half-tracks, usually 84 (42 tracks)
+	MaxTrackSize uint16  // largest track size in bytes, usually 7928
+}
+
+// HalfTrack is the GCR data of a track, or half-track.
+type HalfTrack struct {
+	Number float32 // track number, e.g. 18 or 18.5
+
+	// Speed zone, 0-3 (slowest to fastest). Values above 3 are the offset of
+	// a table giving the speed of every byte of the track.
+	Speed uint32
+
+	Data []byte
+}
+
+// G64 disk image, with its tracks decoded to sectors.
+type G64 struct {
+	reader *storage.Reader
+
+	Header     Header
+	HalfTracks []HalfTrack
+
+	disk *disk.Disk
+	d64  *d64.D64
+
+	charset petscii.Charset
+}
+
+func New(reader *storage.Reader) *G64 {
+	return &G64{reader: reader}
+}
+
+// Read the G64 file and decode the GCR data of the full tracks into sectors.
+func (g *G64) Read() error {
+	image := make([]byte, g.reader.FileSize)
+	if _, err := g.reader.Read(image); err != nil {
+		return err
+	}
+
+	if err := binary.Read(bytes.NewReader(image), binary.LittleEndian, &g.Header); err != nil {
+		return fmt.Errorf("error reading the header: %w", err)
+	}
+	if string(g.Header.Signature[:]) != signature {
+		return fmt.Errorf("invalid G64 signature: '%s'", g.Header.Signature)
+	}
+
+	tables := make([]uint32, int(g.Header.HalfTracks)*2)
+	if err := binary.Read(bytes.NewReader(image[12:]), binary.LittleEndian, tables); err != nil {
+		return fmt.Errorf("error reading the track tables: %w", err)
+	}
+
+	for i := 0; i < int(g.Header.HalfTracks); i++ {
+		ht := HalfTrack{Number: 1 + float32(i)/2, Speed: tables[int(g.Header.HalfTracks)+i]}
+
+		offset := int(tables[i])
+		if offset > 0 {
+			if offset+2 > len(image) {
+				return fmt.Errorf("track %.1f: offset is beyond the end of the file", ht.Number)
+			}
+			size := int(binary.LittleEndian.Uint16(image[offset:]))
+			if offset+2+size > len(image) {
+				return fmt.Errorf("track %.1f: data is beyond the end of the file", ht.Number)
+			}
+			ht.Data = image[offset+2 : offset+2+size]
+		}
+
+		g.HalfTracks = append(g.HalfTracks, ht)
+	}
+
+	var err error
+	g.disk, err = gcr.Decode(func(track uint8) []byte {
+		if ht := g.track(track); ht != nil {
+			return ht.Data
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	g.d64, err = d64.NewFromDisk(g.disk)
+	if err != nil {
+		return fmt.Errorf("error reading the directory: %w", err)
+	}
+	g.d64.SetCharset(g.charset)
+
+	return nil
+}
+
+// track returns the full track, or nil when it is not in the image.
+func (g G64) track(number uint8) *HalfTrack {
+	index := int(number-1) * 2
+	if index >= len(g.HalfTracks) || len(g.HalfTracks[index].Data) == 0 {
+		return nil
+	}
+	return &g.HalfTracks[index]
+}
+
+// Disk returns the decoded disk, which includes error bytes for any sectors
+// that could not be read without errors.
+func (g G64) Disk() *disk.Disk {
+	return g.disk
+}
+
+// SetCharset sets the PETSCII character set used to display names.
+func (g *G64) SetCharset(cs petscii.Charset) {
+	g.charset = cs
+	if g.d64 != nil {
+		g.d64.SetCharset(cs)
+	}
+}
+
+// DisplayGeometry prints the G64 tracks, followed by the decoded disk.
+func (g G64) DisplayGeometry() {
+	fmt.Println("G64 INFORMATION:")
+	fmt.Println()
+	fmt.Printf("Version:     %d\n", g.Header.Version)
+	fmt.Printf("Half-tracks: %d\n", g.Header.HalfTracks)
+	fmt.Printf("Track Size:  %d bytes max\n", g.Header.MaxTrackSize)
+	fmt.Println()
+
+	for _, ht := range g.HalfTracks {
+		if len(ht.Data) == 0 {
+			continue
+		}
+		speed := fmt.Sprintf("zone %d", ht.Speed)
+		if ht.Speed > 3 {
+			speed = fmt.Sprintf("speed map at $%X", ht.Speed)
+		}
+		syncs, longest := gcr.SyncStats(ht.Data)
+		fmt.Printf("Track %4.1f: %5d bytes, %s, %d syncs (longest %d bits)\n", ht.Number, len(ht.Data), speed, syncs, longest)
+	}
+	fmt.Println()
+
+	g.d64.DisplayGeometry()
+}
+
+func (g G64) CommandDir() {
+	g.d64.CommandDir()
+}
+
+// ExtractFiles reads the files of the decoded disk.
+func (g G64) ExtractFiles() []commodore.File {
+	return g.d64.ExtractFiles()
+}
@@ -0,0 +1,168 @@
+package gcr
+
+import (
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/disk"
+)
+
+const (
+	standardTracks = 35
+	maxTracks      = 42
+)
+
+// TrackData returns the GCR data of a full track, or nil when the track is
+// not in the image.
+type TrackData func(track uint8) []byte
+
+// decoder holds the sectors and read errors found on each track.
+type decoder struct {
+	sectors map[disk.Location][]byte
+	errors  map[disk.Location]uint8
+	id      [2]uint8 // disk ID from the track 18 sector headers
+}
+
+// Decode the tracks into a D64 disk, recording the read errors the 1541
+// would report for each sector in the error bytes. Error bytes are only
+// included when there are errors. Tracks 36-42 are only included when they
+// contain sectors, as 40 or 42 track disks.
+func Decode(track TrackData) (*disk.Disk, error) {
+	d := decoder{
+		sectors: make(map[disk.Location][]byte),
+		errors:  make(map[disk.Location]uint8),
+	}
+
+	// as the DOS does, the disk ID is taken from the first header on track 18
+	for _, b := range (bitstream{data: track(18)}).decodeBlocks() {
+		if b.header != nil && b.valid && b.header.track == 18 {
+			d.id = b.header.id
+			break
+		}
+	}
+
+	tracks := standardTracks
+	for t := uint8(1); t <= maxTracks; t++ {
+		found := d.decodeTrack(t, track(t))
+		if t > standardTracks && found > 0 {
+			tracks = 40
+			if t > 40 {
+				tracks = 42
+			}
+		}
+	}
+
+	errs := d.errorsOnTracks(tracks)
+	dsk, err := disk.NewBlankTracks(commodore.D64, uint8(tracks), len(errs) > 0)
+	if err != nil {
+		return nil, err
+	}
+	for l, data := range d.sectors {
+		if sector, err := dsk.Sector(l.Track, l.Sector); err == nil {
+			copy(sector[:], data)
+		}
+	}
+	for l, code := range errs {
+		_ = dsk.SetSectorError(l, code)
+	}
+
+	return dsk, nil
+}
+
+// decodeTrack decodes the sectors of the track, returning the number found.
+// Each header is matched with the data block which follows it. Only the
+// first copy of a sector is used.
+func (d *decoder) decodeTrack(track uint8, data []byte) int {
+	spt := SectorsPerTrack(track)
+
+	found := make(map[uint8]bool)
+	setError := func(sector, code uint8) {
+		l := disk.Location{Track: track, Sector: sector}
+		if _, ok := d.errors[l]; !ok {
+			d.errors[l] = code
+		}
+	}
+
+	bits := bitstream{data: data}
+	if len(bits.syncs()) == 0 {
+		for s := 0; s < spt; s++ {
+			setError(uint8(s), disk.CodeNoSync)
+		}
+		return 0
+	}
+	blocks := bits.decodeBlocks()
+
+	for i, b := range blocks {
+		h := b.header
+		if h == nil || h.track != track || int(h.sector) >= spt || found[h.sector] {
+			continue
+		}
+		switch {
+		case !h.checksum:
+			setError(h.sector, disk.CodeHeaderChecksum)
+		case h.id != d.id:
+			setError(h.sector, disk.CodeIDMismatch)
+		}
+
+		next := blocks[(i+1)%len(blocks)]
+		if !next.isData {
+			setError(h.sector, disk.CodeDataNotFound)
+			continue
+		}
+		if !next.valid {
+			setError(h.sector, disk.CodeDataChecksum)
+		}
+		// the data is kept even when the sector has an error, as the
+		// protection checks of many disks still read it
+		found[h.sector] = true
+		d.sectors[disk.Location{Track: track, Sector: h.sector}] = next.data
+	}
+
+	for s := 0; s < spt; s++ {
+		if !found[uint8(s)] {
+			setError(uint8(s), disk.CodeHeaderNotFound)
+		}
+	}
+
+	return len(found)
+}
+
+// errorsOnTracks returns the sector errors found on the tracks kept for the
+// disk. Missing extra tracks are not errors.
+func (d decoder) errorsOnTracks(tracks int) map[disk.Location]uint8 {
+	errs := make(map[disk.Location]uint8)
+	for l, code := range d.errors {
+		if int(l.Track) <= tracks {
+			errs[l] = code
+		}
+	}
+	return errs
+}
+
+// SectorsPerTrack returns the sectors of the track in the standard 1541
+// speed zones.
+func SectorsPerTrack(track uint8) int {
+	switch {
+	case track <= 17:
+		return 21
+	case track <= 24:
+		return 19
+	case track <= 30:
+		return 18
+	default:
+		return 17
+	}
+}
+
+// Density returns the standard speed zone of the track, 3 (fastest) for
+// the outer tracks, to 0 for the inner tracks.
+func Density(track uint8) int {
+	switch {
+	case track <= 17:
+		return 3
+	case track <= 24:
+		return 2
+	case track <= 30:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,193 @@
+// Package gcr decodes the Group Code Recording data of 1541 disk tracks, as
+// stored in raw disk images such as G64 and NIB, into sectors.
+//
+// The 1541 writes data to disk using Group Code Recording, where every 4 bit
+// nybble is written as 5 bits, so that there are never more than two 0 bits
+// in a row, nor more than eight 1 bits. Each 4 bytes of data become 5 bytes
+// on disk.
+//
+// A run of at least ten 1 bits is a SYNC mark, which can not occur in GCR
+// data, and is written before each sector header and data block. The drive
+// hardware aligns the bytes following a SYNC, but as a G64 holds the bits as
+// read from the disk, the blocks may start at any bit position.
+//
+// After decoding, the sector header is 8 bytes:
+//
+//	$00:     header block ID ($08)
+//	$01:     checksum, the EOR of the sector, track, and ID bytes
+//	$02:     sector
+//	$03:     track
+//	$04-$05: disk ID, second character first
+//	$06-$07: off bytes ($0F)
+//
+// The data block is 260 bytes:
+//
+//	$000:     data block ID ($07)
+//	$001-100: sector data
+//	$101:     checksum, the EOR of the sector data
+//	$102-103: off bytes ($00)
+package gcr
+
+const (
+	headerBlockID = 0x08
+	dataBlockID   = 0x07
+
+	headerGCRBytes = 10  // 8 bytes
+	dataGCRBytes   = 325 // 260 bytes
+
+	minSyncBits = 10
+)
+
+// gcrDecode converts a 5 bit GCR code to its nybble. Invalid codes are $FF.
+var gcrDecode = [32]uint8{
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+	0xFF, 0x08, 0x00, 0x01, 0xFF, 0x0C, 0x04, 0x05,
+	0xFF, 0xFF, 0x02, 0x03, 0xFF, 0x0F, 0x06, 0x07,
+	0xFF, 0x09, 0x0A, 0x0B, 0xFF, 0x0D, 0x0E, 0xFF,
+}
+
+// bitstream of a track, which wraps around to the start, as the track is
+// a circle on the disk.
+type bitstream struct {
+	data []byte
+}
+
+func (b bitstream) length() int {
+	return len(b.data) * 8
+}
+
+func (b bitstream) bit(pos int) uint8 {
+	pos %= b.length()
+	return (b.data[pos/8] >> (7 - pos%8)) & 1
+}
+
+// syncMark is a SYNC found on the track: the bit position which follows
+// it, and its length in bits.
+type syncMark struct {
+	end  int
+	bits int
+}
+
+// syncs returns the SYNC marks on the track.
+func (b bitstream) syncs() []syncMark {
+	var marks []syncMark
+
+	n := b.length()
+	if n == 0 {
+		return nil
+	}
+
+	// start counting from the first 0 bit, so that a SYNC which wraps around
+	// the end of the track is found
+	start := 0
+	for start < n && b.bit(start) == 1 {
+		start++
+	}
+	if start == n {
+		return nil // no 0 bits, the track is all SYNC
+	}
+
+	// the loop ends back at the first 0 bit, which ends any SYNC wrapping
+	// around the end of the track
+	ones := 0
+	for i := start; i <= start+n; i++ {
+		if b.bit(i) == 1 {
+			ones++
+			continue
+		}
+		if ones >= minSyncBits {
+			marks = append(marks, syncMark{end: i % n, bits: ones})
+		}
+		ones = 0
+	}
+
+	return marks
+}
+
+// SyncStats returns the number of SYNC marks on the track, and the length
+// of the longest in bits. Standard tracks have two SYNC marks per sector, of
+// about 40 bits each.
+func SyncStats(data []byte) (count, longest int) {
+	marks := bitstream{data: data}.syncs()
+	for _, m := range marks {
+		if m.bits > longest {
+			longest = m.bits
+		}
+	}
+	return len(marks), longest
+}
+
+// decode reads the GCR bytes starting at the bit position, returning the
+// decoded data, and false when any of the GCR codes were invalid.
+func (b bitstream) decode(pos, gcrBytes int) ([]byte, bool) {
+	data := make([]byte, gcrBytes*4/5)
+	valid := true
+
+	for i := 0; i < len(data)*2; i++ {
+		code := uint8(0)
+		for j := 0; j < 5; j++ {
+			code = code<<1 | b.bit(pos)
+			pos++
+		}
+		nybble := gcrDecode[code]
+		if nybble == 0xFF {
+			valid = false
+			nybble = 0
+		}
+		if i%2 == 0 {
+			data[i/2] = nybble << 4
+		} else {
+			data[i/2] |= nybble
+		}
+	}
+
+	return data, valid
+}
+
+// sectorHeader is a decoded header block.
+type sectorHeader struct {
+	sector   uint8
+	track    uint8
+	id       [2]uint8 // in disk order: second ID character first
+	checksum bool
+}
+
+// block found after a SYNC mark: either a header, or the data block which
+// follows it.
+type block struct {
+	header *sectorHeader
+	data   []byte
+	valid  bool // GCR codes were valid, and the checksum matched
+	isData bool
+}
+
+// decodeBlocks decodes the header and data blocks following each SYNC mark.
+// Blocks with an unknown ID byte are ignored.
+func (b bitstream) decodeBlocks() []block {
+	var blocks []block
+
+	for _, mark := range b.syncs() {
+		pos := mark.end
+		id, _ := b.decode(pos, 5)
+		switch id[0] {
+		case headerBlockID:
+			data, valid := b.decode(pos, headerGCRBytes)
+			h := &sectorHeader{
+				sector: data[2],
+				track:  data[3],
+				id:     [2]uint8{data[4], data[5]},
+			}
+			h.checksum = valid && data[1] == data[2]^data[3]^data[4]^data[5]
+			blocks = append(blocks, block{header: h, valid: h.checksum})
+		case dataBlockID:
+			data, valid := b.decode(pos, dataGCRBytes)
+			checksum := uint8(0)
+			for _, c := range data[1:257] {
+				checksum ^= c
+			}
+			blocks = append(blocks, block{data: data[1:257], valid: valid && checksum == data[257], isData: true})
+		}
+	}
+
+	return blocks
+}
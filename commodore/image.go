@@ -1,5 +1,7 @@
 package commodore
 
+import "github.com/mrcook/retroio/commodore/petscii"
+
 // Custom type for the different storage mediums
 type MediaType uint8
 
@@ -10,6 +12,14 @@ const (
 	D64 // all variations: 35, 40, and 42 tracks
 	D71
 	D81
+	G64
+	NIB
+	D80 // CBM 8050
+	D82 // CBM 8250
+	D1M // CMD FD-2000 DD
+	D2M // CMD FD-2000 HD
+	D4M // CMD FD-4000 ED
+	DNP // CMD native partition
 )
 
 type Image interface {
@@ -17,3 +27,42 @@ type Image interface {
 	DisplayGeometry()
 	CommandDir()
 }
+
+// CharsetSelector is implemented by the media types which display PETSCII
+// names, allowing the character set to be chosen.
+type CharsetSelector interface {
+	SetCharset(cs petscii.Charset)
+}
+
+// PathSelector is implemented by the media types which support
+// sub-directories, allowing the directory to be chosen.
+type PathSelector interface {
+	SetPath(path string) error
+}
+
+// FileExtractor is implemented by the media types that store named files
+// which can be extracted.
+type FileExtractor interface {
+	Image
+	ExtractFiles() []File
+}
+
+// File is a single file as stored on a disk or tape image. For PRG files the
+// Data starts with the two byte load address, exactly as stored on the media.
+type File struct {
+	Name     string // filename as stored on the media, without padding
+	Filename []byte // the PETSCII filename, without padding
+	Type     string // file type label, e.g. "prg", "seq"
+	Data     []byte
+	Warnings []string // problems found reading the file, Data holds what could be read
+
+	RecordLength uint8 // REL files only, the length of each record
+}
+
+// LoadAddress returns the load address stored in the first two bytes of the data.
+func (f File) LoadAddress() (uint16, bool) {
+	if len(f.Data) < 2 {
+		return 0, false
+	}
+	return uint16(f.Data[0]) | uint16(f.Data[1])<<8, true
+}
@@ -0,0 +1,236 @@
+// Package nib implements reading of the NIB raw nibble dumps of 1541 disks,
+// as created by the nibtools (MNIB) disk copying tools.
+//
+// A NIB file starts with a 256 byte header, followed by 8192 bytes of data
+// for each track read from the disk. As the data is read directly from the
+// drive head, with the hardware aligning the bytes after each SYNC mark, the
+// data of each track holds more than one revolution of the disk, starting
+// at a random position.
+//
+// Header layout:
+//
+//	$00-$0C: signature "MNIB-1541-RAW"
+//	$0D:     version, 1-3
+//	$0E:     reserved
+//	$0F:     flags
+//	$10-$FF: track entries, two bytes each: the half-track number (track 1
+//	         is half-track 2), and the density the track was read with. A
+//	         half-track number of $00 ends the list.
+//
+// Density byte: bits 0-1 are the speed zone (3 fastest, 0 slowest), bit 7
+// is set for a killer track (all SYNC), and bit 6 for a track without SYNC.
+package nib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/d64"
+	"github.com/mrcook/retroio/commodore/disk"
+	"github.com/mrcook/retroio/commodore/gcr"
+	"github.com/mrcook/retroio/commodore/petscii"
+	"github.com/mrcook/retroio/storage"
+)
+
+const (
+	signature = "MNIB-1541-RAW"
+	trackSize = 0x2000
+
+	densityMask   = 0b00000011
+	killerTrack   = 0b10000000
+	noSyncTrack   = 0b01000000
+	minTrackBytes = 5000 // shortest revolution searched for when aligning
+)
+
+// Header of the NIB file.
+type Header struct {
+	Signature [13]byte // "MNIB-1541-RAW"
+	Version   uint8
+	Reserved  uint8
+	Flags     uint8
+	Entries   [120]TrackEntry
+}
+
+// TrackEntry gives the half-track number, and the density of a track.
+type TrackEntry struct {
+	HalfTrack uint8
+	Density   uint8
+}
+
+// Track is the data read from a track, and its single revolution once
+// aligned to the first sector header.
+type Track struct {
+	Number  float32 // e.g. 18 or 18.5
+	Density uint8
+	Data    []byte // raw data, more than one revolution
+	Aligned []byte // one revolution, starting at a sector header
+}
+
+// NIB disk image, with its tracks decoded to sectors.
+type NIB struct {
+	reader *storage.Reader
+
+	Header Header
+	Tracks []Track
+
+	disk *disk.Disk
+	d64  *d64.D64
+
+	charset petscii.Charset
+}
+
+func New(reader *storage.Reader) *NIB {
+	return &NIB{reader: reader}
+}
+
+// Read the NIB file, align the data of each track, and decode the GCR data
+// of the full tracks into sectors.
+func (n *NIB) Read() error {
+	image := make([]byte, n.reader.FileSize)
+	if _, err := n.reader.Read(image); err != nil {
+		return err
+	}
+
+	if err := binary.Read(bytes.NewReader(image), binary.LittleEndian, &n.Header); err != nil {
+		return fmt.Errorf("error reading the header: %w", err)
+	}
+	if string(n.Header.Signature[:]) != signature {
+		return fmt.Errorf("invalid NIB signature: '%s'", n.Header.Signature)
+	}
+
+	for i, e := range n.Header.Entries {
+		if e.HalfTrack == 0 {
+			break
+		}
+		offset := 0x100 + i*trackSize
+		if offset+trackSize > len(image) {
+			return fmt.Errorf("track %.1f: data is beyond the end of the file", float32(e.HalfTrack)/2)
+		}
+		data := image[offset : offset+trackSize]
+
+		n.Tracks = append(n.Tracks, Track{
+			Number:  float32(e.HalfTrack) / 2,
+			Density: e.Density,
+			Data:    data,
+			Aligned: alignTrack(data),
+		})
+	}
+
+	var err error
+	n.disk, err = gcr.Decode(func(track uint8) []byte {
+		if t := n.track(track); t != nil {
+			return t.Aligned
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	n.d64, err = d64.NewFromDisk(n.disk)
+	if err != nil {
+		return fmt.Errorf("error reading the directory: %w", err)
+	}
+	n.d64.SetCharset(n.charset)
+
+	return nil
+}
+
+// alignTrack finds a single revolution of the track, starting at the SYNC
+// of the first sector header. The end of the revolution is found where the
+// same header is read again. When no header repeats, such as on unformatted
+// or killer tracks, the data is returned as read.
+func alignTrack(data []byte) []byte {
+	for p := 1; p+minTrackBytes+10 <= len(data); p++ {
+		// headers start with $52 after the SYNC, the GCR code of $08
+		if data[p] != 0x52 || data[p-1] != 0xFF {
+			continue
+		}
+		header := data[p : p+10]
+
+		q := bytes.Index(data[p+minTrackBytes:], header)
+		if q < 0 {
+			continue
+		}
+		length := q + minTrackBytes
+
+		start := p
+		for start > 0 && data[start-1] == 0xFF {
+			start--
+		}
+		return data[start : start+length]
+	}
+	return data
+}
+
+// track returns the full track, or nil when it is not in the image.
+func (n NIB) track(number uint8) *Track {
+	for i, t := range n.Tracks {
+		if t.Number == float32(number) {
+			return &n.Tracks[i]
+		}
+	}
+	return nil
+}
+
+// Disk returns the decoded disk, which includes error bytes for any sectors
+// that could not be read without errors.
+func (n NIB) Disk() *disk.Disk {
+	return n.disk
+}
+
+// SetCharset sets the PETSCII character set used to display names.
+func (n *NIB) SetCharset(cs petscii.Charset) {
+	n.charset = cs
+	if n.d64 != nil {
+		n.d64.SetCharset(cs)
+	}
+}
+
+// DisplayGeometry prints the density and SYNC usage of each track, which
+// show the tracks used for copy protection, followed by the decoded disk.
+func (n NIB) DisplayGeometry() {
+	fmt.Println("NIB INFORMATION:")
+	fmt.Println()
+	fmt.Printf("Version:     %d\n", n.Header.Version)
+	fmt.Printf("Tracks:      %d\n", len(n.Tracks))
+	fmt.Println()
+
+	for _, t := range n.Tracks {
+		density := int(t.Density & densityMask)
+		syncs, longest := gcr.SyncStats(t.Aligned)
+
+		info := fmt.Sprintf("Track %4.1f: density %d, ", t.Number, density)
+		if len(t.Aligned) < len(t.Data) {
+			info += fmt.Sprintf("%d bytes/rev, ", len(t.Aligned))
+		} else {
+			info += "not aligned, "
+		}
+		info += fmt.Sprintf("%d syncs (longest %d bits)", syncs, longest)
+
+		if t.Number == float32(int(t.Number)) && density != gcr.Density(uint8(t.Number)) {
+			info += ", non-standard density"
+		}
+		if t.Density&killerTrack > 0 {
+			info += ", killer track"
+		}
+		if t.Density&noSyncTrack > 0 || syncs == 0 {
+			info += ", no sync"
+		}
+		fmt.Println(info)
+	}
+	fmt.Println()
+
+	n.d64.DisplayGeometry()
+}
+
+func (n NIB) CommandDir() {
+	n.d64.CommandDir()
+}
+
+// ExtractFiles reads the files of the decoded disk.
+func (n NIB) ExtractFiles() []commodore.File {
+	return n.d64.ExtractFiles()
+}
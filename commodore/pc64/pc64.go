@@ -0,0 +1,139 @@
+// Package pc64 implements reading and writing of the P00, S00, U00, R00 and
+// D00 container files, first used by the PC64 emulator, as specified at:
+// https://ist.uwaterloo.ca/~schepers/formats/PC64.TXT
+//
+// Each file holds a single C64 file, with a 26 byte header giving the real
+// PETSCII filename, which the filename on the host system could not hold:
+//
+//	$00-$07: signature "C64File", followed by $00
+//	$08-$17: C64 filename, in PETSCII, padded with $00
+//	$18:     always $00, ending the filename
+//	$19:     REL file record size, $00 for other file types
+//
+// The file type is given by the first letter of the extension, with the
+// two digits used to keep host filenames unique, e.g. GAME.P00, GAME.P01.
+package pc64
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/petscii"
+)
+
+// Signature is the start of every PC64 file.
+const Signature = "C64File\x00"
+
+const headerSize = 26
+
+// file types by the first letter of the extension
+var fileTypes = map[byte]string{
+	'p': "prg",
+	's': "seq",
+	'u': "usr",
+	'r': "rel",
+	'd': "del",
+}
+
+// Header is the PC64 file header.
+type Header struct {
+	Signature  [8]byte
+	Filename   [16]byte // PETSCII, padded with $00
+	Terminator uint8    // always $00
+	RecordSize uint8    // REL files only
+}
+
+// Name returns the filename, without the padding.
+func (h Header) Name(cs petscii.Charset) string {
+	return petscii.String(h.PETSCIIName(), cs)
+}
+
+// PETSCIIName returns the PETSCII filename, without the padding. Some tools
+// pad the name with $A0, as in a directory entry, which is also removed.
+func (h Header) PETSCIIName() []byte {
+	name := h.Filename[:]
+	for len(name) > 0 && (name[len(name)-1] == 0x00 || name[len(name)-1] == 0xA0) {
+		name = name[:len(name)-1]
+	}
+	return name
+}
+
+// IsPC64 reports whether the extension is that of a PC64 file, e.g. ".p00".
+func IsPC64(extension string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(extension, "."))
+	if len(ext) != 3 || ext[1] < '0' || ext[1] > '9' || ext[2] < '0' || ext[2] > '9' {
+		return false
+	}
+	_, ok := fileTypes[ext[0]]
+	return ok
+}
+
+// Extension returns the extension for the file type, e.g. "p00", with n
+// giving the two digits. Types with no PC64 letter are stored as USR files.
+func Extension(fileType string, n int) string {
+	letter := byte('u')
+	for l, t := range fileTypes {
+		if t == strings.ToLower(fileType) {
+			letter = l
+		}
+	}
+	return fmt.Sprintf("%c%02d", letter, n%100)
+}
+
+// Read decodes a PC64 file, taking the file type from the extension.
+func Read(data []byte, extension string) (commodore.File, Header, error) {
+	var header Header
+
+	if !IsPC64(extension) {
+		return commodore.File{}, header, fmt.Errorf("not a PC64 file extension: %s", extension)
+	}
+	if len(data) < headerSize {
+		return commodore.File{}, header, fmt.Errorf("invalid PC64 file - too short")
+	}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		return commodore.File{}, header, err
+	}
+	if string(header.Signature[:]) != Signature {
+		return commodore.File{}, header, fmt.Errorf("invalid PC64 file - signature not found")
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(extension, "."))
+	file := commodore.File{
+		Name:     header.Name(petscii.Upper),
+		Filename: header.PETSCIIName(),
+		Type:     fileTypes[ext[0]],
+		Data:     data[headerSize:],
+	}
+	if file.Type == "rel" {
+		file.RecordLength = header.RecordSize
+		if header.RecordSize == 0 {
+			file.Warnings = append(file.Warnings, "REL file has a record length of 0")
+		}
+	} else if header.RecordSize != 0 {
+		file.Warnings = append(file.Warnings, fmt.Sprintf("record size of %d given for a %s file", header.RecordSize, strings.ToUpper(file.Type)))
+	}
+
+	return file, header, nil
+}
+
+// Bytes encodes the file as a PC64 file, with the header holding its name
+// and REL record size. The PETSCII filename of the file is used, when known,
+// otherwise the name is converted back to PETSCII.
+func Bytes(file commodore.File) []byte {
+	name := file.Filename
+	if name == nil {
+		name = petscii.Bytes(file.Name, petscii.Upper)
+	}
+
+	header := Header{RecordSize: file.RecordLength}
+	copy(header.Signature[:], Signature)
+	copy(header.Filename[:], name)
+
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, header)
+	buf.Write(file.Data)
+	return buf.Bytes()
+}
@@ -0,0 +1,132 @@
+package petscii
+
+// Characters of the uppercase/graphics set which differ from ASCII. Codes
+// $C0-$DF and $E0-$FE are shown with their duplicates at $60-$7F and $A0-$BE.
+var graphics = map[byte]rune{
+	0x5C: '£',
+	0x5E: '↑',
+	0x5F: '←',
+
+	0x60: '─',
+	0x61: '♠',
+	0x62: '\U0001FB72',
+	0x63: '\U0001FB78',
+	0x64: '\U0001FB77',
+	0x65: '\U0001FB76',
+	0x66: '\U0001FB7A',
+	0x67: '\U0001FB71',
+	0x68: '\U0001FB74',
+	0x69: '╮',
+	0x6A: '╰',
+	0x6B: '╯',
+	0x6C: '\U0001FB7C',
+	0x6D: '╲',
+	0x6E: '╱',
+	0x6F: '\U0001FB7D',
+	0x70: '\U0001FB7E',
+	0x71: '●',
+	0x72: '\U0001FB7B',
+	0x73: '♥',
+	0x74: '\U0001FB70',
+	0x75: '╭',
+	0x76: '╳',
+	0x77: '○',
+	0x78: '♣',
+	0x79: '\U0001FB75',
+	0x7A: '♦',
+	0x7B: '┼',
+	0x7C: '\U0001FB8C',
+	0x7D: '│',
+	0x7E: 'π',
+	0x7F: '◥',
+
+	0xA0: ' ',
+	0xA1: '▌',
+	0xA2: '▄',
+	0xA3: '▔',
+	0xA4: '▁',
+	0xA5: '▏',
+	0xA6: '▒',
+	0xA7: '▕',
+	0xA8: '\U0001FB8F',
+	0xA9: '◤',
+	0xAA: '\U0001FB87',
+	0xAB: '├',
+	0xAC: '▗',
+	0xAD: '└',
+	0xAE: '┐',
+	0xAF: '▂',
+	0xB0: '┌',
+	0xB1: '┴',
+	0xB2: '┬',
+	0xB3: '┤',
+	0xB4: '▎',
+	0xB5: '▍',
+	0xB6: '\U0001FB88',
+	0xB7: '\U0001FB82',
+	0xB8: '\U0001FB83',
+	0xB9: '▃',
+	0xBA: '\U0001FB7F',
+	0xBB: '▖',
+	0xBC: '▝',
+	0xBD: '┘',
+	0xBE: '▘',
+	0xBF: '▚',
+}
+
+// Characters of the lowercase/uppercase set which differ from the
+// uppercase/graphics set, other than the letters.
+var lowerGraphics = map[byte]rune{
+	0x7E: '\U0001FB96',
+	0x7F: '\U0001FB98',
+	0xA9: '\U0001FB99',
+	0xBA: '✓',
+}
+
+// Names of the control characters, as used in listings.
+var controlCodes = map[byte]string{
+	0x03: "stop",
+	0x05: "wht",
+	0x07: "bell",
+	0x08: "lock",
+	0x09: "unlock",
+	0x0A: "lf",
+	0x0D: "return",
+	0x0E: "lower",
+	0x11: "down",
+	0x12: "rvs on",
+	0x13: "home",
+	0x14: "del",
+	0x1B: "esc",
+	0x1C: "red",
+	0x1D: "rght",
+	0x1E: "grn",
+	0x1F: "blu",
+	0x81: "orng",
+	0x85: "f1",
+	0x86: "f3",
+	0x87: "f5",
+	0x88: "f7",
+	0x89: "f2",
+	0x8A: "f4",
+	0x8B: "f6",
+	0x8C: "f8",
+	0x8D: "shift return",
+	0x8E: "upper",
+	0x90: "blk",
+	0x91: "up",
+	0x92: "rvs off",
+	0x93: "clr",
+	0x94: "inst",
+	0x95: "brn",
+	0x96: "lred",
+	0x97: "gry1",
+	0x98: "gry2",
+	0x99: "lgrn",
+	0x9A: "lblu",
+	0x9B: "gry3",
+	0x9C: "pur",
+	0x9D: "left",
+	0x9E: "yel",
+	0x9F: "cyn",
+}
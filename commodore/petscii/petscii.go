@@ -0,0 +1,174 @@
+// Package petscii converts the PETSCII characters of the Commodore 8-bit
+// computers to Unicode.
+//
+// The C64 has two character sets: uppercase/graphics (the power-on default)
+// and lowercase/uppercase, switched with the Commodore and Shift keys. Block
+// graphics which have no equivalent in the older Unicode blocks are mapped to
+// the Symbols for Legacy Computing block (U+1FB00-U+1FBFF).
+//
+// Control characters (colours, cursor movement, etc.) have no glyph, and are
+// returned by name in braces, e.g. {clr}, as used in listings.
+package petscii
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset is one of the two C64 character sets.
+type Charset int
+
+const (
+	Upper Charset = iota // uppercase/graphics
+	Lower                // lowercase/uppercase
+)
+
+func (c Charset) String() string {
+	if c == Lower {
+		return "lower"
+	}
+	return "upper"
+}
+
+// ParseCharset returns the character set for the name: upper or lower.
+func ParseCharset(name string) (Charset, error) {
+	switch strings.ToLower(name) {
+	case "upper", "graphics", "":
+		return Upper, nil
+	case "lower":
+		return Lower, nil
+	}
+	return Upper, fmt.Errorf("unknown character set: '%s', expected upper or lower", name)
+}
+
+// Rune returns the Unicode character for a PETSCII code, or false for the
+// control characters.
+func Rune(c byte, cs Charset) (rune, bool) {
+	switch {
+	case c < 0x20, c >= 0x80 && c < 0xA0:
+		return 0, false
+	case c == 0xFF:
+		// same as $DE
+		c = 0xDE
+	case c >= 0xE0:
+		// $E0-$FE are the same as $A0-$BE
+		c -= 0x40
+	case c >= 0xC0:
+		// $C0-$DF are the same as $60-$7F
+		c -= 0x60
+	}
+
+	if cs == Lower {
+		switch {
+		case c >= 0x41 && c <= 0x5A:
+			return rune(c) + 0x20, true
+		case c >= 0x61 && c <= 0x7A:
+			return rune(c) - 0x20, true
+		}
+		if r, ok := lowerGraphics[c]; ok {
+			return r, true
+		}
+	}
+
+	if r, ok := graphics[c]; ok {
+		return r, true
+	}
+	return rune(c), true
+}
+
+// Character returns the PETSCII code as a string, with control characters
+// given by name in braces, e.g. {clr}, or by value, e.g. {$02}.
+func Character(c byte, cs Charset) string {
+	if r, ok := Rune(c, cs); ok {
+		return string(r)
+	}
+	if name, ok := controlCodes[c]; ok {
+		return "{" + name + "}"
+	}
+	return fmt.Sprintf("{$%02x}", c)
+}
+
+// String converts the PETSCII data to a string.
+func String(data []byte, cs Charset) string {
+	var str strings.Builder
+	for _, c := range data {
+		str.WriteString(Character(c, cs))
+	}
+	return str.String()
+}
+
+// Filename converts a filename, which ends at the first shifted space ($A0)
+// used as padding in directory entries.
+func Filename(name []byte, cs Charset) string {
+	for i, c := range name {
+		if c == 0xA0 {
+			name = name[:i]
+			break
+		}
+	}
+	return String(name, cs)
+}
+
+// Padded converts a name padded with shifted spaces ($A0), such as a disk
+// name, showing the padding as spaces.
+func Padded(name []byte, cs Charset) string {
+	var str strings.Builder
+	for _, c := range name {
+		if c == 0xA0 {
+			c = 0x20
+		}
+		str.WriteString(Character(c, cs))
+	}
+	return str.String()
+}
+
+// Bytes converts a string, as returned by String, back to PETSCII. Control
+// characters are given by name or value in braces, e.g. {clr} or {$02}, and
+// characters with no PETSCII code are replaced by a question mark.
+func Bytes(str string, cs Charset) []byte {
+	var data []byte
+
+	for len(str) > 0 {
+		if str[0] == '{' {
+			if end := strings.IndexByte(str, '}'); end > 0 {
+				if c, ok := controlCode(str[1:end]); ok {
+					data = append(data, c)
+					str = str[end+1:]
+					continue
+				}
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(str)
+		str = str[size:]
+
+		data = append(data, runeCode(r, cs))
+	}
+
+	return data
+}
+
+// runeCode returns the PETSCII code of the character, using the first
+// code for characters which appear more than once.
+func runeCode(r rune, cs Charset) byte {
+	for c := 0x20; c < 0xC0; c++ {
+		if v, ok := Rune(byte(c), cs); ok && v == r {
+			return byte(c)
+		}
+	}
+	return '?'
+}
+
+func controlCode(name string) (byte, bool) {
+	var value byte
+	if _, err := fmt.Sscanf(name, "$%02x", &value); err == nil {
+		return value, true
+	}
+	for c, n := range controlCodes {
+		if n == name {
+			return c, true
+		}
+	}
+	return 0, false
+}
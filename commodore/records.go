@@ -0,0 +1,99 @@
+package commodore
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// Records splits the data of a REL file into its fixed length records.
+// Records which have never been written hold a single $FF followed by zeros;
+// these are dropped from the end of the file.
+func (f File) Records() [][]byte {
+	if f.RecordLength == 0 {
+		return nil
+	}
+
+	var records [][]byte
+	size := int(f.RecordLength)
+	for i := 0; i+size <= len(f.Data); i += size {
+		records = append(records, f.Data[i:i+size])
+	}
+
+	for len(records) > 0 && unusedRecord(records[len(records)-1]) {
+		records = records[:len(records)-1]
+	}
+
+	return records
+}
+
+// RecordsCSV returns the records as CSV, one row per record. The fields of a
+// record are separated by a carriage return, as written by PRINT#.
+func (f File) RecordsCSV() (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	for i, record := range f.Records() {
+		row := []string{fmt.Sprintf("%d", i+1)}
+		if !unusedRecord(record) {
+			record = bytes.TrimRight(record, "\x00")
+			for _, field := range bytes.Split(bytes.TrimSuffix(record, []byte{0x0D}), []byte{0x0D}) {
+				row = append(row, recordText(field))
+			}
+		}
+		if err := w.Write(row); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+
+	return buf.String(), w.Error()
+}
+
+// RecordsHex returns a hex dump of each record, with the printable
+// characters shown alongside.
+func (f File) RecordsHex() string {
+	var str strings.Builder
+
+	for i, record := range f.Records() {
+		fmt.Fprintf(&str, "RECORD %d:\n", i+1)
+		for pos := 0; pos < len(record); pos += 16 {
+			end := pos + 16
+			if end > len(record) {
+				end = len(record)
+			}
+			line := record[pos:end]
+			fmt.Fprintf(&str, "  %04X  % -47X  %s\n", pos, line, recordText(line))
+		}
+	}
+
+	return str.String()
+}
+
+// unusedRecord checks for the $FF, $00... marker of a record never written.
+func unusedRecord(record []byte) bool {
+	if len(record) == 0 || record[0] != 0xFF {
+		return false
+	}
+	for _, b := range record[1:] {
+		if b != 0x00 {
+			return false
+		}
+	}
+	return true
+}
+
+// recordText shows the printable characters of a record, replacing
+// everything else with a dot.
+func recordText(data []byte) string {
+	text := make([]byte, len(data))
+	for i, c := range data {
+		if c >= 0x20 && c < 0x7F {
+			text[i] = c
+		} else {
+			text[i] = '.'
+		}
+	}
+	return string(text)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 
+	"github.com/mrcook/retroio/commodore/petscii"
 	"github.com/mrcook/retroio/storage"
 )
 
@@ -22,8 +23,14 @@ func (h *Header) Read(reader *storage.Reader) error {
 }
 
 func (h Header) String() string {
+	return h.Describe(petscii.Upper)
+}
+
+// Describe returns the header details, with the tape name shown using the
+// PETSCII character set.
+func (h Header) Describe(cs petscii.Charset) string {
 	str := ""
-	str += fmt.Sprintf("Name:            %s\n", h.Name)
+	str += fmt.Sprintf("Name:            %s\n", petscii.Padded(h.Name[:], cs))
 	str += fmt.Sprintf("Version:         $%04x\n", h.Version)
 	str += fmt.Sprintf("Max Directories: %d\n", h.MaxEntries)
 	str += fmt.Sprintf("Used Entries:    %d\n", h.UsedEntries)
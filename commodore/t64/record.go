@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/mrcook/retroio/commodore/petscii"
 	"github.com/mrcook/retroio/storage"
 )
 
@@ -60,8 +61,14 @@ func (r *Record) DataRead(reader *storage.Reader, dataOffset int) ([]byte, error
 }
 
 func (r Record) String() string {
+	return r.Describe(petscii.Upper)
+}
+
+// Describe returns the record details, with the filename shown using the
+// PETSCII character set.
+func (r Record) Describe(cs petscii.Charset) string {
 	str := ""
-	str += fmt.Sprintf("Filename:      %s\n", r.Filename)
+	str += fmt.Sprintf("Filename:      %s\n", r.Name(cs))
 	str += fmt.Sprintf("Type:          %s: %s\n", r.fileTypeLabel(r.FileType), r.entryTypeLabel(r.Type))
 	str += fmt.Sprintf("Start Address: %d\n", r.StartAddress)
 	str += fmt.Sprintf("End Address:   %d\n", r.EndAddress)
@@ -70,6 +77,26 @@ func (r Record) String() string {
 	return str
 }
 
+// Blocks returns the number of 254 byte blocks the file would use on a disk,
+// including its two byte load address.
+func (r Record) Blocks() int {
+	return (int(r.EndAddress-r.StartAddress) + 2 + 253) / 254
+}
+
+// Name returns the filename, without the padding.
+func (r Record) Name(cs petscii.Charset) string {
+	return petscii.String(r.PETSCIIName(), cs)
+}
+
+// PETSCIIName returns the PETSCII filename, without the padding.
+func (r Record) PETSCIIName() []byte {
+	name := r.Filename[:]
+	for len(name) > 0 && (name[len(name)-1] == 0x20 || name[len(name)-1] == 0xA0) {
+		name = name[:len(name)-1]
+	}
+	return name
+}
+
 func (r Record) entryTypeLabel(id byte) string {
 	var label string
 	switch id {
@@ -93,13 +120,19 @@ func (r Record) entryTypeLabel(id byte) string {
 
 func (r Record) fileTypeLabel(id byte) string {
 	var label string
-	switch id {
-	case 0x81:
+	switch {
+	case r.IsSnapshot():
+		label = "FRZ" // C64s frozen session snapshot
+	case id == 0x81:
 		label = "SEQ"
-	case 0x82:
-		label = "PRG"
 	default:
-		label = "???" // what about USR value?
+		label = "PRG" // any other value is treated as a PRG
 	}
 	return label
 }
+
+// IsSnapshot reports whether the record is a C64s frozen session snapshot:
+// a 1541 file type of $00, with a C64s entry type above 1.
+func (r Record) IsSnapshot() bool {
+	return r.FileType == 0x00 && r.Type > 1
+}
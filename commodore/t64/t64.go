@@ -14,6 +14,8 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/petscii"
 	"github.com/mrcook/retroio/storage"
 )
 
@@ -25,6 +27,10 @@ type T64 struct {
 	Records []Record // File records for 32*n directory entries
 	Data    [][]byte // Binary data for the records
 
+	// Problems found with each record, such as a corrected end address
+	warnings map[int][]string
+
+	charset petscii.Charset
 }
 
 func New(reader *storage.Reader) *T64 {
@@ -51,6 +57,8 @@ func (t *T64) Read() error {
 		return t.Records[i].Offset < t.Records[j].Offset
 	})
 
+	t.fixEndAddresses()
+
 	if err := t.readDataEntries(); err != nil {
 		return err
 	}
@@ -58,14 +66,23 @@ func (t *T64) Read() error {
 	return nil
 }
 
+// SetCharset sets the PETSCII character set used to display names.
+func (t *T64) SetCharset(cs petscii.Charset) {
+	t.charset = cs
+}
+
 // DisplayGeometry prints the tape metadata and record headers to the terminal.
 func (t T64) DisplayGeometry() {
 	fmt.Println("HEADER INFORMATION:")
-	fmt.Println(t.Header)
+	fmt.Println(t.Header.Describe(t.charset))
 
 	for i, r := range t.Records {
 		fmt.Printf("RECORD #%d:\n", i)
-		fmt.Println(r)
+		fmt.Print(r.Describe(t.charset))
+		for _, w := range t.warnings[i] {
+			fmt.Printf("WARNING:       %s\n", w)
+		}
+		fmt.Println()
 	}
 
 	for i, r := range t.Data {
@@ -73,8 +90,106 @@ func (t T64) DisplayGeometry() {
 	}
 }
 
+// CommandDir prints the records as a C64 directory listing, with the size
+// of each file in blocks, as it would be stored on a disk.
 func (t T64) CommandDir() {
-	fmt.Println("directory listing unsupported")
+	fmt.Println("LOAD\"$\",8")
+	fmt.Println("SEARCHING FOR $")
+	fmt.Println("LOADING")
+	fmt.Println("READY.")
+	fmt.Println("LIST")
+	fmt.Println()
+	fmt.Printf("0 \"%-24s\" T64\n", petscii.Padded(t.Header.Name[:], t.charset))
+
+	used := 0
+	for _, r := range t.Records {
+		if r.Type == 0x00 {
+			continue
+		}
+		used++
+		filename := fmt.Sprintf("\"%s\"", r.Name(t.charset))
+		fmt.Printf("%-3d  %-18s %s\n", r.Blocks(), filename, r.fileTypeLabel(r.FileType))
+	}
+
+	free := int(t.Header.MaxEntries) - used
+	if free < 0 {
+		free = 0
+	}
+	fmt.Printf("%d ENTRIES FREE.\n", free)
+	fmt.Println()
+}
+
+// ExtractFiles returns the files stored on the tape. The load address is
+// added to the start of PRG data, as it would be stored on a disk.
+func (t T64) ExtractFiles() []commodore.File {
+	var files []commodore.File
+
+	for i, r := range t.Records {
+		if r.Type == 0x00 || i >= len(t.Data) {
+			continue
+		}
+
+		file := commodore.File{
+			Name:     r.Name(petscii.Upper),
+			Filename: r.PETSCIIName(),
+			Type:     "prg",
+			Warnings: t.warnings[i],
+		}
+		switch {
+		case r.IsSnapshot():
+			// snapshots have no load address, and are written as stored
+			file.Type = "frz"
+			file.Data = t.Data[i]
+		case r.FileType == 0x81:
+			file.Type = "seq"
+			file.Data = t.Data[i]
+		default:
+			file.Data = append([]byte{uint8(r.StartAddress), uint8(r.StartAddress >> 8)}, t.Data[i]...)
+		}
+		files = append(files, file)
+	}
+
+	return files
+}
+
+// fixEndAddresses corrects the end address of records which extend beyond
+// the start of the next record, or the end of the file. Many T64 files were
+// created by tools which wrote a wrong end address, commonly $C3C6, for
+// every record, so the real length can only be found from the offsets.
+// The records must be sorted by their offset.
+func (t *T64) fixEndAddresses() {
+	t.warnings = make(map[int][]string)
+
+	for i, r := range t.Records {
+		if r.Type == 0x00 {
+			continue
+		}
+
+		// the data ends at the next record, or the end of the file
+		end := uint32(t.reader.FileSize)
+		for _, next := range t.Records[i+1:] {
+			if next.Type != 0x00 && next.Offset > r.Offset {
+				end = next.Offset
+				break
+			}
+		}
+		if r.Offset >= end {
+			continue
+		}
+		available := end - r.Offset
+
+		length := uint32(r.EndAddress) - uint32(r.StartAddress)
+		if r.EndAddress > r.StartAddress && length <= available {
+			continue
+		}
+		if uint32(r.StartAddress)+available > 0xFFFF {
+			available = 0xFFFF - uint32(r.StartAddress)
+		}
+
+		endAddress := r.StartAddress + uint16(available)
+		t.warnings[i] = append(t.warnings[i], fmt.Sprintf("end address $%04X is wrong, using $%04X from the file offsets", r.EndAddress, endAddress))
+		t.Records[i].EndAddress = endAddress
+	}
 }
 
 // readDataEntries reads the data for each record.
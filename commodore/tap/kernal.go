@@ -0,0 +1,402 @@
+// Kernal ROM Loader
+//
+// The C64 Kernal writes data to tape using three pulse lengths, which on a
+// PAL C64 are close to these TAP values:
+//
+//	Short  (S): $30, 384 cycles
+//	Medium (M): $42, 528 cycles
+//	Long   (L): $56, 688 cycles
+//
+// Each block starts with a pilot (leader) of short pulses, followed by the
+// bytes of the block. A byte starts with a byte marker (L M), followed by its
+// eight bits, least significant bit first, and a parity bit making the number
+// of 1 bits odd. A 0 bit is stored as S M, and a 1 bit as M S. The end of the
+// block is marked with L S.
+//
+// Every block is written twice. The first copy starts with a countdown
+// sequence of the bytes $89-$81, and the repeated copy with $09-$01. The
+// countdown is followed by the data, and a checksum byte: all of the data
+// bytes XOR'ed together.
+//
+// A file is made of a 192 byte header block, holding the file type, the
+// start and end address and the filename, followed by a data block. The
+// data of sequential files is instead stored in a series of 192 byte blocks,
+// each starting with the type byte $02.
+package tap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mrcook/retroio/commodore/petscii"
+)
+
+const (
+	headerBlockSize = 192
+	minPilotPulses  = 50
+	countdownLength = 9
+
+	// pilot pulses may vary from their average by this fraction
+	pilotTolerance = 0.2
+)
+
+// KernalLoader is the name of the standard Kernal ROM loader.
+const KernalLoader = "CBM Kernal"
+
+// Kernal header block types
+const (
+	HeaderBasicProgram   = 1 // relocatable program, loaded to the start of BASIC
+	HeaderDataBlock      = 2 // data block of a sequential file
+	HeaderProgram        = 3 // non-relocatable program
+	HeaderSequentialFile = 4 // sequential file header
+	HeaderEndOfTape      = 5 // end-of-tape marker
+)
+
+// HeaderTypes are the labels of the Kernal header types.
+var HeaderTypes = map[uint8]string{
+	HeaderBasicProgram:   "PRG",
+	HeaderDataBlock:      "DATA",
+	HeaderProgram:        "PRG",
+	HeaderSequentialFile: "SEQ",
+	HeaderEndOfTape:      "EOT",
+}
+
+// Header is the start of a Kernal header block, the remaining bytes of the
+// 192 byte block are usually filled with spaces.
+type Header struct {
+	Type         uint8
+	StartAddress uint16
+	EndAddress   uint16
+	Filename     [16]byte // PETSCII, padded with $20
+}
+
+func newHeader(data []byte) (Header, bool) {
+	if len(data) != headerBlockSize || data[0] < HeaderBasicProgram || data[0] > HeaderEndOfTape || data[0] == HeaderDataBlock {
+		return Header{}, false
+	}
+	h := Header{
+		Type:         data[0],
+		StartAddress: uint16(data[1]) | uint16(data[2])<<8,
+		EndAddress:   uint16(data[3]) | uint16(data[4])<<8,
+	}
+	copy(h.Filename[:], data[5:21])
+	return h, true
+}
+
+// TypeLabel returns the file type of the header, e.g. PRG.
+func (h Header) TypeLabel() string {
+	return HeaderTypes[h.Type]
+}
+
+// Name returns the filename, without the padding.
+func (h Header) Name(cs petscii.Charset) string {
+	return petscii.String(h.PETSCIIName(), cs)
+}
+
+// PETSCIIName returns the PETSCII filename, without the padding.
+func (h Header) PETSCIIName() []byte {
+	name := h.Filename[:]
+	for len(name) > 0 && (name[len(name)-1] == 0x20 || name[len(name)-1] == 0xA0) {
+		name = name[:len(name)-1]
+	}
+	return name
+}
+
+// Block is a single copy of a block written by the Kernal.
+type Block struct {
+	Offset      int // offset of the pilot in the TAP data
+	PilotPulses int
+	Repeat      bool // the second copy of the block
+
+	Data     []byte // without the countdown and checksum
+	Checksum uint8
+	Errors   []string // parity errors and unreadable pulses
+
+	first, last int // range of pulses used by the block, including the pilot
+}
+
+// ChecksumOK reports whether the checksum matches the data.
+func (b Block) ChecksumOK() bool {
+	var sum uint8
+	for _, v := range b.Data {
+		sum ^= v
+	}
+	return sum == b.Checksum
+}
+
+// OK reports whether the block was read without errors.
+func (b Block) OK() bool {
+	return len(b.Errors) == 0 && b.ChecksumOK()
+}
+
+// File is a file found on the tape, with its header and data.
+type File struct {
+	Offset   int // offset of the first block of the file in the TAP data
+	Header   Header
+	Data     []byte
+	Loader   string   // the loader used to write the file
+	Warnings []string // read errors, with Data holding what could be read
+}
+
+// findPilot returns the index of the first pulse of the next pilot tone at
+// or after the start pulse, and its number of pulses and average length.
+// A pilot is a run of pulses of almost the same length, which must be
+// followed by a longer pulse.
+func findPilot(pulses []Pulse, start int) (int, int, float64) {
+	first, sum := start, 0.0
+	for i := start; i < len(pulses); i++ {
+		cycles := float64(pulses[i].Cycles)
+		count := i - first
+
+		if count > 0 {
+			avg := sum / float64(count)
+			if cycles > avg*(1-pilotTolerance) && cycles < avg*(1+pilotTolerance) {
+				sum += cycles
+				continue
+			}
+			if count >= minPilotPulses && cycles > avg {
+				return first, count, avg
+			}
+		}
+
+		first, sum = i, cycles
+	}
+	return 0, 0, 0
+}
+
+// pulseDecoder reads the Kernal bytes from the pulses, using thresholds
+// scaled from the length of the short pilot pulses. This allows for tapes
+// recorded at a different speed, and other machines.
+type pulseDecoder struct {
+	pulses []Pulse
+	pos    int
+
+	shortMedium float64 // threshold between short and medium pulses
+	mediumLong  float64 // threshold between medium and long pulses
+	maxLong     float64 // pulses longer than this are not data
+}
+
+const (
+	pulseShort = iota
+	pulseMedium
+	pulseLong
+	pulseInvalid
+)
+
+func newPulseDecoder(pulses []Pulse, pos int, short float64) *pulseDecoder {
+	return &pulseDecoder{
+		pulses:      pulses,
+		pos:         pos,
+		shortMedium: short * 1.19, // M is ~1.375 times S
+		mediumLong:  short * 1.58, // L is ~1.79 times S
+		maxLong:     short * 2.2,
+	}
+}
+
+// next returns the type of the next pulse.
+func (d *pulseDecoder) next() int {
+	if d.pos >= len(d.pulses) {
+		return pulseInvalid
+	}
+	cycles := float64(d.pulses[d.pos].Cycles)
+	d.pos++
+
+	switch {
+	case cycles < d.shortMedium:
+		return pulseShort
+	case cycles < d.mediumLong:
+		return pulseMedium
+	case cycles < d.maxLong:
+		return pulseLong
+	default:
+		return pulseInvalid
+	}
+}
+
+// offset returns the TAP data offset of the next pulse.
+func (d *pulseDecoder) offset() int {
+	if d.pos >= len(d.pulses) {
+		return d.pulses[len(d.pulses)-1].Offset + dataOffset
+	}
+	return d.pulses[d.pos].Offset + dataOffset
+}
+
+var errParity = errors.New("parity error")
+
+// readByte reads the next byte, returning end at the end-of-data marker.
+// On a parity error the value is still returned, along with errParity.
+func (d *pulseDecoder) readByte() (value uint8, end bool, err error) {
+	if d.next() != pulseLong {
+		return 0, false, fmt.Errorf("missing byte marker")
+	}
+	switch d.next() {
+	case pulseMedium:
+	case pulseShort:
+		return 0, true, nil // end-of-data marker
+	default:
+		return 0, false, fmt.Errorf("invalid byte marker")
+	}
+
+	parity := uint8(1)
+	for bit := 0; bit < 9; bit++ {
+		var b uint8
+		switch p1, p2 := d.next(), d.next(); {
+		case p1 == pulseShort && p2 == pulseMedium:
+			b = 0
+		case p1 == pulseMedium && p2 == pulseShort:
+			b = 1
+		default:
+			return value, false, fmt.Errorf("invalid bit pulses")
+		}
+
+		if bit < 8 {
+			value |= b << bit
+			parity ^= b
+		} else if b != parity {
+			return value, false, errParity
+		}
+	}
+
+	return value, false, nil
+}
+
+// readBlock reads the bytes following a pilot, returning false when the
+// countdown sequence is not found, so the pulses were not written by the
+// Kernal loader.
+func (d *pulseDecoder) readBlock() (Block, bool) {
+	var data []byte
+	var errs []string
+
+	for {
+		offset := d.offset()
+		value, end, err := d.readByte()
+		if end {
+			break
+		}
+		if err == errParity {
+			errs = append(errs, fmt.Sprintf("$%06X: %s", offset, err))
+		} else if err != nil {
+			if len(data) > countdownLength {
+				errs = append(errs, fmt.Sprintf("$%06X: %s, block truncated", offset, err))
+			}
+			break
+		}
+		data = append(data, value)
+	}
+
+	if len(data) < countdownLength+1 {
+		return Block{}, false
+	}
+
+	block := Block{Repeat: data[0] == 0x09}
+	for i := 0; i < countdownLength; i++ {
+		expected := uint8(0x89 - i)
+		if block.Repeat {
+			expected = uint8(0x09 - i)
+		}
+		if data[i] != expected {
+			return Block{}, false
+		}
+	}
+
+	block.Data = data[countdownLength : len(data)-1]
+	block.Checksum = data[len(data)-1]
+	block.Errors = errs
+
+	return block, true
+}
+
+// decodeKernal finds all blocks written by the Kernal loader.
+func decodeKernal(pulses []Pulse) []Block {
+	var blocks []Block
+
+	for i := 0; i < len(pulses); {
+		start, count, avg := findPilot(pulses, i)
+		if count == 0 {
+			break
+		}
+
+		d := newPulseDecoder(pulses, start+count, avg)
+		block, ok := d.readBlock()
+		if !ok {
+			i = start + count
+			continue
+		}
+		block.Offset = pulses[start].Offset + dataOffset
+		block.PilotPulses = count
+		block.first, block.last = start, d.pos
+		blocks = append(blocks, block)
+
+		// the end marker may be the start of the next pilot
+		i = d.pos - 1
+	}
+
+	return blocks
+}
+
+// kernalFiles joins the header and data blocks into files, using the first
+// copy of each block, or the repeated copy when the first has errors.
+func kernalFiles(blocks []Block) []File {
+	// pick the best copy of each block
+	var best []Block
+	for i := 0; i < len(blocks); i++ {
+		b := blocks[i]
+		if !b.Repeat && i+1 < len(blocks) && blocks[i+1].Repeat {
+			if !b.OK() && blocks[i+1].OK() {
+				b = blocks[i+1]
+			}
+			i++
+		}
+		best = append(best, b)
+	}
+
+	var files []File
+	for i := 0; i < len(best); i++ {
+		header, ok := newHeader(best[i].Data)
+		if !ok || header.Type == HeaderEndOfTape {
+			continue
+		}
+
+		file := File{Offset: best[i].Offset, Header: header, Loader: KernalLoader}
+		file.Warnings = append(file.Warnings, blockWarnings("header", best[i])...)
+
+		switch header.Type {
+		case HeaderBasicProgram, HeaderProgram:
+			if i+1 >= len(best) {
+				file.Warnings = append(file.Warnings, "data block not found")
+				break
+			}
+			if _, isHeader := newHeader(best[i+1].Data); isHeader {
+				file.Warnings = append(file.Warnings, "data block not found")
+				break
+			}
+			i++
+			file.Data = best[i].Data
+			file.Warnings = append(file.Warnings, blockWarnings("data", best[i])...)
+			if length := int(header.EndAddress) - int(header.StartAddress); length != len(file.Data) {
+				file.Warnings = append(file.Warnings, fmt.Sprintf("data is %d bytes, header gives %d bytes", len(file.Data), length))
+			}
+		case HeaderSequentialFile:
+			for i+1 < len(best) && len(best[i+1].Data) == headerBlockSize && best[i+1].Data[0] == HeaderDataBlock {
+				i++
+				file.Data = append(file.Data, best[i].Data[1:]...)
+				file.Warnings = append(file.Warnings, blockWarnings("data", best[i])...)
+			}
+		}
+
+		files = append(files, file)
+	}
+
+	return files
+}
+
+func blockWarnings(name string, b Block) []string {
+	var warnings []string
+	for _, e := range b.Errors {
+		warnings = append(warnings, fmt.Sprintf("%s block: %s", name, e))
+	}
+	if !b.ChecksumOK() {
+		warnings = append(warnings, fmt.Sprintf("%s block at $%06X: checksum error", name, b.Offset))
+	}
+	return warnings
+}
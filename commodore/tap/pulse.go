@@ -0,0 +1,97 @@
+// TAP Pulses
+//
+// Each byte of the data is the length of a pulse, the time between two
+// falling edges of the signal, in units of 8 CPU clock cycles. A byte value
+// of $00 marks a pulse too long to be stored in a single byte:
+//
+//	v0: an overflow, with no length given. This is used for pauses, and
+//	    is taken as 256*8 cycles.
+//	v1: the following three bytes hold the exact length of the pulse in
+//	    clock cycles, as a little endian 24-bit value.
+//	v2: as v1, but each value is the length of a half-wave, the time
+//	    between a falling and a rising edge, or the reverse.
+package tap
+
+// overflowCycles is the length taken for a v0 overflow pulse.
+const overflowCycles = 256 * 8
+
+// CPU clock rates of the C64, in cycles per second.
+const (
+	ClockPAL  = 985248
+	ClockNTSC = 1022727
+)
+
+// CPU clock rates of the VIC-20 and C16, in cycles per second.
+const (
+	ClockVIC20PAL  = 1108405
+	ClockVIC20NTSC = 1022727
+	ClockC16PAL    = 886724
+	ClockC16NTSC   = 894886
+)
+
+// Pulse is a single pulse of the tape signal.
+type Pulse struct {
+	Offset int    // offset of the pulse in the TAP data
+	Cycles uint32 // length of the pulse in CPU clock cycles
+}
+
+// decodePulses converts the TAP data into pulse lengths, as given by the TAP
+// version. A truncated v1 overflow at the end of the data is ignored.
+func decodePulses(data []byte, version uint8) []Pulse {
+	pulses := make([]Pulse, 0, len(data))
+
+	for i := 0; i < len(data); i++ {
+		p := Pulse{Offset: i, Cycles: uint32(data[i]) * 8}
+
+		if data[i] == 0x00 {
+			if version == 0 {
+				p.Cycles = overflowCycles
+			} else {
+				if i+3 >= len(data) {
+					break
+				}
+				p.Cycles = uint32(data[i+1]) | uint32(data[i+2])<<8 | uint32(data[i+3])<<16
+				i += 3
+			}
+		}
+
+		pulses = append(pulses, p)
+	}
+
+	return pulses
+}
+
+// encodePulses converts the pulse lengths, in clock cycles, into TAP data of
+// the given version. Pulses too long for a single byte are stored as a v0
+// overflow, or as a v1 24-bit value, split into several pulses when needed.
+func encodePulses(cycles []uint32, version uint8) []byte {
+	data := make([]byte, 0, len(cycles))
+
+	for _, c := range cycles {
+		value := (c + 4) / 8
+		switch {
+		case value == 0:
+			data = append(data, 1)
+		case value <= 0xFF:
+			data = append(data, uint8(value))
+		case version == 0:
+			data = append(data, 0x00)
+		default:
+			for ; c > 0xFFFFFF; c -= 0xFFFFFF {
+				data = append(data, 0x00, 0xFF, 0xFF, 0xFF)
+			}
+			data = append(data, 0x00, uint8(c), uint8(c>>8), uint8(c>>16))
+		}
+	}
+
+	return data
+}
+
+// joinHalfWaves joins each pair of v2 half-waves into a full pulse.
+func joinHalfWaves(halfWaves []Pulse) []Pulse {
+	pulses := make([]Pulse, 0, len(halfWaves)/2)
+	for i := 0; i+1 < len(halfWaves); i += 2 {
+		pulses = append(pulses, Pulse{Offset: halfWaves[i].Offset, Cycles: halfWaves[i].Cycles + halfWaves[i+1].Cycles})
+	}
+	return pulses
+}
@@ -6,6 +6,12 @@
 // bit for bit. Since it is simply a representation of the raw serial data
 // from a tape, it should handle *any* custom tape loaders that exist.
 //
+// The header also gives the machine the tape was recorded on, a C64, VIC-20
+// or C16, and its video standard, which together set the clock rate of the
+// pulse lengths. The VIC-20 and C16 ROM loaders write their blocks in the
+// same format as the C64 Kernal, only with different timings. C16 tapes are
+// stored as v2 half-waves, which are joined into full pulses for decoding.
+//
 // The TAP images are generally very large, being a minimum of eight times,
 // and up to sixteen times as large as what a raw PRG file would be. This is
 // due to the way the data is stored, with each bit of the original file now
@@ -18,19 +24,56 @@ package tap
 import (
 	"fmt"
 	"io"
+	"sort"
+	"time"
 
+	"github.com/mrcook/retroio/commodore"
+	"github.com/mrcook/retroio/commodore/petscii"
 	"github.com/mrcook/retroio/storage"
 )
 
+// dataOffset is the size of the header, and the file offset of the data.
+const dataOffset = 0x14
+
+// Machine types
+const (
+	MachineC64   = 0
+	MachineVIC20 = 1
+	MachineC16   = 2
+)
+
+// Video standards
+const (
+	VideoPAL  = 0
+	VideoNTSC = 1
+)
+
+// MachineNames are the labels of the machine types.
+var MachineNames = map[uint8]string{
+	MachineC64:   "C64",
+	MachineVIC20: "VIC-20",
+	MachineC16:   "C16",
+}
+
 // TAP File structure
 type TAP struct {
 	reader *storage.Reader
 
 	Signature [12]byte // File signature "C64-TAPE-RAW"
 	Version   uint8    // TAP version: $00 original layout, $01 updated.
-	Unused    [3]byte  // Future expansion
+	Machine   uint8    // Machine: $00 C64, $01 VIC-20, $02 C16
+	Video     uint8    // Video standard: $00 PAL, $01 NTSC
+	Unused    uint8    // Future expansion
 	DataSize  uint32   // File data size (not including this header)
 	Data      []byte   // File data: 0014-xxxx
+
+	Pulses []Pulse // the decoded pulse lengths of the data
+	Blocks []Block // blocks written by the Kernal loader
+	Files  []File  // files joined from the header and data blocks
+
+	Segments []Segment // parts of the tape, and the loader used for each
+
+	charset petscii.Charset
 }
 
 func New(reader *storage.Reader) *TAP {
@@ -42,35 +85,154 @@ func (t *TAP) Read() error {
 		return err
 	}
 	t.Version = t.reader.ReadByte()
-	if _, err := t.reader.Read(t.Unused[:]); err != nil {
-		return err
-	}
+	t.Machine = t.reader.ReadByte()
+	t.Video = t.reader.ReadByte()
+	t.Unused = t.reader.ReadByte()
 	t.DataSize = t.reader.ReadLong()
 
 	t.Data = make([]byte, t.DataSize)
-	_, err := t.reader.Read(t.Data)
-	if err != nil && err != io.EOF {
+	n, err := t.reader.Read(t.Data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return err
 	}
+	t.Data = t.Data[:n]
+
+	t.Pulses = decodePulses(t.Data, t.Version)
+
+	pulses := t.Pulses
+	if t.Version == 2 {
+		pulses = joinHalfWaves(t.Pulses)
+	}
+	t.Blocks = decodeKernal(pulses)
+	t.Files = kernalFiles(t.Blocks)
+
+	// the timings of the known turbo loaders are for the C64
+	var loaders []turboLoader
+	if t.Machine == MachineC64 {
+		loaders = turboLoaders
+	}
+	t.Segments = findSegments(pulses, t.Blocks)
+	t.Files = append(t.Files, decodeTurbo(loaders, pulses, t.Segments, t.Blocks)...)
+	t.Segments = mergeSegments(t.Segments)
+	sort.SliceStable(t.Files, func(i, j int) bool { return t.Files[i].Offset < t.Files[j].Offset })
 
 	return nil
 }
 
-// DisplayGeometry prints the tape metadata to the terminal.
+// SetCharset sets the PETSCII character set used to display names.
+func (t *TAP) SetCharset(cs petscii.Charset) {
+	t.charset = cs
+}
+
+// DisplayGeometry prints the tape metadata, and the blocks and files found
+// on the tape, to the terminal.
 func (t TAP) DisplayGeometry() {
 	fmt.Println("HEADER INFORMATION:")
 	fmt.Println(t)
+
+	fmt.Println("SEGMENTS:")
+	fmt.Println()
+	for _, s := range t.Segments {
+		info := fmt.Sprintf("  $%06X-$%06X: %-14s %7d pulses", s.Offset, s.End, s.Loader, s.Pulses)
+		if s.Loader != KernalLoader {
+			info += fmt.Sprintf(", short $%02X, long $%02X", s.Short/8, s.Long/8)
+		}
+		fmt.Println(info)
+	}
+	fmt.Println()
+
+	fmt.Println("BLOCKS:")
+	fmt.Println()
+	if len(t.Blocks) == 0 {
+		fmt.Println("  no Kernal loader blocks found")
+	}
+	for _, b := range t.Blocks {
+		copyLabel := "first"
+		if b.Repeat {
+			copyLabel = "repeat"
+		}
+
+		info := fmt.Sprintf("  $%06X: pilot %5d, %-6s  ", b.Offset, b.PilotPulses, copyLabel)
+		if h, ok := newHeader(b.Data); ok {
+			info += fmt.Sprintf("header %-4s \"%s\" $%04X-$%04X", h.TypeLabel(), h.Name(t.charset), h.StartAddress, h.EndAddress)
+		} else {
+			info += fmt.Sprintf("data   %d bytes", len(b.Data))
+		}
+		if b.ChecksumOK() {
+			info += ", checksum OK"
+		} else {
+			info += ", CHECKSUM ERROR"
+		}
+		fmt.Println(info)
+		for _, e := range b.Errors {
+			fmt.Printf("           %s\n", e)
+		}
+	}
+	fmt.Println()
+
+	fmt.Println("FILES:")
+	fmt.Println()
+	for _, f := range t.Files {
+		fmt.Printf("  %-18s %-4s $%04X-$%04X  %5d bytes  %s\n", fmt.Sprintf("\"%s\"", f.Header.Name(t.charset)), f.Header.TypeLabel(), f.Header.StartAddress, f.Header.EndAddress, len(f.Data), f.Loader)
+		for _, w := range f.Warnings {
+			fmt.Printf("    WARNING: %s\n", w)
+		}
+	}
+	fmt.Println()
 }
 
+// CommandDir lists the files on the tape, as found by the C64 when loading.
 func (t TAP) CommandDir() {
-	fmt.Println("directory listing unsupported")
+	fmt.Println("PRESS PLAY ON TAPE")
+	fmt.Println("OK")
+	fmt.Println()
+	fmt.Println("SEARCHING")
+
+	for _, f := range t.Files {
+		status := ""
+		if len(f.Warnings) > 0 {
+			status = "  ?LOAD ERROR"
+		}
+		fmt.Printf("FOUND %-18s %-4s $%04X-$%04X%s\n", f.Header.Name(t.charset), f.Header.TypeLabel(), f.Header.StartAddress, f.Header.EndAddress, status)
+	}
+
+	fmt.Printf("%d FILES FOUND.\n", len(t.Files))
+	fmt.Println()
+}
+
+// ExtractFiles returns the files found on the tape. The start address is
+// added to the start of PRG data, as it would be stored on a disk.
+func (t TAP) ExtractFiles() []commodore.File {
+	var files []commodore.File
+
+	for _, f := range t.Files {
+		file := commodore.File{
+			Name:     f.Header.Name(petscii.Upper),
+			Filename: f.Header.PETSCIIName(),
+			Type:     "prg",
+			Warnings: f.Warnings,
+		}
+		if f.Header.Type == HeaderSequentialFile {
+			file.Type = "seq"
+			file.Data = f.Data
+		} else {
+			file.Data = append([]byte{uint8(f.Header.StartAddress), uint8(f.Header.StartAddress >> 8)}, f.Data...)
+		}
+		files = append(files, file)
+	}
+
+	return files
 }
 
 func (t TAP) String() string {
 	str := ""
 	str += fmt.Sprintf("Signature  %s\n", t.Signature)
 	str += fmt.Sprintf("Version:   $%02x (%s)\n", t.Version, t.tapType(t.Version))
+	str += fmt.Sprintf("Machine:   $%02x (%s)\n", t.Machine, t.machineName())
+	str += fmt.Sprintf("Video:     $%02x (%s, %d Hz clock)\n", t.Video, t.videoName(), t.Clock())
 	str += fmt.Sprintf("Data Size: %d bytes\n", t.DataSize)
+	length := t.Length()
+	str += fmt.Sprintf("Length:    %d:%02d\n", int(length.Minutes()), int(length.Seconds())%60)
 
 	dataLenDiff := int(t.DataSize) - len(t.Data)
 	if dataLenDiff != 0 {
@@ -87,8 +249,59 @@ func (t TAP) tapType(id byte) string {
 		label = "Original Layout"
 	case 0x01:
 		label = "Updated Layout"
+	case 0x02:
+		label = "Half-wave Layout"
 	default:
 		label = "Unknown Layout"
 	}
 	return label
 }
+
+func (t TAP) machineName() string {
+	if name, ok := MachineNames[t.Machine]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+func (t TAP) videoName() string {
+	if t.Video == VideoNTSC {
+		return "NTSC"
+	}
+	return "PAL"
+}
+
+// Clock returns the CPU clock rate of the machine the tape was recorded on,
+// in cycles per second.
+func (t TAP) Clock() uint32 {
+	return MachineClock(t.Machine, t.Video)
+}
+
+// MachineClock returns the CPU clock rate of a machine, in cycles per second.
+// Unknown machines are taken as a C64.
+func MachineClock(machine, video uint8) uint32 {
+	ntsc := video == VideoNTSC
+	switch {
+	case machine == MachineVIC20 && ntsc:
+		return ClockVIC20NTSC
+	case machine == MachineVIC20:
+		return ClockVIC20PAL
+	case machine == MachineC16 && ntsc:
+		return ClockC16NTSC
+	case machine == MachineC16:
+		return ClockC16PAL
+	case ntsc:
+		return ClockNTSC
+	default:
+		return ClockPAL
+	}
+}
+
+// Length returns the playing time of the tape.
+func (t TAP) Length() time.Duration {
+	var cycles uint64
+	for _, p := range t.Pulses {
+		cycles += uint64(p.Cycles)
+	}
+	return time.Duration(float64(cycles) / float64(t.Clock()) * float64(time.Second))
+}
@@ -0,0 +1,724 @@
+// Turbo Loaders
+//
+// Most commercial tapes load a small turbo loader with the Kernal, which then
+// reads the rest of the tape at a higher speed. Nearly all turbo loaders
+// store one bit per pulse: a short pulse for a 0 bit, and a long pulse for a
+// 1 bit. The bytes of a block follow a pilot of a repeated byte, and one or
+// more sync bytes.
+//
+// A loader is identified from:
+//
+//   - the lengths of the short and long pulses of the turbo segment, and
+//   - the tape routine in the code of the last file loaded by the Kernal.
+//
+// The pulse lengths are typical values of each loader. Tape mastering varied
+// between releases, so a tolerance is used when comparing them.
+//
+// A turbo routine sets a CIA timer to a value between the short and long
+// pulse lengths, then on each pulse reads the interrupt control register to
+// learn whether the timer ran out, shifting the bit into the byte with ROL
+// (most significant bit first) or ROR (least significant bit first). When
+// this code is found, the timer value and bit order are used to choose
+// between the loaders matching the pulse lengths.
+//
+// Turbo Tape 64, Freeload, Novaload and Ocean blocks are decoded into files.
+// Cyberload and Visiload change their timings and block layout between
+// releases, so their segments are only identified.
+//
+// Turbo Tape 64: pilot byte $02, sync bytes $09-$01, most significant bit
+// first. The header block starts with a type byte of $01 or $02, followed
+// by the start and end address, an unused byte, and the 16 byte filename.
+// The data block starts with a type byte of $00, followed by the data and
+// a checksum: all data bytes XOR'ed together.
+//
+// Freeload: pilot byte $40, sync byte $5A, most significant bit first. Each
+// block holds the start and end address, the data, and an XOR checksum.
+//
+// Novaload: a pilot of 0 bits, ended by a single 1 bit, least significant
+// bit first. The block starts with the length of the filename, the filename,
+// and the start and end address. The data follows in 256 byte sub-blocks,
+// each followed by a checksum: the sum of all data bytes read so far.
+//
+// Ocean: pilot byte $40, sync byte $5A, most significant bit first. Each
+// block holds the page it loads to, 256 bytes of data, and an XOR checksum.
+// Blocks loading to the following page are joined into a single file.
+package tap
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// pulses longer than this, in cycles, are pauses between segments
+	pauseCycles = 2000
+
+	// segments with fewer pulses than this are treated as noise
+	minSegmentPulses = 800
+
+	// allowed difference, as a fraction, between the pulse lengths of a
+	// segment and a loader
+	turboTolerance = 0.12
+
+	minTurboPilot = 8 // pilot bytes needed to find a block
+)
+
+// Segment is a part of the tape recorded with a single loader.
+type Segment struct {
+	Offset int // offset of the first pulse in the TAP data
+	End    int // offset after the last pulse
+	Pulses int
+	Loader string
+
+	// average lengths of the short (0 bit) and long (1 bit) pulses of a
+	// turbo segment, in cycles
+	Short uint32
+	Long  uint32
+
+	first, last int // range of pulses
+}
+
+// UnknownLoader is the name given to turbo segments of an unknown loader.
+const UnknownLoader = "Unknown turbo"
+
+// turboLoader describes the pulses and block layout of a turbo loader.
+type turboLoader struct {
+	name string
+
+	// typical pulse lengths, in cycles
+	short, long uint32
+
+	msbFirst bool
+	pilot    uint8
+	sync     []byte
+	syncBit  bool // the pilot is 0 bits ended by a 1 bit, in place of the pilot and sync bytes
+
+	// reads a block following the sync bytes, nil when unsupported
+	readBlock func(s bitStream, pos int) (turboBlock, int)
+}
+
+// turboLoaders are the known C64 turbo loaders.
+var turboLoaders = []turboLoader{
+	{
+		name:  "Turbo Tape 64",
+		short: 0x1A * 8, long: 0x28 * 8, msbFirst: true,
+		pilot: 0x02, sync: []byte{0x09, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+		readBlock: readTurboTapeBlock,
+	},
+	{
+		name:  "Freeload",
+		short: 0x24 * 8, long: 0x42 * 8, msbFirst: true,
+		pilot: 0x40, sync: []byte{0x5A},
+		readBlock: readFreeloadBlock,
+	},
+	{
+		name:  "Novaload",
+		short: 0x24 * 8, long: 0x56 * 8,
+		syncBit:   true,
+		readBlock: readNovaloadBlock,
+	},
+	{
+		name:  "Ocean",
+		short: 0x22 * 8, long: 0x44 * 8, msbFirst: true,
+		pilot: oceanPilot, sync: []byte{oceanSync},
+		readBlock: readOceanBlock,
+	},
+	{name: "Cyberload", short: 0x2E * 8, long: 0x4C * 8, msbFirst: true},
+	{name: "Visiload", short: 0x1E * 8, long: 0x35 * 8, msbFirst: true},
+}
+
+const (
+	oceanPilot     = 0x40
+	oceanSync      = 0x5A
+	oceanBlockSize = 256
+
+	novaloadBlockSize = 256
+)
+
+// loaderCode is the tape routine found in the code of a Kernal file.
+type loaderCode struct {
+	threshold uint16 // CIA timer value separating short and long pulses
+	msbFirst  bool
+}
+
+// matches reports whether the routine could read the pulses of the loader.
+func (c loaderCode) matches(l *turboLoader) bool {
+	return c.msbFirst == l.msbFirst && uint32(c.threshold) > l.short && uint32(c.threshold) < l.long
+}
+
+// turboBlock is a block decoded by a turbo loader. Blocks holding a header
+// are joined with the following data block.
+type turboBlock struct {
+	offset     int
+	header     *Header
+	data       []byte
+	isData     bool // a data block, which needs the header of an earlier block
+	checksumOK bool
+	errs       []string
+}
+
+// bitStream holds the bits of a turbo segment, one for each pulse.
+type bitStream struct {
+	bits     []uint8
+	offsets  []int
+	msbFirst bool
+}
+
+func newBitStream(pulses []Pulse, threshold uint32, msbFirst bool) bitStream {
+	s := bitStream{msbFirst: msbFirst}
+	for _, p := range pulses {
+		var bit uint8
+		if p.Cycles >= threshold {
+			bit = 1
+		}
+		s.bits = append(s.bits, bit)
+		s.offsets = append(s.offsets, p.Offset+dataOffset)
+	}
+	return s
+}
+
+// byteAt returns the byte starting at the bit position.
+func (s bitStream) byteAt(pos int) (uint8, bool) {
+	if pos < 0 || pos+8 > len(s.bits) {
+		return 0, false
+	}
+	var value uint8
+	for i := 0; i < 8; i++ {
+		if s.msbFirst {
+			value = value<<1 | s.bits[pos+i]
+		} else {
+			value |= s.bits[pos+i] << i
+		}
+	}
+	return value, true
+}
+
+// readBytes returns the bytes starting at the bit position, or false when
+// the stream ends first.
+func (s bitStream) readBytes(pos, count int) ([]byte, bool) {
+	data := make([]byte, count)
+	for i := range data {
+		b, ok := s.byteAt(pos + i*8)
+		if !ok {
+			return data[:i], false
+		}
+		data[i] = b
+	}
+	return data, true
+}
+
+// findSync returns the bit position following the pilot and sync bytes, or
+// -1 when no block is found.
+func (s bitStream) findSync(start int, pilot uint8, sync []byte) int {
+	for pos := start; pos+8 <= len(s.bits); pos++ {
+		count := 0
+		for b, ok := s.byteAt(pos + count*8); ok && b == pilot; b, ok = s.byteAt(pos + count*8) {
+			count++
+		}
+		if count < minTurboPilot {
+			continue
+		}
+
+		next := pos + count*8
+		if data, ok := s.readBytes(next, len(sync)); ok && bytes.Equal(data, sync) {
+			return next + len(sync)*8
+		}
+		pos = next
+	}
+	return -1
+}
+
+// findSyncBit returns the bit position following a pilot of 0 bits and its
+// 1 sync bit, or -1 when no block is found.
+func (s bitStream) findSyncBit(start int) int {
+	zeros := 0
+	for pos := start; pos < len(s.bits); pos++ {
+		if s.bits[pos] == 0 {
+			zeros++
+			continue
+		}
+		if zeros >= minTurboPilot*8 {
+			return pos + 1
+		}
+		zeros = 0
+	}
+	return -1
+}
+
+// findBlock returns the bit position of the next block of the loader, or -1
+// when no block is found.
+func (s bitStream) findBlock(l *turboLoader, start int) int {
+	if l.syncBit {
+		return s.findSyncBit(start)
+	}
+	return s.findSync(start, l.pilot, l.sync)
+}
+
+func xorChecksum(data []byte) uint8 {
+	var sum uint8
+	for _, b := range data {
+		sum ^= b
+	}
+	return sum
+}
+
+func readTurboTapeBlock(s bitStream, pos int) (turboBlock, int) {
+	block := turboBlock{offset: s.offsets[pos]}
+
+	blockType, ok := s.byteAt(pos)
+	if !ok {
+		return block, pos
+	}
+	pos += 8
+
+	if blockType == 0x01 || blockType == 0x02 {
+		data, ok := s.readBytes(pos, 21)
+		if !ok {
+			block.errs = append(block.errs, "header block truncated")
+			return block, pos + len(data)*8
+		}
+		h := Header{
+			Type:         HeaderProgram,
+			StartAddress: uint16(data[0]) | uint16(data[1])<<8,
+			EndAddress:   uint16(data[2]) | uint16(data[3])<<8,
+		}
+		copy(h.Filename[:], data[5:21])
+		block.header = &h
+		block.checksumOK = true
+		return block, pos + len(data)*8
+	}
+
+	// data blocks are read with the length given by the header
+	block.isData = true
+	return block, pos
+}
+
+func readFreeloadBlock(s bitStream, pos int) (turboBlock, int) {
+	block := turboBlock{offset: s.offsets[pos]}
+
+	addresses, ok := s.readBytes(pos, 4)
+	if !ok {
+		block.errs = append(block.errs, "block truncated")
+		return block, pos + len(addresses)*8
+	}
+	pos += 32
+
+	h := Header{
+		Type:         HeaderProgram,
+		StartAddress: uint16(addresses[0]) | uint16(addresses[1])<<8,
+		EndAddress:   uint16(addresses[2]) | uint16(addresses[3])<<8,
+	}
+	copy(h.Filename[:], fmt.Sprintf("%-16s", fmt.Sprintf("FREELOAD %04X", h.StartAddress)))
+	block.header = &h
+
+	return readTurboData(s, block, pos, int(h.EndAddress)-int(h.StartAddress))
+}
+
+func readNovaloadBlock(s bitStream, pos int) (turboBlock, int) {
+	block := turboBlock{offset: s.offsets[pos]}
+
+	nameLength, ok := s.byteAt(pos)
+	if !ok {
+		block.errs = append(block.errs, "block truncated")
+		return block, pos
+	}
+	pos += 8
+
+	info, ok := s.readBytes(pos, int(nameLength)+4)
+	if !ok {
+		block.errs = append(block.errs, "block truncated")
+		return block, pos + len(info)*8
+	}
+	pos += len(info) * 8
+
+	addresses := info[nameLength:]
+	h := Header{
+		Type:         HeaderProgram,
+		StartAddress: uint16(addresses[0]) | uint16(addresses[1])<<8,
+		EndAddress:   uint16(addresses[2]) | uint16(addresses[3])<<8,
+	}
+	copy(h.Filename[:], bytes.Repeat([]byte{0x20}, len(h.Filename)))
+	copy(h.Filename[:], info[:nameLength])
+	block.header = &h
+
+	length := int(h.EndAddress) - int(h.StartAddress)
+	if length <= 0 {
+		block.errs = append(block.errs, "invalid start and end address")
+		return block, pos
+	}
+
+	var sum uint8
+	block.checksumOK = true
+	for len(block.data) < length {
+		size := length - len(block.data)
+		if size > novaloadBlockSize {
+			size = novaloadBlockSize
+		}
+		data, ok := s.readBytes(pos, size+1)
+		pos += len(data) * 8
+		if !ok {
+			if len(data) > size {
+				data = data[:size]
+			}
+			block.data = append(block.data, data...)
+			block.errs = append(block.errs, fmt.Sprintf("data truncated, %d of %d bytes read", len(block.data), length))
+			return block, pos
+		}
+		for _, b := range data[:size] {
+			sum += b
+		}
+		block.data = append(block.data, data[:size]...)
+		if data[size] != sum {
+			block.checksumOK = false
+		}
+	}
+
+	return block, pos
+}
+
+// readOceanBlock reads an Ocean block, along with the blocks following it
+// which load to the next page.
+func readOceanBlock(s bitStream, pos int) (turboBlock, int) {
+	block := turboBlock{offset: s.offsets[pos], checksumOK: true}
+
+	var start uint16
+	for {
+		data, ok := s.readBytes(pos, oceanBlockSize+2)
+		pos += len(data) * 8
+		if !ok {
+			block.errs = append(block.errs, "block truncated")
+			if len(data) > 1 {
+				block.data = append(block.data, data[1:]...)
+			}
+			break
+		}
+
+		if block.data == nil {
+			start = uint16(data[0]) << 8
+		}
+		block.data = append(block.data, data[1:oceanBlockSize+1]...)
+		if xorChecksum(data[1:oceanBlockSize+1]) != data[oceanBlockSize+1] {
+			block.checksumOK = false
+		}
+
+		next := s.findSync(pos, oceanPilot, []byte{oceanSync})
+		if page, ok := s.byteAt(next); !ok || page != data[0]+1 || page == 0 {
+			break
+		}
+		pos = next
+	}
+
+	if len(block.data) == 0 {
+		return block, pos
+	}
+	h := Header{
+		Type:         HeaderProgram,
+		StartAddress: start,
+		EndAddress:   start + uint16(len(block.data)),
+	}
+	copy(h.Filename[:], fmt.Sprintf("%-16s", fmt.Sprintf("OCEAN %04X", h.StartAddress)))
+	block.header = &h
+
+	return block, pos
+}
+
+// readTurboData reads the data of a block, followed by its XOR checksum.
+func readTurboData(s bitStream, block turboBlock, pos, length int) (turboBlock, int) {
+	if length <= 0 {
+		block.errs = append(block.errs, "invalid start and end address")
+		return block, pos
+	}
+
+	data, ok := s.readBytes(pos, length+1)
+	if !ok {
+		block.data = data
+		block.errs = append(block.errs, fmt.Sprintf("data truncated, %d of %d bytes read", len(data), length))
+		return block, pos + len(data)*8
+	}
+
+	block.data = data[:length]
+	block.checksumOK = xorChecksum(block.data) == data[length]
+	return block, pos + len(data)*8
+}
+
+// findSegments splits the tape into the Kernal loader segments, and the
+// turbo segments between them, separated by pauses.
+func findSegments(pulses []Pulse, blocks []Block) []Segment {
+	kernal := make([]bool, len(pulses))
+	for _, b := range blocks {
+		for i := b.first; i < b.last && i < len(pulses); i++ {
+			kernal[i] = true
+		}
+	}
+
+	var segments []Segment
+	for i := 0; i < len(pulses); {
+		if pulses[i].Cycles > pauseCycles {
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(pulses) && kernal[j] == kernal[i] && pulses[j].Cycles <= pauseCycles {
+			j++
+		}
+
+		seg := Segment{
+			Offset: pulses[i].Offset + dataOffset,
+			End:    pulses[j-1].Offset + dataOffset + 1,
+			Pulses: j - i,
+			first:  i,
+			last:   j,
+		}
+		if kernal[i] {
+			seg.Loader = KernalLoader
+		}
+		if kernal[i] || seg.Pulses >= minSegmentPulses {
+			segments = append(segments, seg)
+		}
+		i = j
+	}
+
+	return segments
+}
+
+// pulseClusters returns the average lengths of the short and long pulses,
+// using a two-means clustering of the pulse lengths.
+func pulseClusters(pulses []Pulse) (float64, float64) {
+	lengths := make([]float64, 0, len(pulses))
+	for _, p := range pulses {
+		lengths = append(lengths, float64(p.Cycles))
+	}
+	sort.Float64s(lengths)
+	if len(lengths) == 0 {
+		return 0, 0
+	}
+
+	short, long := lengths[len(lengths)/10], lengths[len(lengths)*9/10]
+	for iteration := 0; iteration < 10; iteration++ {
+		threshold := (short + long) / 2
+		var sumShort, sumLong float64
+		var countShort, countLong int
+		for _, l := range lengths {
+			if l < threshold {
+				sumShort += l
+				countShort++
+			} else {
+				sumLong += l
+				countLong++
+			}
+		}
+		if countShort == 0 || countLong == 0 {
+			break
+		}
+		short, long = sumShort/float64(countShort), sumLong/float64(countLong)
+	}
+	return short, long
+}
+
+// identifyLoader returns the loader with the closest timings to those of a
+// turbo segment, preferring a loader that the tape routine found in the
+// Kernal files could read.
+func identifyLoader(loaders []turboLoader, short, long float64, code *loaderCode) *turboLoader {
+	var best, bestCode *turboLoader
+	bestDiff, bestCodeDiff := math.MaxFloat64, math.MaxFloat64
+
+	for i := range loaders {
+		l := &loaders[i]
+		ds := math.Abs(short-float64(l.short)) / float64(l.short)
+		dl := math.Abs(long-float64(l.long)) / float64(l.long)
+		if ds > turboTolerance || dl > turboTolerance {
+			continue
+		}
+		if diff := ds + dl; diff < bestDiff {
+			best, bestDiff = l, diff
+		}
+		if diff := ds + dl; code != nil && code.matches(l) && diff < bestCodeDiff {
+			bestCode, bestCodeDiff = l, diff
+		}
+	}
+
+	if bestCode != nil {
+		return bestCode
+	}
+	return best
+}
+
+// CIA timer registers, the low byte of timer A and B, on either CIA
+var timerRegisters = map[[2]byte]bool{
+	{0x04, 0xDC}: true, {0x06, 0xDC}: true,
+	{0x04, 0xDD}: true, {0x06, 0xDD}: true,
+}
+
+// scanLoaderCode looks for a turbo tape routine in the data: the timer being
+// set with an immediate load and absolute store of each byte, e.g.
+// LDA #$07, STA $DC06, LDA #$01, STA $DC07, followed by a read of the
+// interrupt control register of the CIA with LDA or BIT, and a ROL or ROR of
+// the byte within the next few instructions.
+func scanLoaderCode(data []byte) *loaderCode {
+	// LDA/LDX/LDY immediate, with their STA/STX/STY absolute
+	stores := map[byte]byte{0xA9: 0x8D, 0xA2: 0x8E, 0xA0: 0x8C}
+
+	for i := 0; i+10 <= len(data); i++ {
+		lo, hi := data[i:i+5], data[i+5:i+10]
+		if stores[lo[0]] != lo[2] || stores[hi[0]] != hi[2] {
+			continue
+		}
+		if hi[3] == lo[3]-1 && hi[4] == lo[4] {
+			lo, hi = hi, lo // high byte set first
+		}
+		if !timerRegisters[[2]byte{lo[3], lo[4]}] || hi[3] != lo[3]+1 || hi[4] != lo[4] {
+			continue
+		}
+
+		for j := i + 10; j+3 <= len(data); j++ {
+			if (data[j] != 0xAD && data[j] != 0x2C) || data[j+1] != 0x0D || data[j+2] != lo[4] {
+				continue
+			}
+			if msbFirst, ok := bitShift(data[j+3:]); ok {
+				return &loaderCode{threshold: uint16(lo[1]) | uint16(hi[1])<<8, msbFirst: msbFirst}
+			}
+		}
+	}
+	return nil
+}
+
+// instructions searched for the shift of a bit into the byte
+const shiftWindow = 8
+
+// bitShift steps through the instructions of the code, returning whether
+// the first ROL or ROR found shifts the bits in most significant bit first.
+func bitShift(code []byte) (msbFirst bool, found bool) {
+	for i, n := 0, 0; i < len(code) && n < shiftWindow; i, n = i+opcodeLength(code[i]), n+1 {
+		switch code[i] {
+		case 0x26, 0x2A, 0x2E, 0x36, 0x3E: // ROL
+			return true, true
+		case 0x66, 0x6A, 0x6E, 0x76, 0x7E: // ROR
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// opcodeLength returns the length in bytes of a 6502 instruction, from the
+// addressing mode given by the bits of the opcode.
+func opcodeLength(op byte) int {
+	mode := op >> 2 & 0x07
+	switch {
+	case op == 0x20: // JSR
+		return 3
+	case op&0x9F == 0x00: // BRK, RTI, RTS
+		return 1
+	case op&0x03 == 0x00 || op&0x03 == 0x02:
+		switch mode {
+		case 0, 1, 4, 5: // immediate, zero page, branch, zero page indexed
+			return 2
+		case 3, 7: // absolute, absolute indexed
+			return 3
+		}
+		return 1 // implied, accumulator
+	}
+	switch mode {
+	case 3, 6, 7: // absolute, absolute indexed
+		return 3
+	}
+	return 2 // immediate, zero page, indirect
+}
+
+// decodeTurbo identifies the loader of each turbo segment, and decodes the
+// blocks of the known loaders into files. The loaders are those of the
+// machine the tape was recorded for.
+func decodeTurbo(loaders []turboLoader, pulses []Pulse, segments []Segment, blocks []Block) []File {
+	var files []File
+	var code *loaderCode
+	var header *Header
+	var headerBlock turboBlock
+
+	for i := range segments {
+		seg := &segments[i]
+
+		if seg.Loader == KernalLoader {
+			for _, b := range blocks {
+				if b.first < seg.first || b.first >= seg.last {
+					continue
+				}
+				data := b.Data
+				if _, ok := newHeader(b.Data); ok {
+					// a new file, the code of the earlier file no longer runs
+					code = nil
+					data = data[21:]
+				}
+				if c := scanLoaderCode(data); c != nil {
+					code = c
+				}
+			}
+			continue
+		}
+
+		short, long := pulseClusters(pulses[seg.first:seg.last])
+		seg.Short, seg.Long = uint32(short), uint32(long)
+
+		loader := identifyLoader(loaders, short, long, code)
+		if loader == nil {
+			seg.Loader = UnknownLoader
+			continue
+		}
+		seg.Loader = loader.name
+		if loader.readBlock == nil {
+			continue
+		}
+
+		s := newBitStream(pulses[seg.first:seg.last], uint32((short+long)/2), loader.msbFirst)
+		for pos := s.findBlock(loader, 0); pos >= 0 && pos < len(s.bits); pos = s.findBlock(loader, pos) {
+			var block turboBlock
+			block, pos = loader.readBlock(s, pos)
+
+			if block.isData {
+				if header == nil {
+					continue
+				}
+				length := int(header.EndAddress) - int(header.StartAddress)
+				if _, ok := s.byteAt(pos); ok {
+					block, pos = readTurboData(s, block, pos, length)
+				}
+				block.header, block.offset = header, headerBlock.offset
+				block.errs = append(headerBlock.errs, block.errs...)
+				header = nil
+			} else if block.header != nil && block.data == nil && len(block.errs) == 0 {
+				// a header block, waiting for its data block
+				header, headerBlock = block.header, block
+				continue
+			}
+			if block.header == nil {
+				continue
+			}
+
+			file := File{Offset: block.offset, Header: *block.header, Data: block.data, Loader: loader.name}
+			file.Warnings = append(file.Warnings, block.errs...)
+			if !block.checksumOK && len(block.errs) == 0 {
+				file.Warnings = append(file.Warnings, fmt.Sprintf("data block at $%06X: checksum error", block.offset))
+			}
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// mergeSegments joins the neighbouring segments using the same loader, such
+// as the blocks of a Kernal file and their repeated copies.
+func mergeSegments(segments []Segment) []Segment {
+	var merged []Segment
+	for _, s := range segments {
+		if n := len(merged); n > 0 && merged[n-1].Loader == s.Loader {
+			merged[n-1].End = s.End
+			merged[n-1].Pulses += s.Pulses
+			merged[n-1].last = s.last
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
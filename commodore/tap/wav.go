@@ -0,0 +1,259 @@
+// WAV Conversion
+//
+// A TAP can be played back to a real datasette, or recorded from one, as a
+// mono PCM WAV file. Each TAP pulse becomes one full wave of the audio
+// signal: a square wave, as written by the C64, or a sine wave, which some
+// tape decks record more cleanly. The half-waves of a v2 TAP each become
+// half of a wave.
+//
+// When reading a recording, the datasette triggers on the falling edge of
+// the signal, so a pulse is the time between two falling zero crossings.
+// For v2, the time between every zero crossing is stored. A crossing is only
+// counted once the signal has passed a threshold either side of the zero
+// line, so that noise around the line is ignored.
+package tap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// WaveShape is the shape of the waves written to a WAV file.
+type WaveShape int
+
+const (
+	SquareWave WaveShape = iota
+	SineWave
+)
+
+const (
+	wavAmplitude = 0x6000
+
+	// the threshold, as a fraction of the peak level
+	wavHysteresis = 0.1
+)
+
+// wavFormat is the "fmt " chunk of a WAV file.
+type wavFormat struct {
+	AudioFormat   uint16 // 1 for PCM
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+}
+
+// WAV returns the pulses of the tape as a 16-bit mono WAV file, using the
+// given clock rate, usually that of the machine the tape was recorded on.
+func (t TAP) WAV(clock uint32, sampleRate int, shape WaveShape) []byte {
+	halfWaves := t.Version == 2
+
+	// a short lead-in and tail give the falling edges at the start of the
+	// first pulse, and at the end of the last pulse
+	edge := make([]int16, sampleRate/1000)
+	for i := range edge {
+		edge[i] = wavAmplitude
+	}
+	samples := append([]int16{}, edge...)
+
+	var position float64 // position in samples, kept as a fraction to prevent drift
+	level := -1.0        // level of the next half-wave
+
+	for _, p := range t.Pulses {
+		length := float64(p.Cycles) * float64(sampleRate) / float64(clock)
+		start := int(math.Round(position))
+		position += length
+		end := int(math.Round(position))
+
+		for i := start; i < end; i++ {
+			phase := (float64(i-start) + 0.5) / float64(end-start)
+			var value float64
+			switch {
+			case halfWaves && shape == SineWave:
+				value = level * math.Sin(math.Pi*phase)
+			case halfWaves:
+				value = level
+			case shape == SineWave:
+				value = -math.Sin(2 * math.Pi * phase)
+			case phase < 0.5:
+				value = -1
+			default:
+				value = 1
+			}
+			samples = append(samples, int16(value*wavAmplitude))
+		}
+		if halfWaves {
+			level = -level
+		}
+	}
+
+	for range edge {
+		samples = append(samples, int16(level*wavAmplitude))
+	}
+
+	format := wavFormat{
+		AudioFormat:   1,
+		Channels:      1,
+		SampleRate:    uint32(sampleRate),
+		ByteRate:      uint32(sampleRate) * 2,
+		BlockAlign:    2,
+		BitsPerSample: 16,
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(4+8+16+8+len(samples)*2))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, format)
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(samples)*2))
+	_ = binary.Write(buf, binary.LittleEndian, samples)
+
+	return buf.Bytes()
+}
+
+// FromWAV creates a TAP of the given version from a recording of a tape, in
+// an 8 or 16-bit PCM WAV file, using the clock rate of the machine and video
+// standard. For stereo files only the left channel is used.
+func FromWAV(data []byte, machine, video, version uint8) (*TAP, error) {
+	clock := MachineClock(machine, video)
+
+	samples, rate, err := readWAV(data)
+	if err != nil {
+		return nil, err
+	}
+
+	crossings := zeroCrossings(samples, version == 2)
+
+	var cycles []uint32
+	for i := 1; i < len(crossings); i++ {
+		length := (crossings[i] - crossings[i-1]) * float64(clock) / float64(rate)
+		cycles = append(cycles, uint32(math.Round(length)))
+	}
+
+	t := &TAP{Version: version, Machine: machine, Video: video, Data: encodePulses(cycles, version)}
+	copy(t.Signature[:], "C64-TAPE-RAW")
+	t.DataSize = uint32(len(t.Data))
+	t.Pulses = decodePulses(t.Data, t.Version)
+
+	return t, nil
+}
+
+// readWAV returns the samples of the first channel of a PCM WAV file, and
+// its sample rate.
+func readWAV(data []byte) ([]float64, uint32, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("not a WAV file")
+	}
+
+	var format *wavFormat
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		pos += 8
+		if pos+size > len(data) {
+			size = len(data) - pos // a truncated recording
+		}
+		chunk := data[pos : pos+size]
+
+		switch id {
+		case "fmt ":
+			format = &wavFormat{}
+			if err := binary.Read(bytes.NewReader(chunk), binary.LittleEndian, format); err != nil {
+				return nil, 0, fmt.Errorf("invalid WAV format chunk: %w", err)
+			}
+			if format.AudioFormat != 1 || (format.BitsPerSample != 8 && format.BitsPerSample != 16) || format.Channels == 0 {
+				return nil, 0, fmt.Errorf("unsupported WAV format, only 8 and 16-bit PCM are supported")
+			}
+		case "data":
+			if format == nil {
+				return nil, 0, fmt.Errorf("WAV data chunk found before the format chunk")
+			}
+			return wavSamples(chunk, *format), format.SampleRate, nil
+		}
+
+		pos += size + size%2 // chunks are word aligned
+	}
+
+	return nil, 0, fmt.Errorf("WAV data chunk not found")
+}
+
+func wavSamples(chunk []byte, format wavFormat) []float64 {
+	frameSize := int(format.BlockAlign)
+	if frameSize == 0 {
+		frameSize = int(format.Channels) * int(format.BitsPerSample) / 8
+	}
+
+	samples := make([]float64, 0, len(chunk)/frameSize)
+	for pos := 0; pos+frameSize <= len(chunk); pos += frameSize {
+		if format.BitsPerSample == 8 {
+			samples = append(samples, float64(chunk[pos])-128) // unsigned
+		} else {
+			samples = append(samples, float64(int16(binary.LittleEndian.Uint16(chunk[pos:]))))
+		}
+	}
+	return samples
+}
+
+// zeroCrossings returns the positions, in samples, of the falling zero
+// crossings of the signal, or all crossings when halfWaves is set. The
+// position is interpolated between the samples either side of the crossing.
+func zeroCrossings(samples []float64, halfWaves bool) []float64 {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	// remove any DC offset of the recording
+	var mean, peak float64
+	for _, s := range samples {
+		mean += s
+	}
+	mean /= float64(len(samples))
+	for _, s := range samples {
+		peak = math.Max(peak, math.Abs(s-mean))
+	}
+	threshold := peak * wavHysteresis
+
+	var crossings []float64
+	var falling, rising float64 // the last zero crossings
+	state := 0                  // 1 above the threshold, -1 below
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1]-mean, samples[i]-mean
+		if prev >= 0 && cur < 0 {
+			falling = float64(i-1) + prev/(prev-cur)
+		} else if prev < 0 && cur >= 0 {
+			rising = float64(i-1) + prev/(prev-cur)
+		}
+
+		if cur < -threshold && state != -1 {
+			if state == 1 {
+				crossings = append(crossings, falling)
+			}
+			state = -1
+		} else if cur > threshold && state != 1 {
+			if state == -1 && halfWaves {
+				crossings = append(crossings, rising)
+			}
+			state = 1
+		}
+	}
+
+	return crossings
+}
+
+// Bytes returns the TAP file, with its header and data.
+func (t TAP) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(t.Signature[:])
+	buf.WriteByte(t.Version)
+	buf.WriteByte(t.Machine)
+	buf.WriteByte(t.Video)
+	buf.WriteByte(t.Unused)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(t.Data)))
+	buf.Write(t.Data)
+	return buf.Bytes()
+}
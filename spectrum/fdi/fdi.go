@@ -0,0 +1,195 @@
+// Package fdi implements reading of FDI disk images, as created by the UKV
+// Spectrum Debugger and used by many Beta Disk (TR-DOS) emulators.
+//
+// Unlike the TRD format, an FDI stores the ID field (C/H/R/N) of every sector
+// on each track, along with flags for CRC errors and deleted data marks, so
+// that non-standard disk layouts used for copy protection can be preserved.
+//
+// File layout:
+//   header: "FDI", write protect, cylinders, heads, text/data offsets, extra header
+//   track headers: track offset, sector count, and 7 bytes for each sector
+//   description text
+//   sector data
+//
+// Note: all WORD and DWORD values are stored in low/high byte order.
+package fdi
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mrcook/retroio/spectrum/floppy"
+	"github.com/mrcook/retroio/storage"
+)
+
+const (
+	headerSize       = 14
+	trackHeaderSize  = 7
+	sectorHeaderSize = 7
+)
+
+// Sector flags
+const (
+	flagCRCMask = 0x3F // bits 0-5: CRC is correct for sector size 128 << bit
+	flagNoData  = 0x40 // sector has no data field
+	flagDeleted = 0x80 // data written with a deleted data address mark
+)
+
+// FDI disk image
+type FDI struct {
+	reader *storage.Reader
+
+	Header      Header
+	Description string
+	Disk        floppy.Disk
+}
+
+// Header of the FDI file.
+type Header struct {
+	Signature         [3]byte // "FDI"
+	WriteProtected    uint8   // 1 if the disk is write protected
+	Cylinders         uint16
+	Heads             uint16
+	TextOffset        uint16 // offset of the description text
+	DataOffset        uint16 // offset of the sector data
+	ExtraHeaderLength uint16 // length of additional header data, before the track headers
+}
+
+func New(reader *storage.Reader) *FDI {
+	return &FDI{reader: reader}
+}
+
+// Read the FDI header, track headers, and the sector data.
+func (f *FDI) Read() error {
+	data, err := f.reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(data) < headerSize {
+		return fmt.Errorf("file too small for an FDI image")
+	}
+
+	copy(f.Header.Signature[:], data[0:3])
+	if string(f.Header.Signature[:]) != "FDI" {
+		return fmt.Errorf("invalid FDI signature: %q", f.Header.Signature)
+	}
+	f.Header.WriteProtected = data[3]
+	f.Header.Cylinders = binary.LittleEndian.Uint16(data[4:])
+	f.Header.Heads = binary.LittleEndian.Uint16(data[6:])
+	f.Header.TextOffset = binary.LittleEndian.Uint16(data[8:])
+	f.Header.DataOffset = binary.LittleEndian.Uint16(data[10:])
+	f.Header.ExtraHeaderLength = binary.LittleEndian.Uint16(data[12:])
+
+	f.Description = readText(data, int(f.Header.TextOffset))
+
+	f.Disk = floppy.Disk{
+		Cylinders: uint8(f.Header.Cylinders),
+		Sides:     uint8(f.Header.Heads),
+	}
+
+	pos := headerSize + int(f.Header.ExtraHeaderLength)
+
+	for c := 0; c < int(f.Header.Cylinders); c++ {
+		for h := 0; h < int(f.Header.Heads); h++ {
+			if pos+trackHeaderSize > len(data) {
+				return fmt.Errorf("unexpected end of data reading track header for cylinder %d, head %d", c, h)
+			}
+			trackOffset := int(f.Header.DataOffset) + int(binary.LittleEndian.Uint32(data[pos:]))
+			sectorCount := int(data[pos+6])
+			pos += trackHeaderSize
+
+			track := floppy.Track{Cylinder: uint8(c), Side: uint8(h)}
+
+			for i := 0; i < sectorCount; i++ {
+				if pos+sectorHeaderSize > len(data) {
+					return fmt.Errorf("unexpected end of data reading sector headers for cylinder %d, head %d", c, h)
+				}
+				header := data[pos : pos+sectorHeaderSize]
+				pos += sectorHeaderSize
+
+				sector := floppy.Sector{
+					Cylinder: header[0],
+					Head:     header[1],
+					ID:       header[2],
+					Size:     header[3],
+					Deleted:  header[4]&flagDeleted > 0,
+					NoData:   header[4]&flagNoData > 0,
+				}
+
+				if !sector.NoData {
+					// the flags have no CRC bit for sizes above 4096 bytes
+					if sector.Size <= 5 {
+						sector.DataCRCError = header[4]&flagCRCMask&(1<<sector.Size) == 0
+					}
+
+					start := trackOffset + int(binary.LittleEndian.Uint16(header[5:]))
+					end := start + sector.SizeInBytes()
+					if end > len(data) {
+						track.Warnings = append(track.Warnings, fmt.Sprintf("sector %d data is truncated", sector.ID))
+						end = len(data)
+					}
+					if start < end {
+						sector.Data = append([]byte{}, data[start:end]...)
+					}
+				}
+
+				track.Sectors = append(track.Sectors, sector)
+			}
+
+			f.Disk.Tracks = append(f.Disk.Tracks, track)
+		}
+	}
+
+	return nil
+}
+
+// DisplayGeometry prints the disk and track metadata to the terminal.
+func (f FDI) DisplayGeometry() {
+	fmt.Println("DISK INFORMATION:")
+	fmt.Printf("Type:            FDI\n")
+	if f.Description != "" {
+		fmt.Printf("Description:     %s\n", f.Description)
+	}
+	fmt.Printf("Cylinders:       %d\n", f.Header.Cylinders)
+	fmt.Printf("Sides:           %d\n", f.Header.Heads)
+	fmt.Printf("Write protected: %t\n", f.Header.WriteProtected > 0)
+	fmt.Println()
+
+	for _, track := range f.Disk.Tracks {
+		fmt.Println(track)
+	}
+}
+
+// CommandDir displays the TR-DOS catalogue, read from the logical sectors of
+// the system track.
+func (f FDI) CommandDir() {
+	catalogue, err := f.Disk.Catalogue()
+	if err != nil {
+		fmt.Printf("Unable to read the TR-DOS catalogue: %s\n", err)
+		return
+	}
+
+	if !f.Disk.IsStandardTRD() {
+		fmt.Println("NOTE: the disk does not have a standard TR-DOS layout")
+		fmt.Println()
+	}
+
+	catalogue.CommandDir()
+}
+
+// DisplayBASIC outputs all BASIC programs on the disk
+func (f FDI) DisplayBASIC() {
+	fmt.Println("Not implemented for Spectrum disk images")
+}
+
+// readText reads the zero terminated description text.
+func readText(data []byte, offset int) string {
+	if offset <= 0 || offset >= len(data) {
+		return ""
+	}
+	end := offset
+	for end < len(data) && data[end] != 0 {
+		end++
+	}
+	return string(data[offset:end])
+}
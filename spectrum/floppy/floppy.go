@@ -0,0 +1,137 @@
+// Package floppy provides a common track and sector layout for the raw disk
+// image formats (UDI, FDI), which store the physical layout of each track
+// rather than just the logical sector data.
+//
+// The layout closely follows the NEC µPD765 / WD1793 ID fields, where each
+// sector is identified by its C/H/R/N values, and is much like the track and
+// sector information blocks of the Amstrad DSK format.
+package floppy
+
+import (
+	"fmt"
+)
+
+// TR-DOS logical disk layout.
+const (
+	TRDSectorsPerTrack = 16
+	TRDSectorSize      = 256
+)
+
+// Disk holds all the tracks read from the disk image.
+// Tracks are ordered by cylinder, then side:
+//
+//	cylinder 0 side 0, cylinder 0 side 1, cylinder 1 side 0, etc.
+type Disk struct {
+	Cylinders uint8
+	Sides     uint8
+	Tracks    []Track
+}
+
+// Track information, with the sectors in the order they were found on the track.
+type Track struct {
+	Cylinder uint8 // physical cylinder number
+	Side     uint8 // physical side number
+	Sectors  []Sector
+	Warnings []string // problems found while decoding the raw track data
+}
+
+// Sector information and data.
+type Sector struct {
+	Cylinder uint8 // C: cylinder number as recorded in the ID field
+	Head     uint8 // H: head number as recorded in the ID field
+	ID       uint8 // R: sector number
+	Size     uint8 // N: sector size code, the size in bytes is 128 << N
+
+	Data []byte
+
+	Deleted      bool // data field written with a deleted data address mark
+	NoData       bool // ID field found, but without a data field
+	IDCRCError   bool
+	DataCRCError bool
+}
+
+// SizeInBytes returns the sector size as given by the N value of the ID field.
+func (s Sector) SizeInBytes() int {
+	return 128 << (s.Size & 0x07)
+}
+
+// Track returns the track for the cylinder/side.
+func (d Disk) Track(cylinder, side uint8) (Track, bool) {
+	for _, t := range d.Tracks {
+		if t.Cylinder == cylinder && t.Side == side {
+			return t, true
+		}
+	}
+	return Track{}, false
+}
+
+// Sector returns the first sector with the given ID number.
+func (t Track) Sector(id uint8) (Sector, bool) {
+	for _, s := range t.Sectors {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Sector{}, false
+}
+
+func (t Track) String() string {
+	str := fmt.Sprintf("SIDE %d, TRACK %02d: ", t.Side, t.Cylinder)
+	if len(t.Sectors) == 0 {
+		str += "Blank Track"
+	} else {
+		str += fmt.Sprintf("%02d sectors", len(t.Sectors))
+		if size, ok := t.uniformSectorSize(); ok {
+			str += fmt.Sprintf(" (%d bytes)", size)
+		} else {
+			str += " (mixed sizes)"
+		}
+	}
+
+	for _, s := range t.Sectors {
+		if info := s.status(); info != "" {
+			str += fmt.Sprintf("\n    - sector C%d H%d R%d N%d: %s", s.Cylinder, s.Head, s.ID, s.Size, info)
+		}
+	}
+	for _, w := range t.Warnings {
+		str += fmt.Sprintf("\n    - WARNING: %s", w)
+	}
+
+	return str
+}
+
+func (t Track) uniformSectorSize() (int, bool) {
+	size := 0
+	for i, s := range t.Sectors {
+		if i > 0 && s.SizeInBytes() != size {
+			return 0, false
+		}
+		size = s.SizeInBytes()
+	}
+	return size, true
+}
+
+func (s Sector) status() string {
+	var info []string
+	if s.IDCRCError {
+		info = append(info, "ID CRC error")
+	}
+	if s.DataCRCError {
+		info = append(info, "data CRC error")
+	}
+	if s.Deleted {
+		info = append(info, "deleted data mark")
+	}
+	if s.NoData {
+		info = append(info, "no data field")
+	}
+
+	str := ""
+	for i, s := range info {
+		if i > 0 {
+			str += ", "
+		}
+		str += s
+	}
+	return str
+}
@@ -0,0 +1,125 @@
+package floppy
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mrcook/retroio/spectrum/trd"
+	"github.com/mrcook/retroio/storage"
+)
+
+// IsStandardTRD checks that every track has the standard TR-DOS layout: 16
+// sectors of 256 bytes, numbered 1-16, without any errors or extra sectors.
+func (d Disk) IsStandardTRD() bool {
+	return len(d.TRDWarnings()) == 0
+}
+
+// TRDWarnings lists all the ways in which the disk differs from a standard
+// TR-DOS layout. These are usually the features used by copy protection
+// schemes, and they are lost when converting the disk to a TRD image.
+func (d Disk) TRDWarnings() []string {
+	var warnings []string
+
+	for _, t := range d.Tracks {
+		prefix := fmt.Sprintf("side %d, track %02d", t.Side, t.Cylinder)
+
+		if len(t.Sectors) != TRDSectorsPerTrack {
+			warnings = append(warnings, fmt.Sprintf("%s: has %d sectors", prefix, len(t.Sectors)))
+		}
+
+		seen := make(map[uint8]bool)
+		for _, s := range t.Sectors {
+			sector := fmt.Sprintf("%s, sector %d", prefix, s.ID)
+
+			switch {
+			case s.ID < 1 || s.ID > TRDSectorsPerTrack:
+				warnings = append(warnings, fmt.Sprintf("%s: non-standard sector number", sector))
+			case seen[s.ID]:
+				warnings = append(warnings, fmt.Sprintf("%s: duplicate sector", sector))
+			}
+			seen[s.ID] = true
+
+			if s.SizeInBytes() != TRDSectorSize {
+				warnings = append(warnings, fmt.Sprintf("%s: sector size of %d bytes", sector, s.SizeInBytes()))
+			}
+			if s.Cylinder != t.Cylinder {
+				warnings = append(warnings, fmt.Sprintf("%s: ID field has cylinder %d", sector, s.Cylinder))
+			}
+			if info := s.status(); info != "" {
+				warnings = append(warnings, fmt.Sprintf("%s: %s", sector, info))
+			}
+		}
+
+		for id := uint8(1); id <= TRDSectorsPerTrack; id++ {
+			if !seen[id] {
+				warnings = append(warnings, fmt.Sprintf("%s, sector %d: missing", prefix, id))
+			}
+		}
+
+		for _, w := range t.Warnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", prefix, w))
+		}
+	}
+
+	return warnings
+}
+
+// TRDImage returns the logical sectors of the disk in TRD order: sectors 1-16
+// of each track, ordered by cylinder then side. Missing sectors are filled
+// with zeros, and sectors larger than 256 bytes are truncated.
+func (d Disk) TRDImage() []byte {
+	var image []byte
+
+	for c := uint8(0); c < d.Cylinders; c++ {
+		for h := uint8(0); h < d.Sides; h++ {
+			track, _ := d.Track(c, h)
+
+			for id := uint8(1); id <= TRDSectorsPerTrack; id++ {
+				data := make([]byte, TRDSectorSize)
+				if s, ok := track.Sector(id); ok {
+					copy(data, s.Data)
+				}
+				image = append(image, data...)
+			}
+		}
+	}
+
+	return image
+}
+
+// SystemTrack returns the logical data of track 0, side 0 which holds the
+// TR-DOS catalogue (sectors 1-8) and disk information (sector 9).
+func (d Disk) SystemTrack() ([]byte, error) {
+	track, ok := d.Track(0, 0)
+	if !ok {
+		return nil, fmt.Errorf("track 0 not found")
+	}
+
+	var data []byte
+	for id := uint8(1); id <= TRDSectorsPerTrack; id++ {
+		s, ok := track.Sector(id)
+		if !ok {
+			return nil, fmt.Errorf("system track sector %d not found", id)
+		}
+		sector := make([]byte, TRDSectorSize)
+		copy(sector, s.Data)
+		data = append(data, sector...)
+	}
+	return data, nil
+}
+
+// Catalogue reads the TR-DOS catalogue from the logical sectors of the
+// system track.
+func (d Disk) Catalogue() (*trd.TRD, error) {
+	system, err := d.SystemTrack()
+	if err != nil {
+		return nil, err
+	}
+
+	catalogue := trd.New(storage.NewReader(bytes.NewReader(system)))
+	if err := catalogue.Read(); err != nil {
+		return nil, err
+	}
+
+	return catalogue, nil
+}
@@ -4,4 +4,21 @@ type Image interface {
 	Read() error
 	DisplayGeometry()
 	DisplayBASIC()
+	CommandDir()
+}
+
+// FileExtractor is implemented by the disk and microdrive media types that
+// store named files which can be extracted.
+type FileExtractor interface {
+	Image
+	ExtractFiles() []File
+}
+
+// File is a single file as stored on a disk or microdrive image. The Data does
+// not include any header stored by the DOS, only the file contents.
+type File struct {
+	Name      string // filename as stored on the media, without padding
+	Extension string // short label for the type of file, e.g. "bas", "code"
+	Data      []byte
+	Warnings  []string // problems found reading the file, Data holds what could be read
 }
@@ -0,0 +1,218 @@
+package mdr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// fileHeaderSize is the length of the header stored at the start of the first
+// record of every SAVE'd file. PRINT files do not have this header.
+const fileHeaderSize = 9
+
+// File is rebuilt by joining together all records with the same filename,
+// in the order of their record number.
+type File struct {
+	Filename [10]byte
+	Records  []Record
+	Errors   []string // problems found while rebuilding the file
+
+	Header *FileHeader // nil for PRINT files
+}
+
+// FileHeader is stored in the first 9 bytes of the first record, and is very
+// similar to the tape header block.
+type FileHeader struct {
+	Type          uint8  // 0 = BASIC, 1 = number array, 2 = character array, 3 = CODE
+	Length        uint16 // Length of the data following the header
+	StartAddress  uint16 // Start address of the data
+	ProgramLength uint16 // BASIC only: length of the program without variables
+	AutoStartLine uint16 // BASIC only: auto-start line number, >= 32768 if not set
+}
+
+// Name returns the human readable filename.
+func (f File) Name() string {
+	return strings.TrimRight(string(f.Filename[:]), " ")
+}
+
+// TypeName returns a label for the file type.
+func (f File) TypeName() string {
+	if f.Header == nil {
+		return "Print File"
+	}
+
+	switch f.Header.Type {
+	case 0:
+		return "BASIC Program"
+	case 1:
+		return "Numeric Data Array"
+	case 2:
+		return "Alphanumeric Data Array"
+	case 3:
+		if f.Header.Length == 6912 && f.Header.StartAddress == 16384 {
+			return "SCREEN$"
+		}
+		return "Machine Code"
+	default:
+		return fmt.Sprintf("Unknown type (%d)", f.Header.Type)
+	}
+}
+
+// Extension returns a short file type label, used when extracting the file.
+func (f File) Extension() string {
+	if f.Header == nil {
+		return "prn"
+	}
+
+	switch f.Header.Type {
+	case 0:
+		return "bas"
+	case 1:
+		return "num"
+	case 2:
+		return "str"
+	case 3:
+		if f.Header.Length == 6912 && f.Header.StartAddress == 16384 {
+			return "scr"
+		}
+		return "code"
+	default:
+		return "bin"
+	}
+}
+
+// Data returns the file contents, minus the file header.
+func (f File) Data() []byte {
+	var data []byte
+	for _, r := range f.Records {
+		data = append(data, r.BlockData()...)
+	}
+
+	if f.Header == nil {
+		return data
+	}
+
+	data = data[fileHeaderSize:]
+	if int(f.Header.Length) < len(data) {
+		data = data[:f.Header.Length]
+	}
+	return data
+}
+
+// ProgramData returns the BASIC program without its variables.
+func (f File) ProgramData() []byte {
+	data := f.Data()
+	if f.Header != nil && int(f.Header.ProgramLength) < len(data) {
+		data = data[:f.Header.ProgramLength]
+	}
+	return data
+}
+
+// IsProgram returns true for BASIC program files.
+func (f File) IsProgram() bool {
+	return f.Header != nil && f.Header.Type == 0
+}
+
+// SectorCount is the number of sectors used by the file.
+func (f File) SectorCount() int {
+	return len(f.Records)
+}
+
+func (f File) String() string {
+	str := fmt.Sprintf("%s\n", f.TypeName())
+	str += fmt.Sprintf("    - Filename     : %s\n", f.Name())
+	str += fmt.Sprintf("    - Sectors      : %d\n", f.SectorCount())
+	if f.Header != nil {
+		str += fmt.Sprintf("    - Length       : %d\n", f.Header.Length)
+		switch f.Header.Type {
+		case 0:
+			if f.Header.AutoStartLine < 32768 {
+				str += fmt.Sprintf("    - AutoStartLine: %d\n", f.Header.AutoStartLine)
+			}
+		case 3:
+			str += fmt.Sprintf("    - Start Address: %d\n", f.Header.StartAddress)
+		}
+	}
+	for _, e := range f.Errors {
+		str += fmt.Sprintf("    - WARNING      : %s\n", e)
+	}
+	return strings.TrimRight(str, "\n")
+}
+
+// rebuildFiles groups the in-use records by filename, then orders each group
+// by record number, checking that no records are missing from the sequence.
+func rebuildFiles(sectors []Sector) []File {
+	var files []File
+	index := make(map[[10]byte]int)
+
+	for _, s := range sectors {
+		if !s.Record.InUse() {
+			continue
+		}
+
+		i, ok := index[s.Record.Filename]
+		if !ok {
+			i = len(files)
+			index[s.Record.Filename] = i
+			files = append(files, File{Filename: s.Record.Filename})
+		}
+		files[i].Records = append(files[i].Records, s.Record)
+
+		if !s.Record.ValidDescriptor() || !s.Record.ValidData() {
+			files[i].Errors = append(files[i].Errors, fmt.Sprintf("checksum error in sector %d", s.Header.Number))
+		}
+	}
+
+	for i := range files {
+		files[i].sortRecords()
+		files[i].validateRecordSequence()
+		files[i].readHeader()
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	return files
+}
+
+func (f *File) sortRecords() {
+	sort.SliceStable(f.Records, func(i, j int) bool {
+		return f.Records[i].Number < f.Records[j].Number
+	})
+}
+
+func (f *File) validateRecordSequence() {
+	for i, r := range f.Records {
+		if int(r.Number) != i {
+			f.Errors = append(f.Errors, fmt.Sprintf("record %d missing or duplicated", i))
+			return
+		}
+	}
+
+	if !f.Records[len(f.Records)-1].IsLastRecord() {
+		f.Errors = append(f.Errors, "last record (EOF) is missing")
+	}
+}
+
+func (f *File) readHeader() {
+	first := f.Records[0]
+	if first.IsPrintFile() {
+		return
+	}
+
+	data := first.BlockData()
+	if len(data) < fileHeaderSize {
+		f.Errors = append(f.Errors, "file header is incomplete")
+		return
+	}
+
+	f.Header = &FileHeader{
+		Type:          data[0],
+		Length:        binary.LittleEndian.Uint16(data[1:3]),
+		StartAddress:  binary.LittleEndian.Uint16(data[3:5]),
+		ProgramLength: binary.LittleEndian.Uint16(data[5:7]),
+		AutoStartLine: binary.LittleEndian.Uint16(data[7:9]),
+	}
+}
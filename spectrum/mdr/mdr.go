@@ -0,0 +1,172 @@
+// Package mdr implements reading of ZX Microdrive cartridge images, as used
+// with the ZX Interface 1.
+//
+// An MDR image is a sequence of 254 sectors of 543 bytes, followed by a single
+// byte write protection flag (non-zero when the cartridge is write protected),
+// giving a file size of 137923 bytes.
+//
+// Files are stored as one or more records, each record occupying the data block
+// of a single sector. A file is rebuilt by collecting all records with the same
+// filename and ordering them by their record number.
+//
+// https://faqwiki.zxnet.co.uk/wiki/Microdrive_and_Interface_1
+package mdr
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mrcook/retroio/spectrum"
+	"github.com/mrcook/retroio/spectrum/basic"
+	"github.com/mrcook/retroio/storage"
+)
+
+const maxSectors = 254
+
+// MDR cartridge image
+type MDR struct {
+	reader *storage.Reader
+
+	Sectors        []Sector
+	WriteProtected bool
+	Files          []File
+}
+
+func New(reader *storage.Reader) *MDR {
+	return &MDR{reader: reader}
+}
+
+// Read all sectors on the cartridge, and rebuild the files from their records.
+func (m *MDR) Read() error {
+	for {
+		if _, err := m.reader.Peek(sectorSize); err != nil {
+			break
+		}
+		if len(m.Sectors) == maxSectors {
+			break
+		}
+
+		sector := Sector{}
+		if err := sector.Read(m.reader); err != nil {
+			return fmt.Errorf("error reading sector #%d: %w", len(m.Sectors)+1, err)
+		}
+		m.Sectors = append(m.Sectors, sector)
+	}
+
+	if len(m.Sectors) == 0 {
+		return fmt.Errorf("no sectors found, invalid cartridge image")
+	}
+
+	flag, err := m.reader.PeekByte()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	m.WriteProtected = flag > 0
+
+	m.Files = rebuildFiles(m.Sectors)
+
+	return nil
+}
+
+// CartridgeName is taken from the first valid sector header.
+func (m MDR) CartridgeName() string {
+	for _, s := range m.Sectors {
+		if s.Header.Valid() {
+			return strings.TrimRight(string(s.Header.Name[:]), " ")
+		}
+	}
+	return ""
+}
+
+// FreeSectors counts the sectors not allocated to any file.
+func (m MDR) FreeSectors() int {
+	free := 0
+	for _, s := range m.Sectors {
+		if s.Header.Valid() && !s.Record.InUse() {
+			free++
+		}
+	}
+	return free
+}
+
+// ExtractFiles returns the contents of every file on the cartridge.
+func (m MDR) ExtractFiles() []spectrum.File {
+	var files []spectrum.File
+	for _, f := range m.Files {
+		files = append(files, spectrum.File{
+			Name:      f.Name(),
+			Extension: f.Extension(),
+			Data:      f.Data(),
+			Warnings:  f.Errors,
+		})
+	}
+	return files
+}
+
+// DisplayGeometry outputs the cartridge, file and sector metadata to the terminal.
+func (m MDR) DisplayGeometry() {
+	fmt.Println("CARTRIDGE INFORMATION:")
+	fmt.Printf("Name:            %s\n", m.CartridgeName())
+	fmt.Printf("Sectors:         %d\n", len(m.Sectors))
+	fmt.Printf("Free sectors:    %d\n", m.FreeSectors())
+	fmt.Printf("Write protected: %t\n", m.WriteProtected)
+	fmt.Println()
+
+	fmt.Println("FILES:")
+	for i, f := range m.Files {
+		fmt.Printf("#%02d %s\n", i+1, f)
+	}
+
+	// Only sectors with errors are listed, a full listing is rather noisy.
+	var errors []string
+	for _, s := range m.Sectors {
+		if !s.Header.Valid() || !s.Record.ValidDescriptor() || (s.Record.InUse() && !s.Record.ValidData()) {
+			errors = append(errors, s.String())
+		}
+	}
+	if len(errors) > 0 {
+		fmt.Println()
+		fmt.Println("SECTOR ERRORS:")
+		for _, e := range errors {
+			fmt.Println(e)
+		}
+	}
+}
+
+// CommandDir displays the cartridge catalogue, as given by the Interface 1
+// CAT command: cartridge name, filenames in alphabetical order, and the free
+// space in kilobytes.
+func (m MDR) CommandDir() {
+	fmt.Println(m.CartridgeName())
+	fmt.Println()
+	for _, f := range m.Files {
+		fmt.Println(f.Name())
+	}
+	fmt.Println()
+	fmt.Printf("%3d\n", m.FreeSectors()/2)
+}
+
+// DisplayBASIC outputs all BASIC programs on the cartridge
+func (m MDR) DisplayBASIC() {
+	fmt.Println("BASIC PROGRAMS:")
+	fmt.Println()
+	for _, f := range m.Files {
+		if !f.IsProgram() {
+			continue
+		}
+
+		fmt.Printf("FILE: %s\n", f.Name())
+		program, err := basic.Decode(f.ProgramData())
+		if err != nil {
+			fmt.Printf("    %s\n", err)
+			continue
+		}
+
+		for _, line := range program {
+			fmt.Printf("%s", line)
+		}
+		fmt.Println()
+		fmt.Println()
+	}
+}
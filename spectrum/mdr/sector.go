@@ -0,0 +1,145 @@
+package mdr
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mrcook/retroio/storage"
+)
+
+const (
+	sectorSize     = 543 // header + record descriptor + data + data checksum
+	recordDataSize = 512
+)
+
+// Sector as stored on the cartridge. Each sector is made up of a 15 byte
+// header block, followed by a record block containing a 15 byte descriptor,
+// 512 bytes of data, and a final data checksum byte.
+//
+// The 12 byte preamble (10 zeros and 2 FFs) written by the Interface 1 before
+// each block is not stored in the MDR image.
+type Sector struct {
+	Header Header
+	Record Record
+}
+
+// Header block of a sector.
+type Header struct {
+	Flag     uint8    // HDFLAG: bit 0 is always set, indicating a header block.
+	Number   uint8    // HDNUMB: sector number, counting down from 254 to 1.
+	Unused   [2]uint8 // Not used.
+	Name     [10]byte // HDNAME: cartridge name, padded with spaces.
+	Checksum uint8    // HDCHK: checksum of the preceding 14 bytes.
+}
+
+// Record block of a sector.
+type Record struct {
+	// RECFLG
+	//   bit 0: always reset, indicating a record block
+	//   bit 1: set for the last record (EOF) of a file
+	//   bit 2: reset for a PRINT file, set for a SAVE'd file
+	Flag               uint8
+	Number             uint8    // RECNUM: record number within the file, starting from 0.
+	Length             uint16   // RECLEN: number of bytes used in the data block, max. 512.
+	Filename           [10]byte // RECNAM: filename, padded with spaces.
+	DescriptorChecksum uint8    // DESCHK: checksum of the preceding 14 bytes.
+
+	Data         [recordDataSize]byte // DATA: the record data (only RECLEN bytes are valid).
+	DataChecksum uint8                // DCHK: checksum of the 512 data bytes.
+}
+
+// Read a sector from the cartridge image.
+func (s *Sector) Read(reader *storage.Reader) error {
+	return binary.Read(reader, binary.LittleEndian, s)
+}
+
+// Valid header block, with correct flag bit and checksum.
+func (h Header) Valid() bool {
+	if h.Flag&0x01 == 0 {
+		return false
+	}
+	return h.Checksum == h.calculatedChecksum()
+}
+
+func (h Header) calculatedChecksum() uint8 {
+	b := []byte{h.Flag, h.Number, h.Unused[0], h.Unused[1]}
+	b = append(b, h.Name[:]...)
+	return checksum(b)
+}
+
+// InUse reports whether the record is allocated to a file. Free sectors have
+// neither the EOF flag set nor any data stored.
+func (r Record) InUse() bool {
+	return r.Flag&0x02 > 0 || r.Length > 0
+}
+
+// IsLastRecord returns true for the final (EOF) record of a file.
+func (r Record) IsLastRecord() bool {
+	return r.Flag&0x02 > 0
+}
+
+// IsPrintFile returns true for files created with OPEN # and PRINT #, these
+// files do not contain the 9 byte file header.
+func (r Record) IsPrintFile() bool {
+	return r.Flag&0x04 == 0
+}
+
+// ValidDescriptor checks the record flag bit and the descriptor checksum.
+func (r Record) ValidDescriptor() bool {
+	if r.Flag&0x01 != 0 {
+		return false
+	}
+	return r.DescriptorChecksum == r.calculatedDescriptorChecksum()
+}
+
+// ValidData checks the data block checksum.
+func (r Record) ValidData() bool {
+	return r.DataChecksum == checksum(r.Data[:])
+}
+
+// BlockData returns the valid data bytes of the record.
+func (r Record) BlockData() []byte {
+	length := int(r.Length)
+	if length > recordDataSize {
+		length = recordDataSize
+	}
+	return r.Data[:length]
+}
+
+func (r Record) calculatedDescriptorChecksum() uint8 {
+	b := []byte{r.Flag, r.Number, uint8(r.Length), uint8(r.Length >> 8)}
+	b = append(b, r.Filename[:]...)
+	return checksum(b)
+}
+
+// String returns a formatted summary of the sector, including checksum errors.
+func (s Sector) String() string {
+	str := fmt.Sprintf("Sector %3d: ", s.Header.Number)
+	if s.Record.InUse() {
+		str += fmt.Sprintf("%-10s record %d, %d bytes", s.Record.Filename, s.Record.Number, s.Record.Length)
+	} else {
+		str += "free"
+	}
+
+	if !s.Header.Valid() {
+		str += " - HEADER CHECKSUM ERROR"
+	}
+	if !s.Record.ValidDescriptor() {
+		str += " - DESCRIPTOR CHECKSUM ERROR"
+	}
+	if s.Record.InUse() && !s.Record.ValidData() {
+		str += " - DATA CHECKSUM ERROR"
+	}
+
+	return str
+}
+
+// The Interface 1 checksum is the sum of all bytes, with the carry being
+// added back in after each addition, which works out as the sum modulo 255.
+func checksum(data []byte) uint8 {
+	sum := 0
+	for _, b := range data {
+		sum += int(b)
+	}
+	return uint8(sum % 255)
+}
@@ -0,0 +1,249 @@
+package mgt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	dirEntrySize     = 256
+	dirEntries       = 80
+	sectorMapSize    = 195 // bytes, one bit for each sector outside the directory tracks
+	fileHeaderLength = 9   // copy of the file header stored at the start of the file data
+)
+
+// DirectoryEntry is the 256 byte directory entry as used by the +D, DISCiPLE
+// and the SAM Coupé.
+//
+// NOTE: the sector count is stored in big endian (MSB first) byte order,
+// while all the other values are in little endian order.
+type DirectoryEntry struct {
+	Status      uint8    // bits 0-5 file type, bit 6 protected, bit 7 hidden (SAM)
+	Filename    [10]byte // padded with spaces
+	Sectors     uint16   // number of sectors used by the file
+	FirstTrack  uint8    // track of the first sector: 0-79 side 1, 128-207 side 2
+	FirstSector uint8    // sector number of the first sector: 1-10
+
+	// Sector Address Map: one bit for each sector on the disk, starting at
+	// track 4, sector 1. A set bit marks a sector used by this file.
+	SectorMap [sectorMapSize]uint8
+
+	// +D/DISCiPLE file information (types 1-11)
+	Header   FileHeader        // Copy of the file header, 211-219
+	Snapshot SnapshotRegisters // Snapshot registers (types 5 and 9), 220-241
+
+	// SAM Coupé file information (types 16+)
+	SAM SAMFileInfo
+
+	raw [dirEntrySize]byte
+}
+
+// FileHeader is the same layout as the Spectrum tape header, minus the name.
+type FileHeader struct {
+	Type          uint8  // 0 = BASIC, 1 = number array, 2 = character array, 3 = CODE
+	Length        uint16 // Length of the data
+	StartAddress  uint16 // Start address of the data
+	ProgramLength uint16 // BASIC only: length of the program without variables
+	AutoStartLine uint16 // BASIC only: auto-start line number
+}
+
+// SnapshotRegisters are stored in the directory entry of snapshot files. The
+// remaining registers are stored on the stack in the snapshot memory.
+type SnapshotRegisters struct {
+	IY        uint16
+	IX        uint16
+	DEx       uint16 // DE'
+	BCx       uint16 // BC'
+	HLx       uint16 // HL'
+	AFx       uint16 // AF'
+	DE        uint16
+	BC        uint16
+	HL        uint16
+	Interrupt uint8 // Interrupt status
+	I         uint8
+	SP        uint16
+}
+
+// SAMFileInfo holds the file header values for the SAM Coupé file types.
+// The SAM has 512K of paged memory, so addresses are given as a page number
+// and an offset into the page.
+type SAMFileInfo struct {
+	StartPage   uint8    // bits 0-4 hold the start page
+	PageOffset  uint16   // offset within the start page (8000-BFFF)
+	Pages       uint8    // length, number of 16K pages
+	LengthMod   uint16   // length, modulo 16384
+	ExecAddress [3]uint8 // execution address (page/offset), $FF when not executable
+}
+
+func (e *DirectoryEntry) Read(data []byte) error {
+	if len(data) != dirEntrySize {
+		return fmt.Errorf("invalid directory entry size: %d", len(data))
+	}
+	copy(e.raw[:], data)
+
+	e.Status = data[0]
+	copy(e.Filename[:], data[1:11])
+	e.Sectors = binary.BigEndian.Uint16(data[11:13])
+	e.FirstTrack = data[13]
+	e.FirstSector = data[14]
+	copy(e.SectorMap[:], data[15:210])
+
+	e.Header = FileHeader{
+		Type:          data[211],
+		Length:        binary.LittleEndian.Uint16(data[212:214]),
+		StartAddress:  binary.LittleEndian.Uint16(data[214:216]),
+		ProgramLength: binary.LittleEndian.Uint16(data[216:218]),
+		AutoStartLine: binary.LittleEndian.Uint16(data[218:220]),
+	}
+
+	e.Snapshot = SnapshotRegisters{
+		IY:        binary.LittleEndian.Uint16(data[220:222]),
+		IX:        binary.LittleEndian.Uint16(data[222:224]),
+		DEx:       binary.LittleEndian.Uint16(data[224:226]),
+		BCx:       binary.LittleEndian.Uint16(data[226:228]),
+		HLx:       binary.LittleEndian.Uint16(data[228:230]),
+		AFx:       binary.LittleEndian.Uint16(data[230:232]),
+		DE:        binary.LittleEndian.Uint16(data[232:234]),
+		BC:        binary.LittleEndian.Uint16(data[234:236]),
+		HL:        binary.LittleEndian.Uint16(data[236:238]),
+		Interrupt: data[238],
+		I:         data[239],
+		SP:        binary.LittleEndian.Uint16(data[240:242]),
+	}
+
+	e.SAM = SAMFileInfo{
+		StartPage:  data[236] & 0x1F,
+		PageOffset: binary.LittleEndian.Uint16(data[237:239]),
+		Pages:      data[239],
+		LengthMod:  binary.LittleEndian.Uint16(data[240:242]),
+	}
+	copy(e.SAM.ExecAddress[:], data[242:245])
+
+	return nil
+}
+
+// Type of the file, without the hidden/protected flags.
+func (e DirectoryEntry) Type() FileType {
+	return FileType(e.Status & 0x3F)
+}
+
+// IsErased returns true for free directory entries.
+func (e DirectoryEntry) IsErased() bool {
+	return e.Type() == Erased
+}
+
+// IsHidden is only used by the SAM Coupé.
+func (e DirectoryEntry) IsHidden() bool {
+	return e.Status&0x80 > 0
+}
+
+// IsProtected is only used by the SAM Coupé.
+func (e DirectoryEntry) IsProtected() bool {
+	return e.Status&0x40 > 0
+}
+
+// Name returns the filename without padding.
+func (e DirectoryEntry) Name() string {
+	return strings.TrimRight(string(e.Filename[:]), " ")
+}
+
+// Length returns the file length in bytes, as given in the file header.
+func (e DirectoryEntry) Length() int {
+	if e.Type().IsSAM() {
+		return int(e.SAM.Pages)*16384 + int(e.SAM.LengthMod)
+	}
+	switch e.Type() {
+	case ZXSnapshot48:
+		return 49152
+	case ZXSnapshot128:
+		return 131073 // 128K memory and the last value of port 7FFD
+	}
+	return int(e.Header.Length)
+}
+
+// UsesSector checks the sector address map for the given track/sector.
+func (e DirectoryEntry) UsesSector(track, sector uint8) bool {
+	bit, ok := sectorMapBit(track, sector)
+	if !ok {
+		return false
+	}
+	return e.SectorMap[bit/8]&(1<<(bit%8)) > 0
+}
+
+// SectorMapCount returns the number of sectors marked as used in the map.
+func (e DirectoryEntry) SectorMapCount() int {
+	count := 0
+	for _, b := range e.SectorMap {
+		for ; b > 0; b >>= 1 {
+			count += int(b & 1)
+		}
+	}
+	return count
+}
+
+func (e DirectoryEntry) String() string {
+	t := e.Type()
+
+	str := fmt.Sprintf("%s\n", t)
+	str += fmt.Sprintf("    - Filename     : %s\n", e.Name())
+	str += fmt.Sprintf("    - Sectors      : %d\n", e.Sectors)
+	str += fmt.Sprintf("    - First Sector : track %d, sector %d\n", e.FirstTrack, e.FirstSector)
+
+	switch {
+	case t == ZXBasic:
+		str += fmt.Sprintf("    - Length       : %d\n", e.Header.Length)
+		if e.Header.AutoStartLine < 32768 {
+			str += fmt.Sprintf("    - AutoStartLine: %d\n", e.Header.AutoStartLine)
+		}
+	case t == ZXCode || t == ZXScreen:
+		str += fmt.Sprintf("    - Length       : %d\n", e.Header.Length)
+		str += fmt.Sprintf("    - Start Address: %d\n", e.Header.StartAddress)
+	case t == ZXSnapshot48 || t == ZXSnapshot128:
+		str += e.Snapshot.String()
+	case t.IsSAM():
+		str += fmt.Sprintf("    - Length       : %d\n", e.Length())
+		str += fmt.Sprintf("    - Start        : page %d, offset %d\n", e.SAM.StartPage, e.SAM.PageOffset)
+		if e.SAM.ExecAddress[0] != 0xFF {
+			str += fmt.Sprintf("    - Execute      : page %d, offset %d\n", e.SAM.ExecAddress[0], binary.LittleEndian.Uint16(e.SAM.ExecAddress[1:]))
+		}
+	}
+
+	if e.IsHidden() {
+		str += "    - Hidden\n"
+	}
+	if e.IsProtected() {
+		str += "    - Protected\n"
+	}
+
+	return strings.TrimRight(str, "\n")
+}
+
+func (r SnapshotRegisters) String() string {
+	str := "    - Registers    :\n"
+	str += fmt.Sprintf("        BC  %04X  DE  %04X  HL  %04X\n", r.BC, r.DE, r.HL)
+	str += fmt.Sprintf("        BC' %04X  DE' %04X  HL' %04X  AF' %04X\n", r.BCx, r.DEx, r.HLx, r.AFx)
+	str += fmt.Sprintf("        IX  %04X  IY  %04X  SP  %04X\n", r.IX, r.IY, r.SP)
+	str += fmt.Sprintf("        I   %02X    Interrupt status %02X\n", r.I, r.Interrupt)
+	return str
+}
+
+// sectorMapBit returns the bit position in the sector address map for a
+// track/sector. The map starts at track 4, as tracks 0-3 hold the directory.
+func sectorMapBit(track, sector uint8) (int, bool) {
+	if sector < 1 || sector > sectorsPerTrack {
+		return 0, false
+	}
+
+	logicalTrack := int(track)
+	if track >= 128 {
+		logicalTrack = int(track-128) + tracksPerSide
+	}
+	logicalTrack -= directoryTracks
+
+	if logicalTrack < 0 || logicalTrack >= tracksPerSide*2-directoryTracks {
+		return 0, false
+	}
+
+	return logicalTrack*sectorsPerTrack + int(sector-1), true
+}
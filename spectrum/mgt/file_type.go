@@ -0,0 +1,119 @@
+package mgt
+
+import "fmt"
+
+// FileType of a directory entry, bits 0-5 of the first byte of the entry.
+// Types 1-11 are used by the +D and DISCiPLE, types 16 and above by the
+// SAM Coupé (SAMDOS and MasterDOS).
+type FileType uint8
+
+const (
+	Erased        FileType = 0
+	ZXBasic       FileType = 1
+	ZXNumArray    FileType = 2
+	ZXStrArray    FileType = 3
+	ZXCode        FileType = 4
+	ZXSnapshot48  FileType = 5
+	ZXMicrodrive  FileType = 6
+	ZXScreen      FileType = 7
+	Special       FileType = 8
+	ZXSnapshot128 FileType = 9
+	OpenType      FileType = 10
+	Execute       FileType = 11
+	SAMBasic      FileType = 16
+	SAMNumArray   FileType = 17
+	SAMStrArray   FileType = 18
+	SAMCode       FileType = 19
+	SAMScreen     FileType = 20
+	SAMDirectory  FileType = 21
+	SAMDriverApp  FileType = 22
+	SAMDriverBoot FileType = 23
+	EDOSNomen     FileType = 24
+	EDOSSystem    FileType = 25
+	EDOSOverlay   FileType = 26
+	HDOSDocument  FileType = 28
+	HDOSDirectory FileType = 29
+	HDOSDisk      FileType = 30
+	HDOSTemp      FileType = 31
+)
+
+type fileTypeLabel struct {
+	abbreviation string // as shown in a CAT listing
+	extension    string // used when extracting files
+	description  string
+}
+
+var fileTypeLabels = map[FileType]fileTypeLabel{
+	Erased:        {"ERA", "", "Erased"},
+	ZXBasic:       {"BAS", "bas", "ZX BASIC Program"},
+	ZXNumArray:    {"D.ARRAY", "num", "ZX Numeric Data Array"},
+	ZXStrArray:    {"$.ARRAY", "str", "ZX Alphanumeric Data Array"},
+	ZXCode:        {"CDE", "code", "ZX Machine Code"},
+	ZXSnapshot48:  {"SNP 48k", "snp48", "ZX Snapshot 48K"},
+	ZXMicrodrive:  {"MD.FILE", "mdr", "ZX Microdrive File"},
+	ZXScreen:      {"SCREEN$", "scr", "ZX SCREEN$"},
+	Special:       {"SPECIAL", "bin", "Special"},
+	ZXSnapshot128: {"SNP 128k", "snp128", "ZX Snapshot 128K"},
+	OpenType:      {"OPENTYPE", "bin", "Open Type File"},
+	Execute:       {"EXECUTE", "bin", "Execute File"},
+	SAMBasic:      {"BASIC", "bas", "SAM BASIC Program"},
+	SAMNumArray:   {"D.ARRAY", "num", "SAM Numeric Data Array"},
+	SAMStrArray:   {"$.ARRAY", "str", "SAM Alphanumeric Data Array"},
+	SAMCode:       {"C", "code", "SAM Machine Code"},
+	SAMScreen:     {"SCREEN$", "scr", "SAM SCREEN$"},
+	SAMDirectory:  {"<DIR>", "", "MasterDOS Sub-directory"},
+	SAMDriverApp:  {"DRIVER APP", "bin", "SAM Driver Application"},
+	SAMDriverBoot: {"DRIVER BOOT", "bin", "SAM Driver Boot"},
+	EDOSNomen:     {"NOMEN", "bin", "EDOS Nomen"},
+	EDOSSystem:    {"SYSTEM", "bin", "EDOS System"},
+	EDOSOverlay:   {"OVERLAY", "bin", "EDOS Overlay"},
+	HDOSDocument:  {"DOC", "bin", "HDOS Document"},
+	HDOSDirectory: {"HDIR", "bin", "HDOS Directory"},
+	HDOSDisk:      {"HDSK", "bin", "HDOS Disk"},
+	HDOSTemp:      {"TEMP", "bin", "HDOS Temporary"},
+}
+
+// Abbreviation returns the file type as shown in the CAT listing.
+func (t FileType) Abbreviation() string {
+	if l, ok := fileTypeLabels[t]; ok {
+		return l.abbreviation
+	}
+	return "???"
+}
+
+// Extension returns a short label used for the extension of extracted files.
+func (t FileType) Extension() string {
+	if l, ok := fileTypeLabels[t]; ok && l.extension != "" {
+		return l.extension
+	}
+	return "bin"
+}
+
+// IsSpectrum returns true for the +D/DISCiPLE Spectrum file types.
+func (t FileType) IsSpectrum() bool {
+	return t >= ZXBasic && t <= Execute
+}
+
+// IsSAM returns true for the SAM Coupé file types.
+func (t FileType) IsSAM() bool {
+	return t >= SAMBasic
+}
+
+// hasFileHeader returns true for types that store a copy of their 9 byte
+// file header at the start of the file data.
+func (t FileType) hasFileHeader() bool {
+	switch t {
+	case ZXBasic, ZXNumArray, ZXStrArray, ZXCode, ZXScreen:
+		return true
+	case SAMBasic, SAMNumArray, SAMStrArray, SAMCode, SAMScreen:
+		return true
+	}
+	return false
+}
+
+func (t FileType) String() string {
+	if l, ok := fileTypeLabels[t]; ok {
+		return l.description
+	}
+	return fmt.Sprintf("Unknown type (%d)", uint8(t))
+}
@@ -0,0 +1,256 @@
+// Package mgt implements reading of MGT disk images, as used by the +D and
+// DISCiPLE disk interfaces for the ZX Spectrum, and by the SAM Coupé.
+//
+// An MGT image is a copy of an 800K disk: 2 sides of 80 tracks, with each
+// track holding 10 sectors of 512 bytes. The tracks are interleaved by side:
+//   track 0 side 0, track 0 side 1, track 1 side 0, etc.
+//
+// The first 4 tracks of side 0 hold the directory, 80 entries of 256 bytes.
+//
+// Files are stored as a chain of sectors, with the last two bytes of each
+// sector being the track/sector of the next sector in the chain. Track
+// numbers 0-79 are on side 0, and 128-207 are on side 1.
+//
+// https://faqwiki.zxnet.co.uk/wiki/MGT_filesystem
+package mgt
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/spectrum"
+	"github.com/mrcook/retroio/spectrum/basic"
+	"github.com/mrcook/retroio/storage"
+)
+
+const (
+	tracksPerSide   = 80
+	sectorsPerTrack = 10
+	sectorSize      = 512
+	directoryTracks = 4
+	totalSectors    = tracksPerSide * 2 * sectorsPerTrack
+	sectorDataSize  = sectorSize - 2 // the last two bytes are the next track/sector
+)
+
+// MGT disk image
+type MGT struct {
+	reader *storage.Reader
+
+	sectors   [][]byte // all sectors in the order they are stored in the image
+	Directory []DirectoryEntry
+}
+
+func New(reader *storage.Reader) *MGT {
+	return &MGT{reader: reader}
+}
+
+// Read the whole disk image, then the directory entries.
+func (m *MGT) Read() error {
+	for i := 0; i < totalSectors; i++ {
+		sector := make([]byte, sectorSize)
+		if _, err := m.reader.Read(sector); err != nil {
+			return fmt.Errorf("error reading sector #%d, expected an 800K disk image: %w", i, err)
+		}
+		m.sectors = append(m.sectors, sector)
+	}
+
+	for i := 0; i < dirEntries; i++ {
+		track := uint8(i / (sectorsPerTrack * 2))
+		sector := uint8((i/2)%sectorsPerTrack) + 1
+
+		data, err := m.sector(track, sector)
+		if err != nil {
+			return err
+		}
+
+		offset := (i % 2) * dirEntrySize
+		entry := DirectoryEntry{}
+		if err := entry.Read(data[offset : offset+dirEntrySize]); err != nil {
+			return fmt.Errorf("error reading directory entry #%d: %w", i+1, err)
+		}
+		m.Directory = append(m.Directory, entry)
+	}
+
+	return nil
+}
+
+// sector returns the data for the track/sector. Tracks on side 1 are
+// numbered from 128, and sectors are numbered from 1.
+func (m MGT) sector(track, sector uint8) ([]byte, error) {
+	side := 0
+	if track >= 128 {
+		side = 1
+		track -= 128
+	}
+	if track >= tracksPerSide || sector < 1 || sector > sectorsPerTrack {
+		return nil, fmt.Errorf("invalid track/sector: %d/%d", track+uint8(side*128), sector)
+	}
+
+	index := (int(track)*2+side)*sectorsPerTrack + int(sector-1)
+	return m.sectors[index], nil
+}
+
+// FileData follows the sector chain of a directory entry, returning the file
+// contents. Any copy of the file header stored at the start of the data is
+// removed. The data read so far is returned along with any error.
+func (m MGT) FileData(entry DirectoryEntry) ([]byte, error) {
+	var data []byte
+
+	track, sector := entry.FirstTrack, entry.FirstSector
+	visited := make(map[[2]uint8]bool)
+
+	for i := 0; i < int(entry.Sectors); i++ {
+		location := [2]uint8{track, sector}
+		if visited[location] {
+			return data, fmt.Errorf("circular sector chain at track %d, sector %d", track, sector)
+		}
+		visited[location] = true
+
+		if !entry.UsesSector(track, sector) {
+			return data, fmt.Errorf("track %d, sector %d is not in the sector address map", track, sector)
+		}
+
+		s, err := m.sector(track, sector)
+		if err != nil {
+			return data, err
+		}
+		data = append(data, s[:sectorDataSize]...)
+
+		track, sector = s[sectorDataSize], s[sectorDataSize+1]
+		if track == 0 && sector == 0 {
+			break
+		}
+	}
+
+	if entry.Type().hasFileHeader() && len(data) >= fileHeaderLength {
+		data = data[fileHeaderLength:]
+	}
+	if length := entry.Length(); length > 0 && length < len(data) {
+		data = data[:length]
+	}
+
+	return data, nil
+}
+
+// Files returns the used (non erased) directory entries.
+func (m MGT) Files() []DirectoryEntry {
+	var files []DirectoryEntry
+	for _, e := range m.Directory {
+		if !e.IsErased() {
+			files = append(files, e)
+		}
+	}
+	return files
+}
+
+// FreeSectors counts the sectors not allocated to any file.
+func (m MGT) FreeSectors() int {
+	var used [sectorMapSize]uint8
+	for _, e := range m.Files() {
+		for i, b := range e.SectorMap {
+			used[i] |= b
+		}
+	}
+
+	free := 0
+	for _, b := range used {
+		for i := 0; i < 8; i++ {
+			if b&(1<<i) == 0 {
+				free++
+			}
+		}
+	}
+	return free
+}
+
+// DisplayGeometry outputs the disk and file metadata to the terminal.
+func (m MGT) DisplayGeometry() {
+	fmt.Println("DISK INFORMATION:")
+	fmt.Printf("Type:         MGT (+D/DISCiPLE/SAM Coupé)\n")
+	fmt.Printf("Sides:        2\n")
+	fmt.Printf("Tracks:       %d\n", tracksPerSide)
+	fmt.Printf("Sectors:      %d per track, %d bytes\n", sectorsPerTrack, sectorSize)
+	fmt.Printf("Total files:  %d\n", len(m.Files()))
+	fmt.Printf("Free sectors: %d\n", m.FreeSectors())
+	fmt.Println()
+
+	fmt.Println("FILES:")
+	for i, e := range m.Directory {
+		if e.IsErased() {
+			continue
+		}
+		fmt.Printf("#%02d %s\n", i+1, e)
+
+		if count := e.SectorMapCount(); count != int(e.Sectors) {
+			fmt.Printf("    - WARNING      : sector map has %d sectors allocated\n", count)
+		}
+		if _, err := m.FileData(e); err != nil {
+			fmt.Printf("    - WARNING      : %s\n", err)
+		}
+	}
+}
+
+// CommandDir displays the disk catalogue, similar to the DISCiPLE/+D CAT command.
+func (m MGT) CommandDir() {
+	files := 0
+	for i, e := range m.Directory {
+		if e.IsErased() || e.IsHidden() {
+			continue
+		}
+		fmt.Printf("%3d  %-10s %4d  %s\n", i+1, e.Name(), e.Sectors, e.Type().Abbreviation())
+		files++
+	}
+
+	fmt.Println()
+	fmt.Printf("%d Files, %d Free Slots, %dK Free\n", files, dirEntries-len(m.Files()), m.FreeSectors()/2)
+}
+
+// DisplayBASIC outputs all ZX Spectrum BASIC programs on the disk.
+func (m MGT) DisplayBASIC() {
+	fmt.Println("BASIC PROGRAMS:")
+	fmt.Println()
+	for _, e := range m.Directory {
+		if e.Type() != ZXBasic {
+			continue
+		}
+
+		fmt.Printf("FILE: %s\n", e.Name())
+		data, err := m.FileData(e)
+		if err != nil {
+			fmt.Printf("    %s\n", err)
+			continue
+		}
+		if int(e.Header.ProgramLength) < len(data) {
+			data = data[:e.Header.ProgramLength]
+		}
+
+		program, err := basic.Decode(data)
+		if err != nil {
+			fmt.Printf("    %s\n", err)
+			continue
+		}
+
+		for _, line := range program {
+			fmt.Printf("%s", line)
+		}
+		fmt.Println()
+		fmt.Println()
+	}
+}
+
+// ExtractFiles returns the contents of every file on the disk. Files with a
+// broken sector chain are returned with the data that could be read.
+func (m MGT) ExtractFiles() []spectrum.File {
+	var files []spectrum.File
+	for _, e := range m.Files() {
+		file := spectrum.File{Name: e.Name(), Extension: e.Type().Extension()}
+
+		data, err := m.FileData(e)
+		if err != nil {
+			file.Warnings = append(file.Warnings, err.Error())
+		}
+		file.Data = data
+
+		files = append(files, file)
+	}
+	return files
+}
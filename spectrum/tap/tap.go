@@ -159,6 +159,10 @@ func (t TAP) DisplayGeometry() {
 	}
 }
 
+func (t TAP) CommandDir() {
+	fmt.Println("directory listing unsupported for tapes")
+}
+
 // DisplayBASIC outputs all BASIC programs
 func (t TAP) DisplayBASIC() {
 	isProgram := false
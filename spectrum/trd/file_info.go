@@ -59,6 +59,19 @@ func (i FileInformation) IsDeleted() bool {
 	return false
 }
 
+// Extension returns the TR-DOS file type character.
+func (i FileInformation) Extension() byte {
+	switch t := i.FileType.(type) {
+	case *FileTypeBasic:
+		return 'B'
+	case *FileTypeCode:
+		return 'C'
+	case *FileTypeOther:
+		return t.Extension
+	}
+	return '?'
+}
+
 func (i FileInformation) String() string {
 	str := fmt.Sprintf("%s\n", i.FileType.Name())
 	str += fmt.Sprintf(" - Filename:    %s\n", i.Filename)
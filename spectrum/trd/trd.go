@@ -58,6 +58,23 @@ func (t TRD) DisplayGeometry() {
 	}
 }
 
+// CommandDir displays the disk catalogue, similar to the TR-DOS CAT command.
+func (t TRD) CommandDir() {
+	fmt.Printf("Title: %s\n", t.Info.Label)
+	fmt.Printf("%d File(s)  %d Deleted File(s)\n", t.Info.NumFiles, t.Info.NumDeletedFiles)
+	fmt.Println()
+
+	for _, file := range t.Files {
+		if file.IsDeleted() {
+			continue
+		}
+		fmt.Printf("%s<%c> %3d\n", file.Filename, file.Extension(), file.LengthInSectors)
+	}
+
+	fmt.Println()
+	fmt.Printf("Free sectors: %d\n", t.Info.NumFreeSectors)
+}
+
 // DisplayBASIC outputs all BASIC programs on the disk
 func (t TRD) DisplayBASIC() {
 	fmt.Println("Not implemented for Spectrum disk images")
@@ -32,6 +32,9 @@ type Text struct {
 	Characters []byte // Text string in ASCII format
 }
 
+// LoaderTextID is the Text ID of the protection scheme/loader.
+const LoaderTextID = 0x07
+
 // Headings for the Text ID's.
 var headings = map[uint8]string{
 	0x00: "Title",     // 00 - Full title
@@ -84,6 +87,25 @@ func (a ArchiveInfo) BlockData() tap.Block {
 	return nil
 }
 
+// Loader returns the protection scheme/loader text, or an empty string when
+// the block does not include one.
+func (a ArchiveInfo) Loader() string {
+	for _, t := range a.Strings {
+		if t.TypeID == LoaderTextID {
+			return string(t.Characters)
+		}
+	}
+	return ""
+}
+
+// AddText appends a new text string, updating the string count and block length.
+func (a *ArchiveInfo) AddText(id uint8, text string) {
+	t := Text{TypeID: id, Length: uint8(len(text)), Characters: []byte(text)}
+	a.Strings = append(a.Strings, t)
+	a.StringCount++
+	a.Length += 2 + uint16(t.Length)
+}
+
 // String returns a human readable string of the block data
 // Each character is first converted to a Rune so that Latin characters are preserved.
 func (a ArchiveInfo) String() string {
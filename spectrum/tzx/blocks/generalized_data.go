@@ -1,8 +1,8 @@
 package blocks
 
 import (
+	"encoding/binary"
 	"fmt"
-	"log"
 
 	"github.com/mrcook/retroio/spectrum/tap"
 	"github.com/mrcook/retroio/spectrum/tzx/blocks/types"
@@ -64,11 +64,134 @@ type PilotRLE struct {
 // Read the tape and extract the data.
 // It is expected that the tape pointer is at the correct position for reading.
 func (g *GeneralizedData) Read(reader *storage.Reader) error {
-	log.Fatal("GeneralizedData is not currently supported - unable to continue.")
+	g.BlockID = types.BlockType(reader.ReadByte())
+	if g.BlockID != g.Id() {
+		return fmt.Errorf("expected block ID 0x%02x, got 0x%02x", g.Id(), g.BlockID)
+	}
+
+	g.Length = reader.ReadLong()
+
+	data := make([]byte, g.Length)
+	if _, err := reader.Read(data); err != nil {
+		return err
+	}
+	if len(data) < 14 {
+		return fmt.Errorf("generalized data block too short: %d bytes", len(data))
+	}
+
+	g.Pause = binary.LittleEndian.Uint16(data[0:])
+	g.TOTP = binary.LittleEndian.Uint32(data[2:])
+	g.NPP = data[6]
+	g.ASP = data[7]
+	g.TOTD = binary.LittleEndian.Uint32(data[8:])
+	g.NPD = data[12]
+	g.ASD = data[13]
+
+	pos := 14
+	var err error
+
+	if g.TOTP > 0 {
+		g.PilotSymbols, pos, err = readSymbols(data, pos, alphabetSize(g.ASP), int(g.NPP))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < int(g.TOTP); i++ {
+			if pos+3 > len(data) {
+				return fmt.Errorf("generalized data pilot stream truncated")
+			}
+			g.PilotStreams = append(g.PilotStreams, PilotRLE{
+				Symbol:          data[pos],
+				RepetitionCount: binary.LittleEndian.Uint16(data[pos+1:]),
+			})
+			pos += 3
+		}
+	}
+
+	if g.TOTD > 0 {
+		g.DataSymbols, pos, err = readSymbols(data, pos, alphabetSize(g.ASD), int(g.NPD))
+		if err != nil {
+			return err
+		}
+		size := (g.SymbolBits()*int(g.TOTD) + 7) / 8
+		if pos+size > len(data) {
+			return fmt.Errorf("generalized data stream truncated")
+		}
+		g.DataStreams = data[pos : pos+size]
+	}
 
 	return nil
 }
 
+// readSymbols reads a symbol definition table from the block data, returning
+// the position after the table.
+func readSymbols(data []byte, pos, count, maxPulses int) ([]Symbol, int, error) {
+	var symbols []Symbol
+	for i := 0; i < count; i++ {
+		if pos+1+2*maxPulses > len(data) {
+			return nil, pos, fmt.Errorf("generalized data symbol table truncated")
+		}
+		s := Symbol{Flags: data[pos]}
+		pos++
+		for p := 0; p < maxPulses; p++ {
+			s.PulseLengths = append(s.PulseLengths, binary.LittleEndian.Uint16(data[pos:]))
+			pos += 2
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols, pos, nil
+}
+
+// alphabetSize returns the number of symbols in an alphabet, where 0 means 256.
+func alphabetSize(size uint8) int {
+	if size == 0 {
+		return 256
+	}
+	return int(size)
+}
+
+// SymbolBits returns the number of bits used for each symbol of the data
+// stream: NB = ceiling(Log2(ASD)).
+func (g GeneralizedData) SymbolBits() int {
+	bits := 0
+	for 1<<bits < alphabetSize(g.ASD) {
+		bits++
+	}
+	return bits
+}
+
+// Pulses returns the number of pulses in the symbol, a zero-length pulse
+// terminates a symbol shorter than the maximum.
+func (s Symbol) Pulses() int {
+	for i, p := range s.PulseLengths {
+		if p == 0 {
+			return i
+		}
+	}
+	return len(s.PulseLengths)
+}
+
+// DataBytes returns the data stream as bytes when it is encoded with a two
+// symbol alphabet, as used by the ZX81 tape encoding, where the symbol with
+// the fewest pulses represents a zero bit.
+func (g GeneralizedData) DataBytes() ([]byte, error) {
+	if g.TOTD == 0 {
+		return nil, nil
+	}
+	if len(g.DataSymbols) != 2 {
+		return nil, fmt.Errorf("unable to decode data stream with %d symbols", len(g.DataSymbols))
+	}
+
+	data := g.DataStreams[:g.TOTD/8]
+	if g.DataSymbols[0].Pulses() > g.DataSymbols[1].Pulses() {
+		inverted := make([]byte, len(data))
+		for i, b := range data {
+			inverted[i] = ^b
+		}
+		data = inverted
+	}
+	return data, nil
+}
+
 // Id of the block as given in the TZX specification, written as a hexadecimal number.
 func (g GeneralizedData) Id() types.BlockType {
 	return types.GeneralizedData
@@ -85,5 +208,5 @@ func (g GeneralizedData) BlockData() tap.Block {
 
 // String returns a human readable string of the block data
 func (g GeneralizedData) String() string {
-	return fmt.Sprintf("%s", g.Name())
+	return fmt.Sprintf("%-19s : %d data symbols, pause for %d ms.", g.Name(), g.TOTD, g.Pause)
 }
@@ -0,0 +1,150 @@
+// Package loader identifies the loading routines, and copy protection schemes,
+// used to record the data blocks of a TZX tape.
+//
+// Three sources of information are used to identify a loader:
+//
+//   - the pulse timings of the data blocks (pilot, sync, and zero/one bits),
+//   - the pattern of the pilot tones and pulse sequences before the data,
+//   - the loader routine found in the CODE block loaded before it.
+//
+// The timing signatures are typical values found in TZX conversions of the
+// original tapes. Tape mastering varied between releases, so a tolerance is
+// used when comparing the timings.
+//
+// Most custom loaders are a copy of the ROM LD-BYTES routine with new timing
+// constants. When such a routine is found in a CODE block, the bit length it
+// reads as a 1 is used to choose between the signatures matching the timings
+// of the blocks that follow, up to the next ROM header.
+package loader
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mrcook/retroio/spectrum/tap/headers"
+	"github.com/mrcook/retroio/spectrum/tzx/blocks"
+	"github.com/mrcook/retroio/spectrum/tzx/blocks/types"
+)
+
+// ROM is the name given to blocks recorded with the standard ROM timings.
+const ROM = "ROM"
+
+// Block is the part of the TZX block interface needed for detecting loaders.
+type Block interface {
+	Id() types.BlockType
+}
+
+// Detect returns the loader name for each of the tape blocks. Blocks which
+// do not contain any data (pure tones, pauses, text, etc.) are given an
+// empty name.
+func Detect(tape []Block) []string {
+	names := make([]string, len(tape))
+
+	var l leader
+	var code *loaderCode
+	codeHeader := false // the last ROM header was for a CODE block
+
+	for i, block := range tape {
+		var t timings
+		var data []byte
+
+		switch b := block.(type) {
+		case *blocks.PureTone:
+			l.addTone(b)
+			continue
+		case *blocks.SequenceOfPulses:
+			l.addPulses(b)
+			continue
+		case *blocks.StandardSpeedData:
+			t = romTimings
+			switch b.DataBlock.(type) {
+			case nil:
+			case *headers.ByteData:
+				code, codeHeader = nil, true
+			case *headers.ProgramData, *headers.NumericData, *headers.AlphanumericData:
+				code, codeHeader = nil, false
+			default:
+				if codeHeader {
+					data = b.DataBlock.BlockData()
+				}
+				codeHeader = false
+			}
+		case *blocks.TurboSpeedData:
+			t = timings{
+				pilot: b.PilotPulse,
+				sync1: b.SyncFirstPulse,
+				sync2: b.SyncSecondPulse,
+				zero:  b.ZeroBitPulse,
+				one:   b.OneBitPulse,
+			}
+		case *blocks.PureData:
+			t = l.timings()
+			t.zero = b.ZeroBitPulse
+			t.one = b.OneBitPulse
+		default:
+			continue
+		}
+
+		names[i] = identify(t, l, code)
+		l = leader{}
+
+		if c := scanCode(data); c != nil {
+			code = c
+		}
+	}
+
+	return names
+}
+
+// Summary returns the loaders used on the tape, in the order they were first
+// found. ROM is only reported when no other loader was detected, and a loader
+// family (e.g. Speedlock) is dropped when a specific version was detected.
+func Summary(names []string) string {
+	var found []string
+	seen := make(map[string]bool)
+	rom := false
+
+	for _, name := range names {
+		switch {
+		case name == "":
+			continue
+		case name == ROM:
+			rom = true
+		case !seen[name]:
+			seen[name] = true
+			found = append(found, name)
+		}
+	}
+
+	if len(found) == 0 && rom {
+		return ROM
+	}
+
+	var loaders []string
+	for _, name := range found {
+		if !hasVersion(name, found) {
+			loaders = append(loaders, name)
+		}
+	}
+	return strings.Join(loaders, ", ")
+}
+
+// identify the loader of a data block from its timings. When more than one
+// signature matches, the loader routine of the preceding CODE block is used
+// to choose between them.
+func identify(t timings, l leader, code *loaderCode) string {
+	if sig, ok := matchSignature(t, l, code); ok {
+		return sig.name
+	}
+	return fmt.Sprintf("Unknown (zero %d, one %d T-states)", t.zero, t.one)
+}
+
+// hasVersion reports whether a specific version of the loader family was found.
+func hasVersion(family string, names []string) bool {
+	for _, name := range names {
+		if name != family && strings.HasPrefix(name, family+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package loader
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/mrcook/retroio/spectrum/tzx/blocks"
+)
+
+// Allowed difference, as a fraction, between a block timing and a signature.
+const tolerance = 0.08
+
+// Pulses shorter than this, found between pilot tones, are treated as clicks.
+const clickLength = 1000
+
+// timings of the pulses used to record a data block, in T-states.
+type timings struct {
+	pilot uint16
+	sync1 uint16
+	sync2 uint16
+	zero  uint16
+	one   uint16
+}
+
+var romTimings = timings{pilot: 2168, sync1: 667, sync2: 735, zero: 855, one: 1710}
+
+// signature of a loader. A zero timing value matches any block timing.
+type signature struct {
+	name   string
+	timing timings
+	clicks bool // the pilot tone is broken up by short click pulses
+}
+
+// Timing signatures for the known loaders.
+var signatures = []signature{
+	{name: ROM, timing: romTimings},
+	{name: "Speedlock 1", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 611, one: 1222}, clicks: true},
+	{name: "Speedlock 2", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 583, one: 1166}, clicks: true},
+	{name: "Speedlock 3", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 583, one: 1166}},
+	{name: "Speedlock 4", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 611, one: 1222}},
+	{name: "Speedlock 5", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 641, one: 1282}},
+	{name: "Speedlock 6", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 558, one: 1116}},
+	{name: "Speedlock 7", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 530, one: 1060}},
+	{name: "Alkatraz", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 684, one: 1368}},
+	{name: "Bleepload", timing: timings{pilot: 1710, sync1: 667, sync2: 735, zero: 745, one: 1490}},
+	{name: "Microsphere", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 1050, one: 2100}},
+	{name: "Search Loader", timing: timings{pilot: 2168, sync1: 667, sync2: 735, zero: 490, one: 980}},
+}
+
+// loaderCode is the LD-BYTES style routine found in a CODE block.
+type loaderCode struct {
+	threshold int // length of a bit, in T-states, above which it is read as a 1
+}
+
+// reads reports whether the routine could read the bits of the signature.
+func (c loaderCode) reads(t timings) bool {
+	return t.zero != 0 && t.one != 0 && c.threshold > 2*int(t.zero) && c.threshold < 2*int(t.one)
+}
+
+// Parts of the ROM LD-BYTES routine, kept by the custom loaders copied from it
+var (
+	// IN A,(#FE) / RRA: reading the EAR bit in the edge loop
+	edgeLoopCode = []byte{0xdb, 0xfe, 0x1f}
+
+	// LD A,n / DEC A / JR NZ,-3: the delay before each edge is sampled
+	edgeDelayCode = []byte{0x3e, 0x00, 0x3d, 0x20, 0xfd}
+)
+
+const (
+	edgeLoopStates = 59 // T-states of each pass of the edge loop
+	delayStates    = 16 // T-states of each pass of the delay loop
+)
+
+// scanCode looks for an LD-BYTES style routine in the CODE block, returning
+// the bit length it uses to tell a 0 from a 1. Each bit is two edges, timed
+// by counting passes of the edge loop in B, and the bit loop compares the
+// count against a threshold:
+//
+//	LD A,threshold / CP B / RL r / LD B,start
+func scanCode(data []byte) *loaderCode {
+	if !bytes.Contains(data, edgeLoopCode) {
+		return nil
+	}
+
+	delay := 0
+	for i := 0; i+len(edgeDelayCode) <= len(data); i++ {
+		if data[i] == edgeDelayCode[0] && bytes.Equal(data[i+2:i+5], edgeDelayCode[2:]) {
+			delay = int(data[i+1])
+			break
+		}
+	}
+
+	for i := 0; i+7 <= len(data); i++ {
+		if data[i] != 0x3e || data[i+2] != 0xb8 || data[i+3] != 0xcb || data[i+4]&0xf8 != 0x10 || data[i+5] != 0x06 {
+			continue
+		}
+		threshold, start := int(data[i+1]), int(data[i+6])
+		if threshold <= start {
+			continue
+		}
+		return &loaderCode{threshold: 2*delay*delayStates + (threshold-start)*edgeLoopStates}
+	}
+	return nil
+}
+
+// matchSignature returns the closest signature for the block timings. When
+// the loader routine is known, a signature it can read is preferred over one
+// with closer timings.
+func matchSignature(t timings, l leader, code *loaderCode) (signature, bool) {
+	var best, bestCode signature
+	bestScore, bestCodeScore := math.MaxFloat64, math.MaxFloat64
+
+	for _, sig := range signatures {
+		if sig.clicks != l.clicks {
+			continue
+		}
+		score, ok := sig.timing.compare(t)
+		if !ok {
+			continue
+		}
+		if score < bestScore {
+			best, bestScore = sig, score
+		}
+		if code != nil && code.reads(sig.timing) && score < bestCodeScore {
+			bestCode, bestCodeScore = sig, score
+		}
+	}
+
+	if bestCodeScore != math.MaxFloat64 {
+		return bestCode, true
+	}
+	return best, bestScore != math.MaxFloat64
+}
+
+// compare the signature timings against the block timings, returning the
+// total difference, and whether every timing is within the tolerance.
+func (s timings) compare(t timings) (float64, bool) {
+	score := 0.0
+	pairs := [][2]uint16{{s.pilot, t.pilot}, {s.sync1, t.sync1}, {s.sync2, t.sync2}, {s.zero, t.zero}, {s.one, t.one}}
+
+	for _, p := range pairs {
+		if p[0] == 0 || p[1] == 0 {
+			continue
+		}
+		diff := math.Abs(float64(p[1])-float64(p[0])) / float64(p[0])
+		if diff > tolerance {
+			return 0, false
+		}
+		score += diff
+	}
+	return score, true
+}
+
+// leader holds the pilot tones and pulse sequences found before a data block.
+type leader struct {
+	tones  []*blocks.PureTone
+	pulses []*blocks.SequenceOfPulses
+	clicks bool
+
+	shortPulses bool // the last pulse sequence followed a tone, and was all short pulses
+}
+
+// addTone records the pilot tone. Short pulses between two pilot tones are
+// the "clicks" of the early Speedlock loaders.
+func (l *leader) addTone(t *blocks.PureTone) {
+	if l.shortPulses {
+		l.clicks = true
+	}
+	l.shortPulses = false
+	l.tones = append(l.tones, t)
+}
+
+func (l *leader) addPulses(s *blocks.SequenceOfPulses) {
+	l.pulses = append(l.pulses, s)
+
+	l.shortPulses = len(l.tones) > 0 && len(s.Lengths) > 0
+	for _, length := range s.Lengths {
+		if length >= clickLength {
+			l.shortPulses = false
+		}
+	}
+}
+
+// timings returns the pilot and sync timings for a pure data block: the
+// pilot is the first tone, and the sync is the last sequence of two pulses.
+func (l leader) timings() timings {
+	var t timings
+	if len(l.tones) > 0 {
+		t.pilot = l.tones[0].Length
+	}
+	for i := len(l.pulses) - 1; i >= 0; i-- {
+		if len(l.pulses[i].Lengths) == 2 {
+			t.sync1 = l.pulses[i].Lengths[0]
+			t.sync2 = l.pulses[i].Lengths[1]
+			break
+		}
+	}
+	return t
+}
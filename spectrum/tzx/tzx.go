@@ -60,7 +60,9 @@ import (
 
 	"github.com/mrcook/retroio/spectrum/basic"
 	"github.com/mrcook/retroio/spectrum/tap"
+	"github.com/mrcook/retroio/spectrum/tzx/blocks"
 	"github.com/mrcook/retroio/spectrum/tzx/blocks/types"
+	"github.com/mrcook/retroio/spectrum/tzx/loader"
 	"github.com/mrcook/retroio/storage"
 )
 
@@ -78,6 +80,9 @@ type TZX struct {
 	header
 	archive Block
 	blocks  []Block
+
+	detectLoaders bool
+	loaders       []string // loader name for each block, see the loader package
 }
 
 // Block is an interface for Tape data blocks
@@ -100,7 +105,13 @@ type header struct {
 }
 
 func New(reader *storage.Reader) *TZX {
-	return &TZX{reader: reader}
+	return &TZX{reader: reader, detectLoaders: true}
+}
+
+// DisableLoaderDetection turns off the identification of Spectrum loaders,
+// for tapes from other systems which use the TZX format.
+func (t *TZX) DisableLoaderDetection() {
+	t.detectLoaders = false
 }
 
 // Read processes the header, and then each block on the tape.
@@ -113,9 +124,32 @@ func (t *TZX) Read() error {
 		return err
 	}
 
+	if t.detectLoaders {
+		t.detectLoaderNames()
+	}
+
 	return nil
 }
 
+// detectLoaderNames identifies the loader of each data block. When the
+// archive info does not include the loader, it is added from the detected
+// loaders.
+func (t *TZX) detectLoaderNames() {
+	tape := make([]loader.Block, len(t.blocks))
+	for i, block := range t.blocks {
+		tape[i] = block
+	}
+	t.loaders = loader.Detect(tape)
+
+	archive, ok := t.archive.(*blocks.ArchiveInfo)
+	if !ok || archive.Loader() != "" {
+		return
+	}
+	if name := loader.Summary(t.loaders); name != "" {
+		archive.AddText(blocks.LoaderTextID, name+" (detected)")
+	}
+}
+
 // readHeader reads the tape header data and validates that the format is correct.
 func (t *TZX) readHeader() error {
 	t.header = header{}
@@ -171,11 +205,17 @@ func (t TZX) DisplayGeometry() {
 
 		fmt.Println("ARCHIVE INFORMATION (BLOCK #1):")
 		fmt.Println(t.archive)
+	} else if name := loader.Summary(t.loaders); name != "" {
+		fmt.Printf("LOADER: %s (detected)\n", name)
+		fmt.Println()
 	}
 
 	fmt.Println("DATA BLOCKS:")
 	for i, block := range t.blocks {
 		fmt.Printf("#%02d %s\n", i+blockCountOffset, block)
+		if i < len(t.loaders) && t.loaders[i] != "" {
+			fmt.Printf("    - Loader: %s\n", t.loaders[i])
+		}
 	}
 
 	fmt.Println()
@@ -190,6 +230,15 @@ func (t TZX) DisplayGeometry() {
 	fmt.Println()
 }
 
+// Blocks returns the tape blocks, not including the archive info.
+func (t TZX) Blocks() []Block {
+	return t.blocks
+}
+
+func (t TZX) CommandDir() {
+	fmt.Println("directory listing unsupported for tapes")
+}
+
 // DisplayBASIC outputs all BASIC programs
 func (t TZX) DisplayBASIC() {
 	isProgram := false
@@ -0,0 +1,133 @@
+package udi
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/spectrum/floppy"
+)
+
+// MFM address marks, each is preceded by three $A1 sync bytes which are
+// written with a missing clock bit.
+const (
+	syncByte           = 0xA1
+	idAddressMark      = 0xFE
+	dataAddressMark    = 0xFB
+	deletedAddressMark = 0xF8
+
+	// Maximum number of bytes between the end of an ID field and the start
+	// of its data field (GAP 2 plus the sync bytes).
+	maxDataFieldGap = 64
+)
+
+// decodeMFMTrack scans the raw track bytes for the ID and data fields of each
+// sector. The clock bitmap marks the bytes written with a missing clock bit,
+// which is how the $A1 sync bytes are told apart from normal $A1 data bytes.
+func decodeMFMTrack(data, clock []byte) ([]floppy.Sector, []string) {
+	var sectors []floppy.Sector
+	var warnings []string
+
+	isMark := func(i int) bool {
+		if i/8 >= len(clock) {
+			return false
+		}
+		return data[i] == syncByte && clock[i/8]&(1<<(i%8)) > 0
+	}
+
+	// nextMark returns the position of the address mark byte following a run
+	// of sync bytes, and the number of sync bytes found.
+	nextMark := func(from, limit int) (int, int) {
+		for i := from; i < len(data) && i < limit; i++ {
+			if !isMark(i) {
+				continue
+			}
+			syncs := 0
+			for i < len(data) && isMark(i) {
+				syncs++
+				i++
+			}
+			if i < len(data) {
+				return i, syncs
+			}
+		}
+		return -1, 0
+	}
+
+	for pos := 0; pos < len(data); {
+		mark, syncs := nextMark(pos, len(data))
+		if mark < 0 {
+			break
+		}
+		pos = mark + 1
+
+		if data[mark] != idAddressMark {
+			continue
+		}
+		if mark+7 > len(data) {
+			warnings = append(warnings, "ID field truncated at end of track")
+			break
+		}
+
+		id := data[mark : mark+7]
+		sector := floppy.Sector{Cylinder: id[1], Head: id[2], ID: id[3], Size: id[4]}
+		sector.IDCRCError = crc16(syncs, data[mark:mark+5]) != uint16(id[5])<<8|uint16(id[6])
+		pos = mark + 7
+
+		dataMark, syncs := nextMark(pos, pos+maxDataFieldGap)
+		if dataMark < 0 || (data[dataMark] != dataAddressMark && data[dataMark] != deletedAddressMark) {
+			sector.NoData = true
+			sectors = append(sectors, sector)
+			continue
+		}
+		sector.Deleted = data[dataMark] == deletedAddressMark
+
+		size := sector.SizeInBytes()
+		end := dataMark + 1 + size
+		if end+2 > len(data) {
+			warnings = append(warnings, fmt.Sprintf("sector %d data field truncated at end of track", sector.ID))
+			sector.Data = append([]byte{}, data[dataMark+1:min(end, len(data))]...)
+			sector.DataCRCError = true
+			sectors = append(sectors, sector)
+			break
+		}
+
+		sector.Data = append([]byte{}, data[dataMark+1:end]...)
+		sector.DataCRCError = crc16(syncs, data[dataMark:end]) != uint16(data[end])<<8|uint16(data[end+1])
+		sectors = append(sectors, sector)
+		pos = end + 2
+	}
+
+	return sectors, warnings
+}
+
+// crc16 calculates the CRC-CCITT used by the WD1793 floppy controller,
+// starting with $FFFF and including the $A1 sync bytes and address mark.
+func crc16(syncs int, data []byte) uint16 {
+	crc := uint16(0xFFFF)
+
+	update := func(b byte) {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 > 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	for i := 0; i < syncs; i++ {
+		update(syncByte)
+	}
+	for _, b := range data {
+		update(b)
+	}
+
+	return crc
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,174 @@
+// Package udi implements reading of UDI (Ultra Disk Image) files, a raw disk
+// image format used by Beta Disk (TR-DOS) emulators.
+//
+// Rather than storing just the sector data, a UDI stores the complete MFM
+// encoded data of each track, including the gaps, sync bytes, ID fields and
+// CRCs. This allows copy protected disks to be preserved, as any non-standard
+// sector layout, bad CRC or deleted data mark is kept.
+//
+// File layout:
+//
+//	header: "UDI!", file size, version, cylinders, sides, extended header length
+//	tracks: type, track length, track data, clock bitmap (for each cylinder and side)
+//	CRC32 of the whole file
+//
+// http://faqwiki.zxnet.co.uk/wiki/UDI_format
+package udi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/mrcook/retroio/spectrum/floppy"
+	"github.com/mrcook/retroio/storage"
+)
+
+const headerSize = 16
+
+// Track types
+const (
+	trackMFM   = 0x00
+	trackFM    = 0x01
+	trackMixed = 0x02
+)
+
+// UDI disk image
+type UDI struct {
+	reader *storage.Reader
+
+	Header Header
+	Disk   floppy.Disk
+
+	crcValid bool
+}
+
+// Header of the UDI file.
+type Header struct {
+	Signature       [4]byte // "UDI!", a lowercase "udi!" signature is used for compressed images
+	FileSize        uint32  // file size, not including the CRC32
+	Version         uint8   // currently $00
+	MaxCylinder     uint8   // number of cylinders - 1
+	MaxSide         uint8   // number of sides - 1
+	Unused          uint8
+	ExtHeaderLength uint32 // length of the extended header
+}
+
+func New(reader *storage.Reader) *UDI {
+	return &UDI{reader: reader}
+}
+
+// Read the UDI header and decode every track.
+func (u *UDI) Read() error {
+	data, err := u.reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(data) < headerSize {
+		return fmt.Errorf("file too small for a UDI image")
+	}
+
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &u.Header); err != nil {
+		return err
+	}
+	if string(u.Header.Signature[:]) == "udi!" {
+		return fmt.Errorf("compressed UDI images are not supported")
+	}
+	if string(u.Header.Signature[:]) != "UDI!" {
+		return fmt.Errorf("invalid UDI signature: %q", u.Header.Signature)
+	}
+	if int(u.Header.FileSize) > len(data) {
+		return fmt.Errorf("UDI image truncated, expected %d bytes, got %d", u.Header.FileSize, len(data))
+	}
+
+	if int(u.Header.FileSize)+4 <= len(data) {
+		stored := binary.LittleEndian.Uint32(data[u.Header.FileSize:])
+		calculated := crc32.ChecksumIEEE(data[:u.Header.FileSize])
+		// some tools do not apply the final inversion to the CRC
+		u.crcValid = stored == calculated || stored == ^calculated
+	}
+
+	u.Disk = floppy.Disk{
+		Cylinders: u.Header.MaxCylinder + 1,
+		Sides:     u.Header.MaxSide + 1,
+	}
+
+	pos := headerSize + int(u.Header.ExtHeaderLength)
+	end := int(u.Header.FileSize)
+
+	for c := 0; c < int(u.Disk.Cylinders); c++ {
+		for h := 0; h < int(u.Disk.Sides); h++ {
+			track := floppy.Track{Cylinder: uint8(c), Side: uint8(h)}
+
+			if pos+3 > end {
+				return fmt.Errorf("unexpected end of data reading cylinder %d, side %d", c, h)
+			}
+			trackType := data[pos]
+			length := int(binary.LittleEndian.Uint16(data[pos+1:]))
+			pos += 3
+
+			clockLength := (length + 7) / 8
+			if pos+length+clockLength > end {
+				return fmt.Errorf("unexpected end of data reading cylinder %d, side %d", c, h)
+			}
+			trackData := data[pos : pos+length]
+			clock := data[pos+length : pos+length+clockLength]
+			pos += length + clockLength
+
+			switch trackType {
+			case trackMFM, trackMixed:
+				track.Sectors, track.Warnings = decodeMFMTrack(trackData, clock)
+				if trackType == trackMixed {
+					track.Warnings = append(track.Warnings, "mixed FM/MFM track, only MFM sectors decoded")
+				}
+			case trackFM:
+				track.Warnings = append(track.Warnings, "FM encoded tracks are not supported")
+			default:
+				return fmt.Errorf("unsupported track type $%02X at cylinder %d, side %d", trackType, c, h)
+			}
+
+			u.Disk.Tracks = append(u.Disk.Tracks, track)
+		}
+	}
+
+	return nil
+}
+
+// DisplayGeometry prints the disk and track metadata to the terminal.
+func (u UDI) DisplayGeometry() {
+	fmt.Println("DISK INFORMATION:")
+	fmt.Printf("Type:      UDI v%d\n", u.Header.Version)
+	fmt.Printf("Cylinders: %d\n", u.Disk.Cylinders)
+	fmt.Printf("Sides:     %d\n", u.Disk.Sides)
+	if !u.crcValid {
+		fmt.Println("WARNING:   file CRC32 mismatch")
+	}
+	fmt.Println()
+
+	for _, track := range u.Disk.Tracks {
+		fmt.Println(track)
+	}
+}
+
+// CommandDir displays the TR-DOS catalogue, read from the logical sectors of
+// the system track.
+func (u UDI) CommandDir() {
+	catalogue, err := u.Disk.Catalogue()
+	if err != nil {
+		fmt.Printf("Unable to read the TR-DOS catalogue: %s\n", err)
+		return
+	}
+
+	if !u.Disk.IsStandardTRD() {
+		fmt.Println("NOTE: the disk does not have a standard TR-DOS layout")
+		fmt.Println()
+	}
+
+	catalogue.CommandDir()
+}
+
+// DisplayBASIC outputs all BASIC programs on the disk
+func (u UDI) DisplayBASIC() {
+	fmt.Println("Not implemented for Spectrum disk images")
+}
@@ -80,6 +80,11 @@ func (r Reader) ReadLong() uint32 {
 	return binary.LittleEndian.Uint32(b[:])
 }
 
+// ReadAll reads all remaining bytes from the reader.
+func (r Reader) ReadAll() ([]byte, error) {
+	return io.ReadAll(r.reader)
+}
+
 // Buffered delegates to the underlying Reader function, returning the number of bytes left in the buffer.
 func (r Reader) Buffered() int {
 	return r.reader.Buffered()
@@ -0,0 +1,246 @@
+package basic
+
+// ZX81 character set.
+//
+// The ZX81 does not use ASCII, characters $00-$3F are the printable set, and
+// $80-$BF are the same characters in inverse video. The block graphics use
+// the Unicode block elements, with the chequered half blocks taken from
+// Symbols for Legacy Computing.
+var CharacterSet = map[byte]string{
+	0x00: " ",
+	0x01: "▘",
+	0x02: "▝",
+	0x03: "▀",
+	0x04: "▖",
+	0x05: "▌",
+	0x06: "▞",
+	0x07: "▛",
+	0x08: "▒",
+	0x09: "🮏",
+	0x0A: "🮎",
+	0x0B: "\"",
+	0x0C: "£",
+	0x0D: "$",
+	0x0E: ":",
+	0x0F: "?",
+	0x10: "(",
+	0x11: ")",
+	0x12: ">",
+	0x13: "<",
+	0x14: "=",
+	0x15: "+",
+	0x16: "-",
+	0x17: "*",
+	0x18: "/",
+	0x19: ";",
+	0x1A: ",",
+	0x1B: ".",
+	0x1C: "0",
+	0x1D: "1",
+	0x1E: "2",
+	0x1F: "3",
+	0x20: "4",
+	0x21: "5",
+	0x22: "6",
+	0x23: "7",
+	0x24: "8",
+	0x25: "9",
+	0x26: "A",
+	0x27: "B",
+	0x28: "C",
+	0x29: "D",
+	0x2A: "E",
+	0x2B: "F",
+	0x2C: "G",
+	0x2D: "H",
+	0x2E: "I",
+	0x2F: "J",
+	0x30: "K",
+	0x31: "L",
+	0x32: "M",
+	0x33: "N",
+	0x34: "O",
+	0x35: "P",
+	0x36: "Q",
+	0x37: "R",
+	0x38: "S",
+	0x39: "T",
+	0x3A: "U",
+	0x3B: "V",
+	0x3C: "W",
+	0x3D: "X",
+	0x3E: "Y",
+	0x3F: "Z",
+	// BASIC tokens - functions without arguments
+	0x40: "RND",
+	0x41: "INKEY$",
+	0x42: "PI",
+	// Inverse block graphics
+	0x80: "█",
+	0x81: "▟",
+	0x82: "▙",
+	0x83: "▄",
+	0x84: "▜",
+	0x85: "▐",
+	0x86: "▚",
+	0x87: "▗",
+	0x88: "🮐",
+	0x89: "🮑",
+	0x8A: "🮒",
+	// BASIC tokens - expression
+	0xC0: "\"\"",
+	0xC1: "AT",
+	0xC2: "TAB",
+	0xC3: "?",
+	0xC4: "CODE",
+	0xC5: "VAL",
+	0xC6: "LEN",
+	0xC7: "SIN",
+	0xC8: "COS",
+	0xC9: "TAN",
+	0xCA: "ASN",
+	0xCB: "ACS",
+	0xCC: "ATN",
+	0xCD: "LN",
+	0xCE: "EXP",
+	0xCF: "INT",
+	0xD0: "SQR",
+	0xD1: "SGN",
+	0xD2: "ABS",
+	0xD3: "PEEK",
+	0xD4: "USR",
+	0xD5: "STR$",
+	0xD6: "CHR$",
+	0xD7: "NOT",
+	0xD8: "**",
+	0xD9: "OR",
+	0xDA: "AND",
+	0xDB: "<=",
+	0xDC: ">=",
+	0xDD: "<>",
+	0xDE: "THEN",
+	0xDF: "TO",
+	0xE0: "STEP",
+	// BASIC tokens - keywords
+	0xE1: "LPRINT",
+	0xE2: "LLIST",
+	0xE3: "STOP",
+	0xE4: "SLOW",
+	0xE5: "FAST",
+	0xE6: "NEW",
+	0xE7: "SCROLL",
+	0xE8: "CONT",
+	0xE9: "DIM",
+	0xEA: "REM",
+	0xEB: "FOR",
+	0xEC: "GOTO",
+	0xED: "GOSUB",
+	0xEE: "INPUT",
+	0xEF: "LOAD",
+	0xF0: "LIST",
+	0xF1: "LET",
+	0xF2: "PAUSE",
+	0xF3: "NEXT",
+	0xF4: "POKE",
+	0xF5: "PRINT",
+	0xF6: "PLOT",
+	0xF7: "RUN",
+	0xF8: "SAVE",
+	0xF9: "RAND",
+	0xFA: "IF",
+	0xFB: "CLS",
+	0xFC: "UNPLOT",
+	0xFD: "CLEAR",
+	0xFE: "RETURN",
+	0xFF: "COPY",
+}
+
+// ZX80 character set.
+//
+// The printable characters follow the same layout as the ZX81, except for the
+// position of the double quote, block graphics and the operators. Keywords
+// are tokenised from $D4, while functions are stored letter by letter.
+var ZX80CharacterSet = map[byte]string{
+	0x00: " ",
+	0x01: "\"",
+	0x02: "▌",
+	0x03: "▄",
+	0x04: "▘",
+	0x05: "▝",
+	0x06: "▖",
+	0x07: "▗",
+	0x08: "▞",
+	0x09: "▒",
+	0x0A: "🮎",
+	0x0B: "🮏",
+	0x0C: "£",
+	0x0D: "$",
+	0x0E: ":",
+	0x0F: "?",
+	0x10: "(",
+	0x11: ")",
+	0x12: "-",
+	0x13: "+",
+	0x14: "*",
+	0x15: "/",
+	0x16: "=",
+	0x17: ">",
+	0x18: "<",
+	0x19: ";",
+	0x1A: ",",
+	0x1B: ".",
+	// $1C-$3F: digits and letters, as the ZX81
+	0x80: "█", // inverse space
+	// BASIC tokens
+	0xD4: "\"",
+	0xD5: "THEN",
+	0xD6: "TO",
+	0xD7: ";",
+	0xD8: ",",
+	0xD9: ")",
+	0xDA: "(",
+	0xDB: "NOT",
+	0xDC: "-",
+	0xDD: "+",
+	0xDE: "*",
+	0xDF: "/",
+	0xE0: "AND",
+	0xE1: "OR",
+	0xE2: "**",
+	0xE3: "=",
+	0xE4: ">",
+	0xE5: "<",
+	0xE6: "LIST",
+	0xE7: "RETURN",
+	0xE8: "CLS",
+	0xE9: "DIM",
+	0xEA: "SAVE",
+	0xEB: "FOR",
+	0xEC: "GO TO",
+	0xED: "POKE",
+	0xEE: "INPUT",
+	0xEF: "RANDOMISE",
+	0xF0: "LET",
+	0xF1: "?",
+	0xF2: "?",
+	0xF3: "NEXT",
+	0xF4: "PRINT",
+	0xF5: "?",
+	0xF6: "NEW",
+	0xF7: "RUN",
+	0xF8: "STOP",
+	0xF9: "CONTINUE",
+	0xFA: "IF",
+	0xFB: "GO SUB",
+	0xFC: "LOAD",
+	0xFD: "CLEAR",
+	0xFE: "REM",
+	0xFF: "?",
+}
+
+func init() {
+	// digits and letters are shared by both machines
+	for c := byte(0x1C); c <= 0x3F; c++ {
+		ZX80CharacterSet[c] = CharacterSet[c]
+	}
+}
@@ -0,0 +1,150 @@
+// Package basic is a simple decoder for ZX81 and ZX80 BASIC programs.
+//
+// The ZX81 stores each line as a 2 byte line number (high byte first), a 2
+// byte line length (low byte first), the tokenised text, and a NEWLINE ($76)
+// character. Numbers are followed by a $7E marker and their 5 byte floating
+// point value, which is not listed.
+//
+// The ZX80 has no line length, only the 2 byte line number followed by the
+// text and a NEWLINE. It only supports integers, which are stored as text.
+package basic
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	newline      = 0x76
+	numberMarker = 0x7E
+)
+
+// Decode a ZX81 BASIC program, as stored from address $407D up to the start
+// of the display file.
+func Decode(program []byte) ([]string, error) {
+	var basic []string
+
+	for pos := 0; pos < len(program); {
+		if pos+4 > len(program) {
+			return basic, fmt.Errorf("unexpected end of program at line header")
+		}
+		lineNum := uint16(program[pos])<<8 | uint16(program[pos+1])
+		lineLen := int(program[pos+2]) | int(program[pos+3])<<8
+		pos += 4
+
+		if pos+lineLen > len(program) {
+			return basic, fmt.Errorf("line %d: length of %d bytes exceeds the program data", lineNum, lineLen)
+		}
+
+		line := decodeLine(program[pos:pos+lineLen], CharacterSet, 0xC0)
+		basic = append(basic, fmt.Sprintf("%4d %s\n", lineNum, line))
+		pos += lineLen
+	}
+
+	return basic, nil
+}
+
+// DecodeZX80 decodes a ZX80 BASIC program, as stored from address $4028 up
+// to the start of the variables area.
+func DecodeZX80(program []byte) ([]string, error) {
+	var basic []string
+
+	for pos := 0; pos < len(program); {
+		if pos+2 > len(program) {
+			return basic, fmt.Errorf("unexpected end of program at line number")
+		}
+		lineNum := uint16(program[pos])<<8 | uint16(program[pos+1])
+		pos += 2
+
+		end := pos
+		for end < len(program) && program[end] != newline {
+			end++
+		}
+		if end == len(program) {
+			return basic, fmt.Errorf("line %d: missing NEWLINE", lineNum)
+		}
+
+		line := decodeLine(program[pos:end], ZX80CharacterSet, 0xD4)
+		basic = append(basic, fmt.Sprintf("%4d %s\n", lineNum, line))
+		pos = end + 1
+	}
+
+	return basic, nil
+}
+
+// Text converts ZX81 characters to a string. Inverse video characters are
+// shown as their normal video character, unless they are a block graphic.
+func Text(data []byte) string {
+	return text(data, CharacterSet)
+}
+
+// ZX80Text converts ZX80 characters to a string.
+func ZX80Text(data []byte) string {
+	return text(data, ZX80CharacterSet)
+}
+
+func text(data []byte, charset map[byte]string) string {
+	str := ""
+	for _, c := range data {
+		str += character(c, charset, false)
+	}
+	return str
+}
+
+// decodeLine decodes the text of a single line. The tokens start at the
+// firstToken code, and everything below it is a character.
+func decodeLine(data []byte, charset map[byte]string, firstToken byte) string {
+	str := ""
+
+	for pos := 0; pos < len(data); pos++ {
+		c := data[pos]
+
+		switch {
+		case c == newline:
+			return strings.TrimRight(str, " ")
+		case c == numberMarker && firstToken == 0xC0:
+			pos += 5 // skip the hidden floating point value
+		case c >= firstToken:
+			str += tokenWithPadding(charset[c], str)
+		default:
+			str += character(c, charset, true)
+		}
+	}
+
+	return strings.TrimRight(str, " ")
+}
+
+// character returns the string for a character code. Inverse video letters
+// and symbols are prefixed with a `%` when marked, which is the convention
+// used by most ZX81 text-to-program tools.
+func character(c byte, charset map[byte]string, markInverse bool) string {
+	if s, ok := charset[c]; ok {
+		return s
+	}
+	if c >= 0x80 && c < 0xC0 {
+		if s, ok := charset[c&0x3F]; ok {
+			if markInverse {
+				return "%" + s
+			}
+			return s
+		}
+	}
+	return "?"
+}
+
+// tokenWithPadding adds spaces around keywords, but not around the symbol
+// tokens, such as `<=` or `**`.
+func tokenWithPadding(token, previous string) string {
+	if token == "" || !isWord(token) {
+		return token
+	}
+	if previous != "" && !strings.HasSuffix(previous, " ") {
+		token = " " + token
+	}
+	return token + " "
+}
+
+func isWord(token string) bool {
+	c := token[0]
+	return c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,81 @@
+package zx81
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mrcook/retroio/zx81/basic"
+)
+
+const (
+	ScreenRows    = 24
+	ScreenColumns = 32
+)
+
+// Screen is the decoded ZX81 display file.
+//
+// The display file starts with a NEWLINE ($76) character, followed by the 24
+// rows of the screen, each terminated by a NEWLINE. On machines with less
+// than 3.25K of RAM the display file is collapsed, where each row only holds
+// the characters up to the last one printed.
+type Screen struct {
+	Rows      [ScreenRows][]byte
+	Collapsed bool
+}
+
+// Screen decodes the display file, which is only saved by the ZX81.
+func (p Program) Screen() (*Screen, error) {
+	if p.Machine != ZX81 {
+		return nil, fmt.Errorf("the %s does not save the display file", p.Machine)
+	}
+
+	dFile, err := p.SystemVariable("D_FILE")
+	if err != nil {
+		return nil, err
+	}
+	vars, err := p.SystemVariable("VARS")
+	if err != nil {
+		return nil, err
+	}
+	data, err := p.area(dFile, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 || data[0] != 0x76 {
+		return nil, fmt.Errorf("display file does not start with a NEWLINE")
+	}
+
+	screen := &Screen{}
+	pos := 1
+	for row := 0; row < ScreenRows; row++ {
+		start := pos
+		for pos < len(data) && data[pos] != 0x76 {
+			pos++
+		}
+		if pos == len(data) {
+			return nil, fmt.Errorf("display file row %d is missing its NEWLINE", row)
+		}
+		if pos-start > ScreenColumns {
+			return nil, fmt.Errorf("display file row %d is %d characters wide", row, pos-start)
+		}
+		if pos-start < ScreenColumns {
+			screen.Collapsed = true
+		}
+		screen.Rows[row] = data[start:pos]
+		pos++
+	}
+
+	return screen, nil
+}
+
+// String returns the screen as text, with each row padded to the full width.
+func (s Screen) String() string {
+	var rows []string
+	for _, row := range s.Rows {
+		text := basic.Text(row)
+		text += strings.Repeat(" ", ScreenColumns-len(row))
+		rows = append(rows, text)
+	}
+	return strings.Join(rows, "\n") + "\n"
+}
@@ -0,0 +1,18 @@
+// Package zx81 provides the common program handling for the Sinclair ZX81
+// and ZX80 tape images.
+//
+// Both machines save a program by writing their memory to tape, starting with
+// the system variables, followed by the BASIC program, the display file (ZX81
+// only), and the variables. The ZX81 saves from address $4009 up to E_LINE,
+// and precedes the data with the program name. The ZX80 saves from address
+// $4000 up to E_LINE, without a name.
+//
+// http://problemkaputt.de/zxdocs.htm
+package zx81
+
+type Image interface {
+	Read() error
+	DisplayGeometry()
+	DisplayBASIC()
+	Programs() []Program
+}
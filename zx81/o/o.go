@@ -0,0 +1,64 @@
+// Package o implements reading of ZX80 program files.
+//
+// A .O (or .80) file holds the data exactly as saved to tape by the ZX80: the
+// memory from ERR_NR ($4000) up to E_LINE. The ZX80 does not save a program
+// name, or the display file.
+package o
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/storage"
+	"github.com/mrcook/retroio/zx81"
+)
+
+// O program file
+type O struct {
+	reader *storage.Reader
+
+	Program zx81.Program
+}
+
+func New(reader *storage.Reader) *O {
+	return &O{reader: reader}
+}
+
+// Read the program data, and check it is at least as long as the
+// system variables.
+func (o *O) Read() error {
+	data, err := o.reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	o.Program = zx81.Program{Machine: zx81.ZX80, Data: data}
+
+	if _, err := o.Program.SystemVariable("E_LINE"); err != nil {
+		return fmt.Errorf("file too small for a ZX80 program: %d bytes", len(data))
+	}
+
+	return nil
+}
+
+// DisplayGeometry prints the program details.
+func (o O) DisplayGeometry() {
+	fmt.Println("PROGRAM INFORMATION:")
+	fmt.Print(o.Program)
+
+	// E_LINE is the first address not saved
+	if eLine, err := o.Program.SystemVariable("E_LINE"); err == nil {
+		if expected := eLine - 0x4000; expected != len(o.Program.Data) {
+			fmt.Printf("WARNING:      expected %d bytes from E_LINE\n", expected)
+		}
+	}
+}
+
+// DisplayBASIC outputs the BASIC program.
+func (o O) DisplayBASIC() {
+	zx81.DisplayBASIC(o.Programs())
+}
+
+// Programs returns the single program in the file.
+func (o O) Programs() []zx81.Program {
+	return []zx81.Program{o.Program}
+}
@@ -0,0 +1,80 @@
+// Package p implements reading of ZX81 program files, as used by most ZX81
+// emulators.
+//
+// A .P (or .81) file holds the data exactly as saved to tape by the ZX81, but
+// without the program name: the memory from VERSN ($4009) up to E_LINE.
+// A .P81 file is the same, but with the program name stored before the data,
+// and the last character of the name has bit 7 set.
+package p
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/storage"
+	"github.com/mrcook/retroio/zx81"
+)
+
+// P program file
+type P struct {
+	reader   *storage.Reader
+	withName bool
+
+	Program zx81.Program
+}
+
+// New returns a reader for .P and .81 files.
+func New(reader *storage.Reader) *P {
+	return &P{reader: reader}
+}
+
+// NewP81 returns a reader for .P81 files, which include the program name.
+func NewP81(reader *storage.Reader) *P {
+	return &P{reader: reader, withName: true}
+}
+
+// Read the program data, and check it is at least as long as the
+// system variables.
+func (p *P) Read() error {
+	data, err := p.reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	if p.withName {
+		p.Program, err = zx81.NewProgram(data)
+		if err != nil {
+			return err
+		}
+	} else {
+		p.Program = zx81.Program{Machine: zx81.ZX81, Data: data}
+	}
+
+	if _, err := p.Program.SystemVariable("E_LINE"); err != nil {
+		return fmt.Errorf("file too small for a ZX81 program: %d bytes", len(p.Program.Data))
+	}
+
+	return nil
+}
+
+// DisplayGeometry prints the program details.
+func (p P) DisplayGeometry() {
+	fmt.Println("PROGRAM INFORMATION:")
+	fmt.Print(p.Program)
+
+	// E_LINE is the first address not saved
+	if eLine, err := p.Program.SystemVariable("E_LINE"); err == nil {
+		if expected := eLine - 0x4009; expected != len(p.Program.Data) {
+			fmt.Printf("WARNING:      expected %d bytes from E_LINE\n", expected)
+		}
+	}
+}
+
+// DisplayBASIC outputs the BASIC program.
+func (p P) DisplayBASIC() {
+	zx81.DisplayBASIC(p.Programs())
+}
+
+// Programs returns the single program in the file.
+func (p P) Programs() []zx81.Program {
+	return []zx81.Program{p.Program}
+}
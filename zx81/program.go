@@ -0,0 +1,198 @@
+package zx81
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/zx81/basic"
+)
+
+// Machine the program was saved on.
+type Machine uint8
+
+const (
+	ZX81 Machine = iota
+	ZX80
+)
+
+func (m Machine) String() string {
+	if m == ZX80 {
+		return "ZX80"
+	}
+	return "ZX81"
+}
+
+// Memory addresses of the system variables and program areas.
+const (
+	zx81Origin  = 0x4009 // VERSN, the first byte saved
+	zx81DFile   = 0x400C
+	zx81Vars    = 0x4010
+	zx81ELine   = 0x4014
+	zx81Program = 0x407D
+
+	zx80Origin  = 0x4000 // ERR_NR, the first byte saved
+	zx80Vars    = 0x4008
+	zx80ELine   = 0x400A
+	zx80Program = 0x4028
+)
+
+// Program is a single saved program: the memory from the first system
+// variable up to the end of the variables area.
+type Program struct {
+	Name    string // empty for programs without a name (.P files and the ZX80)
+	Machine Machine
+	Data    []byte
+}
+
+// NewProgram reads a program, as saved to tape by the ZX81, where the data
+// starts with the program name. The last character of the name has bit 7 set.
+func NewProgram(data []byte) (Program, error) {
+	for i, c := range data {
+		if c&0x80 > 0 {
+			name := make([]byte, i+1)
+			copy(name, data[:i+1])
+			name[i] &= 0x7F
+			return Program{Name: basic.Text(name), Machine: ZX81, Data: data[i+1:]}, nil
+		}
+	}
+	return Program{}, fmt.Errorf("program name not found")
+}
+
+func (p Program) origin() int {
+	if p.Machine == ZX80 {
+		return zx80Origin
+	}
+	return zx81Origin
+}
+
+// word returns the 16-bit value stored at the memory address.
+func (p Program) word(address int) (int, error) {
+	offset := address - p.origin()
+	if offset < 0 || offset+2 > len(p.Data) {
+		return 0, fmt.Errorf("address $%04X is outside the program data", address)
+	}
+	return int(p.Data[offset]) | int(p.Data[offset+1])<<8, nil
+}
+
+// area returns the memory between the two addresses, checking the addresses
+// are within the program data.
+func (p Program) area(start, end int) ([]byte, error) {
+	from := start - p.origin()
+	to := end - p.origin()
+	if from < 0 || to < from || to > len(p.Data) {
+		return nil, fmt.Errorf("memory area $%04X-$%04X is outside the program data", start, end)
+	}
+	return p.Data[from:to], nil
+}
+
+// SystemVariable returns the address stored in the D_FILE, VARS or E_LINE
+// system variables.
+func (p Program) SystemVariable(name string) (int, error) {
+	addresses := map[string]int{"D_FILE": zx81DFile, "VARS": zx81Vars, "E_LINE": zx81ELine}
+	if p.Machine == ZX80 {
+		addresses = map[string]int{"VARS": zx80Vars, "E_LINE": zx80ELine}
+	}
+	address, ok := addresses[name]
+	if !ok {
+		return 0, fmt.Errorf("system variable %s not available on the %s", name, p.Machine)
+	}
+	return p.word(address)
+}
+
+// BASIC returns the tokenised BASIC program area.
+func (p Program) BASIC() ([]byte, error) {
+	if p.Machine == ZX80 {
+		vars, err := p.SystemVariable("VARS")
+		if err != nil {
+			return nil, err
+		}
+		return p.area(zx80Program, vars)
+	}
+
+	dFile, err := p.SystemVariable("D_FILE")
+	if err != nil {
+		return nil, err
+	}
+	return p.area(zx81Program, dFile)
+}
+
+// Listing decodes the BASIC program.
+func (p Program) Listing() ([]string, error) {
+	program, err := p.BASIC()
+	if err != nil {
+		return nil, err
+	}
+	if p.Machine == ZX80 {
+		return basic.DecodeZX80(program)
+	}
+	return basic.Decode(program)
+}
+
+// String returns the program details, and the memory layout.
+func (p Program) String() string {
+	str := fmt.Sprintf("Machine:      %s\n", p.Machine)
+	if p.Name != "" {
+		str += fmt.Sprintf("Name:         %s\n", p.Name)
+	}
+	str += fmt.Sprintf("Length:       %d bytes\n", len(p.Data))
+
+	var names []string
+	if p.Machine == ZX80 {
+		names = []string{"VARS", "E_LINE"}
+	} else {
+		names = []string{"D_FILE", "VARS", "E_LINE"}
+	}
+	for _, name := range names {
+		if address, err := p.SystemVariable(name); err == nil {
+			str += fmt.Sprintf("%-13s $%04X\n", name+":", address)
+		}
+	}
+
+	if lines, err := p.Listing(); err == nil {
+		str += fmt.Sprintf("BASIC lines:  %d\n", len(lines))
+	} else {
+		str += fmt.Sprintf("BASIC lines:  %s\n", err)
+	}
+
+	if p.Machine == ZX81 {
+		if screen, err := p.Screen(); err == nil {
+			layout := "full"
+			if screen.Collapsed {
+				layout = "collapsed"
+			}
+			str += fmt.Sprintf("Display file: %s\n", layout)
+		} else {
+			str += fmt.Sprintf("Display file: %s\n", err)
+		}
+	}
+
+	return str
+}
+
+// DisplayBASIC prints the BASIC listing of each program.
+func DisplayBASIC(programs []Program) {
+	listing := ""
+	for i, p := range programs {
+		name := p.Name
+		if name == "" {
+			name = "(no name)"
+		}
+		listing += fmt.Sprintf("PROG#%02d: %s\n", i+1, name)
+
+		lines, err := p.Listing()
+		for _, line := range lines {
+			listing += line
+		}
+		if err != nil {
+			listing += fmt.Sprintf("    %s\n", err)
+		}
+		listing += "\n"
+	}
+
+	if len(programs) > 0 {
+		fmt.Println("BASIC PROGRAMS:")
+		fmt.Println()
+		fmt.Println(listing)
+	} else {
+		fmt.Println("Unable to decode BASIC program")
+	}
+}
@@ -0,0 +1,140 @@
+// Package tzx implements reading of ZX81 and ZX80 programs from TZX tapes.
+//
+// These tapes are identified by a Hardware Type block listing the ZX81 (or
+// ZX80) computer. The ZX81 encoding has no pilot tone and uses a different
+// number of pulses for each bit, so the programs are stored in Generalized
+// Data blocks, where each ZX81 program starts with its name.
+//
+// The TZX format itself is read with the `spectrum/tzx` package.
+package tzx
+
+import (
+	"fmt"
+
+	"github.com/mrcook/retroio/spectrum/tzx"
+	"github.com/mrcook/retroio/spectrum/tzx/blocks"
+	"github.com/mrcook/retroio/storage"
+	"github.com/mrcook/retroio/zx81"
+)
+
+// Hardware Type IDs of the computers which use the ZX81/ZX80 tape format.
+const (
+	hardwareComputers = 0x00
+	hardwareZX80      = 0x0c
+	hardwareZX81      = 0x0d
+	hardwareTS1500    = 0x2a
+	hardwareLambda    = 0x2b
+
+	hardwareDoesNotRun = 0x03
+)
+
+// TZX tape containing ZX81 or ZX80 programs
+type TZX struct {
+	*tzx.TZX
+
+	Machine  zx81.Machine
+	programs []zx81.Program
+	warnings []string
+}
+
+func New(reader *storage.Reader) *TZX {
+	t := tzx.New(reader)
+	t.DisableLoaderDetection()
+	return &TZX{TZX: t}
+}
+
+// Read the tape blocks, and the programs from each Generalized Data block.
+func (t *TZX) Read() error {
+	if err := t.TZX.Read(); err != nil {
+		return err
+	}
+
+	if err := t.readMachine(); err != nil {
+		return err
+	}
+
+	count := 0
+	for _, block := range t.Blocks() {
+		data, ok := block.(*blocks.GeneralizedData)
+		if !ok {
+			continue
+		}
+		count++
+
+		bytes, err := data.DataBytes()
+		if err != nil {
+			t.warnings = append(t.warnings, fmt.Sprintf("generalized data block %d: %s", count, err))
+			continue
+		}
+
+		program := zx81.Program{Machine: t.Machine, Data: bytes}
+		if t.Machine == zx81.ZX81 {
+			program, err = zx81.NewProgram(bytes)
+			if err != nil {
+				t.warnings = append(t.warnings, fmt.Sprintf("generalized data block %d: %s", count, err))
+				continue
+			}
+		}
+		t.programs = append(t.programs, program)
+	}
+
+	return nil
+}
+
+// readMachine finds the machine from the Hardware Type blocks. Tapes without
+// the block are assumed to be for the ZX81.
+func (t *TZX) readMachine() error {
+	t.Machine = zx81.ZX81
+	flagged := false
+
+	for _, block := range t.Blocks() {
+		hardware, ok := block.(*blocks.HardwareType)
+		if !ok {
+			continue
+		}
+		for _, m := range hardware.Machines {
+			if m.Type != hardwareComputers || m.Information == hardwareDoesNotRun {
+				continue
+			}
+			switch m.Id {
+			case hardwareZX81, hardwareTS1500, hardwareLambda:
+				return nil
+			case hardwareZX80:
+				t.Machine = zx81.ZX80
+				return nil
+			default:
+				flagged = true
+			}
+		}
+	}
+
+	if flagged {
+		return fmt.Errorf("tape is not flagged as a ZX81 or ZX80 tape")
+	}
+	return nil
+}
+
+// DisplayGeometry prints the TZX blocks, followed by the details of each program.
+func (t TZX) DisplayGeometry() {
+	t.TZX.DisplayGeometry()
+
+	for i, p := range t.programs {
+		fmt.Println()
+		fmt.Printf("PROGRAM #%02d:\n", i+1)
+		fmt.Print(p)
+	}
+
+	for _, w := range t.warnings {
+		fmt.Printf("WARNING: %s\n", w)
+	}
+}
+
+// DisplayBASIC outputs all BASIC programs on the tape.
+func (t TZX) DisplayBASIC() {
+	zx81.DisplayBASIC(t.programs)
+}
+
+// Programs returns the programs found on the tape.
+func (t TZX) Programs() []zx81.Program {
+	return t.programs
+}